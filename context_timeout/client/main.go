@@ -4,63 +4,76 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
-	"net/http"
-	"sync"
+	"log"
+	"main/httpclient"
+	"main/tracing"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 )
 
-type respData struct {
-	resp *http.Response
-	err  error
-}
+// client 在进程内共享，连接池和熔断状态才能复用
+// server 有一半的请求会睡 10 秒，20 毫秒（或者最近延迟的 p95）还没返回就再发一份对冲请求，
+// 单次尝试 50 毫秒超时后重试，大概率能拿到 quick response；剩余时间通过 Header 传给 server
+// 每次尝试都会创建一个客户端 span，并通过 traceparent 头把链路传给 server
+var client = httpclient.New(httpclient.Config{
+	Transport:         tracing.NewTransport(httpclient.DefaultTransport),
+	AttemptTimeout:    50 * time.Millisecond,
+	PropagateDeadline: true,
+	Hedge: httpclient.HedgePolicy{
+		Percentile: 0.95,
+		Delay:      20 * time.Millisecond,
+	},
+	Retry: httpclient.RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   5 * time.Millisecond,
+	},
+	Breaker: httpclient.BreakerConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      10 * time.Second,
+	},
+	Hooks: httpclient.Hooks{
+		OnAttempt: func(info httpclient.AttemptInfo) {
+			log.Printf("%s %s attempt=%d status=%d err=%v cost=%s retry=%t",
+				info.Method, info.Host, info.Attempt, info.StatusCode, info.Err, info.Duration, info.WillRetry)
+		},
+		OnStateChange: func(host string, from, to httpclient.State) {
+			log.Printf("circuit breaker of %s: %s -> %s", host, from, to)
+		},
+		OnHedge: func(host string) {
+			log.Printf("%s is slow, send hedged request", host)
+		},
+	},
+})
 
 func doCall(ctx context.Context) {
-	transport := http.Transport{
-		DisableKeepAlives: true,
-	}
-	client := http.Client{
-		Transport: &transport,
-	}
-	respChan := make(chan *respData, 1)
-	req, err := http.NewRequest("GET", "http://127.0.0.1:8001/", nil)
+	// 重试和对冲产生的 HTTP span 都挂在这个 span 下面
+	ctx, span := otel.Tracer("client").Start(ctx, "doCall")
+	defer span.End()
+	// 超时、重试和取消都由 ctx 和 client 的配置控制，不再需要自己开 goroutine 做 select
+	resp, err := client.Get(ctx, "http://127.0.0.1:8001/")
 	if err != nil {
-		fmt.Printf("new request failed, err:%v\n", err)
+		span.RecordError(err)
+		fmt.Printf("call server api failed, trace_id:%s, err:%v\n", span.SpanContext().TraceID(), err)
 		return
 	}
-
-	req = req.WithContext(ctx) // 使用带超时的ctx创建一个新的client request
-	var wg sync.WaitGroup
-	wg.Add(1)
-	defer wg.Wait()
-	go func() {
-		resp, err := client.Do(req)
-		fmt.Printf("client.do resp:%v, err:%v\n", resp, err)
-		rd := &respData{
-			resp: resp,
-			err:  err,
-		}
-		respChan <- rd
-		wg.Done()
-	}()
-
-	select {
-	case <-ctx.Done():
-		fmt.Println("call api timeout")
-	case result := <-respChan:
-		fmt.Println("call server api success")
-		if result.err != nil {
-			fmt.Printf("call server api failed, err:%v\n", result.err)
-			return
-		}
-		defer result.resp.Body.Close()
-		data, _ := ioutil.ReadAll(result.resp.Body)
-		fmt.Printf("resp:%v\n", string(data))
-	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+	fmt.Printf("resp:%v\n", string(data))
 }
 
 func main() {
-	// 定义一个100毫秒的超时
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	// span 打印到标准输出，生产环境换成 OTLP、Jaeger 等 exporter
+	exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+	if err != nil {
+		log.Fatal(err)
+	}
+	tp := tracing.Init("client", exporter)
+	defer tp.Shutdown(context.Background())
+
+	// 定义一个200毫秒的超时，包括所有重试
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
 	defer cancel()
 	doCall(ctx)
 }
@@ -0,0 +1,58 @@
+// Package deadline 在服务之间传递请求的剩余时间
+//
+// 客户端把 ctx 剩下的时间（毫秒）写进 Header，服务端据此给处理函数的 ctx 设置同样的截止时间，
+// 上游已经放弃的请求下游也能及时停止。传的是剩余时间而不是绝对时间，不受两台机器时钟误差的影响。
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Header 剩余时间的毫秒数
+const Header = "X-Request-Timeout-Ms"
+
+// Inject 根据 req 的 ctx 设置 Header，ctx 没有截止时间时什么也不做
+func Inject(req *http.Request) {
+	d, ok := req.Context().Deadline()
+	if !ok {
+		return
+	}
+	ms := time.Until(d).Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	req.Header.Set(Header, strconv.FormatInt(ms, 10))
+}
+
+// Parse 读取 Header 中的剩余时间
+func Parse(r *http.Request) (time.Duration, bool) {
+	v := r.Header.Get(Header)
+	if v == "" {
+		return 0, false
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// Middleware 给请求的 ctx 加上调用方传来的截止时间，max 大于 0 时限制最长时间，防止调用方传一个很大的值
+func Middleware(max time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, ok := Parse(r)
+		if max > 0 && (!ok || d > max) {
+			d, ok = max, true
+		}
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,169 @@
+package fault
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Config 故障注入的配置，可以从 JSON 文件加载
+//
+//	{
+//	  "seed": 1,
+//	  "headers": true,
+//	  "rules": [
+//	    {"path": "/", "probability": 0.5, "latency": {"mean": "10s"}},
+//	    {"path": "/api/", "method": "POST", "probability": 0.1, "status": 503},
+//	    {"path": "/download", "drip": {"chunk": 16, "interval": "100ms"}}
+//	  ]
+//	}
+type Config struct {
+	// Rules 按顺序匹配，第一条匹配并且按概率命中的规则生效
+	Rules []Rule `json:"rules"`
+	// Headers 为 true 时允许请求通过 X-Fault-* Header 指定故障，优先于 Rules
+	Headers bool `json:"headers"`
+	// Seed 随机数种子，为 0 时使用当前时间；固定种子可以让每次测试命中的故障都一样
+	Seed int64 `json:"seed"`
+	// Body 没有下游 handler 时返回的内容，为空时使用 "ok"
+	Body string `json:"body"`
+}
+
+// Rule 一条故障规则
+type Rule struct {
+	// Method 为空时匹配所有方法
+	Method string `json:"method"`
+	// Path 为空时匹配所有路径，以 / 结尾时按前缀匹配，否则要完全相同
+	Path string `json:"path"`
+	// Probability 命中的概率，为 0 或大于等于 1 时总是命中
+	Probability float64 `json:"probability"`
+	Fault
+}
+
+// Fault 对一个请求注入的故障，可以组合，比如先延迟再返回截断的 body
+type Fault struct {
+	// Latency 处理请求前的延迟
+	Latency Latency `json:"latency"`
+	// Status 大于 0 时不调用下游 handler，直接返回这个状态码
+	Status int `json:"status"`
+	// Body 不为空时不调用下游 handler，返回 200 和这个内容
+	Body string `json:"body"`
+	// Reset 为 true 时不返回任何内容，直接用 RST 断开连接
+	Reset bool `json:"reset"`
+	// Truncate 大于 0 时 Content-Length 按完整的 body 设置，只写这么多字节就断开连接
+	Truncate int `json:"truncate"`
+	// Drip 慢慢地一块一块写 body
+	Drip Drip `json:"drip"`
+}
+
+// Latency 延迟的分布，按 Distribution 取样后限制在 [Min, Max] 之间（Max 为 0 时不限制上限）
+type Latency struct {
+	// Distribution 可以是 fixed（默认，固定为 Mean）、uniform（Min 到 Max 均匀分布）、
+	// normal（均值 Mean、标准差 Stddev 的正态分布）、exponential（Min 加上均值为 Mean 的指数分布）
+	Distribution string   `json:"distribution"`
+	Mean         Duration `json:"mean"`
+	Stddev       Duration `json:"stddev"`
+	Min          Duration `json:"min"`
+	Max          Duration `json:"max"`
+}
+
+// Drip 每隔 Interval 写 Chunk 个字节
+type Drip struct {
+	Chunk    int      `json:"chunk"`
+	Interval Duration `json:"interval"`
+}
+
+// Duration JSON 中可以写 "100ms" 这样的字符串，也可以写纳秒数
+type Duration time.Duration
+
+// UnmarshalJSON 实现 json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v)
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("fault: invalid duration %s", b)
+	}
+	return nil
+}
+
+// MarshalJSON 实现 json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load 读取 JSON 格式的配置文件
+func Load(path string) (Config, error) {
+	var cfg Config
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("fault: parse %s: %w", path, err)
+	}
+	return cfg, cfg.validate()
+}
+
+func (c Config) validate() error {
+	for i, r := range c.Rules {
+		switch r.Latency.Distribution {
+		case "", "fixed", "uniform", "normal", "exponential":
+		default:
+			return fmt.Errorf("fault: rule %d: unknown latency distribution %q", i, r.Latency.Distribution)
+		}
+		if r.Status != 0 && (r.Status < 100 || r.Status > 999) {
+			return fmt.Errorf("fault: rule %d: invalid status %d", i, r.Status)
+		}
+	}
+	return nil
+}
+
+func (r Rule) match(method, path string) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, method) {
+		return false
+	}
+	switch {
+	case r.Path == "":
+		return true
+	case strings.HasSuffix(r.Path, "/"):
+		return strings.HasPrefix(path, r.Path)
+	}
+	return r.Path == path
+}
+
+// sample 按分布取一个延迟
+func (l Latency) sample(rnd *rand.Rand) time.Duration {
+	var d time.Duration
+	switch l.Distribution {
+	case "uniform":
+		if l.Max > l.Min {
+			d = time.Duration(l.Min) + time.Duration(rnd.Int63n(int64(l.Max-l.Min)))
+		}
+	case "normal":
+		d = time.Duration(l.Mean) + time.Duration(rnd.NormFloat64()*float64(l.Stddev))
+	case "exponential":
+		d = time.Duration(l.Min) + time.Duration(rnd.ExpFloat64()*float64(l.Mean))
+	default:
+		d = time.Duration(l.Mean)
+	}
+	if d < time.Duration(l.Min) {
+		d = time.Duration(l.Min)
+	}
+	if l.Max > 0 && d > time.Duration(l.Max) {
+		d = time.Duration(l.Max)
+	}
+	return d
+}
@@ -0,0 +1,250 @@
+// Package fault 故障注入的 HTTP handler，用来确定性地测试客户端的超时、重试和熔断
+//
+// 按 Config.Rules 或者请求的 X-Fault-* Header 给请求加上延迟、错误状态码、连接重置、
+// 截断的 body 和慢速返回。可以包在已有的 handler 外面，也可以单独作为服务（见 faultserver）。
+package fault
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config.Headers 为 true 时识别的 Header，请求带了其中任意一个时忽略 Rules
+const (
+	// HeaderDelay 固定延迟，比如 "2s"
+	HeaderDelay = "X-Fault-Delay"
+	// HeaderStatus 返回的状态码，比如 "503"
+	HeaderStatus = "X-Fault-Status"
+	// HeaderReset 为 "1" 或 "true" 时重置连接
+	HeaderReset = "X-Fault-Reset"
+	// HeaderTruncate 只写这么多字节的 body
+	HeaderTruncate = "X-Fault-Truncate"
+	// HeaderDrip 格式为 "字节数/间隔"，比如 "16/100ms"
+	HeaderDrip = "X-Fault-Drip"
+)
+
+type injector struct {
+	cfg  Config
+	next http.Handler
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// Handler 返回注入故障的 handler，没有命中故障的请求交给 next，next 为 nil 时返回 cfg.Body
+func Handler(cfg Config, next http.Handler) http.Handler {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if next == nil {
+		body := cfg.Body
+		if body == "" {
+			body = "ok"
+		}
+		next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, body)
+		})
+	}
+	return &injector{cfg: cfg, next: next, rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (in *injector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, ok, err := in.pick(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !ok {
+		in.next.ServeHTTP(w, r)
+		return
+	}
+
+	if d := in.latency(f.Latency); d > 0 {
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			// 客户端已经放弃了
+			t.Stop()
+			return
+		}
+	}
+	if f.Reset {
+		reset(w)
+		return
+	}
+
+	next := in.next
+	switch {
+	case f.Status > 0:
+		next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(f.Status), f.Status)
+		})
+	case f.Body != "":
+		next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, f.Body)
+		})
+	}
+	if f.Truncate <= 0 && f.Drip.Chunk <= 0 {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	// 截断和慢速返回需要知道完整的 body，先录下来再按故障写出去
+	rec := httptest.NewRecorder()
+	next.ServeHTTP(rec, r)
+	body := rec.Body.Bytes()
+	for k, v := range rec.Header() {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(rec.Code)
+
+	truncated := f.Truncate > 0 && f.Truncate < len(body)
+	if truncated {
+		body = body[:f.Truncate]
+	}
+	if !drip(w, r, body, f.Drip) {
+		return
+	}
+	if truncated {
+		// 写出去的内容比 Content-Length 少，中止 handler 让 net/http 断开连接
+		if fl, ok := w.(http.Flusher); ok {
+			fl.Flush()
+		}
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// pick 选出这个请求要注入的故障，ok 为 false 时不注入
+func (in *injector) pick(r *http.Request) (f Fault, ok bool, err error) {
+	if in.cfg.Headers {
+		if f, ok, err = fromHeader(r.Header); ok || err != nil {
+			return f, ok, err
+		}
+	}
+	for _, rule := range in.cfg.Rules {
+		if !rule.match(r.Method, r.URL.Path) {
+			continue
+		}
+		if rule.Probability > 0 && rule.Probability < 1 && in.float() >= rule.Probability {
+			continue
+		}
+		return rule.Fault, true, nil
+	}
+	return Fault{}, false, nil
+}
+
+func (in *injector) float() float64 {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.rnd.Float64()
+}
+
+func (in *injector) latency(l Latency) time.Duration {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return l.sample(in.rnd)
+}
+
+// fromHeader 解析 X-Fault-* Header
+func fromHeader(h http.Header) (f Fault, ok bool, err error) {
+	if v := h.Get(HeaderDelay); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return f, false, fmt.Errorf("fault: invalid %s: %w", HeaderDelay, err)
+		}
+		f.Latency.Mean, ok = Duration(d), true
+	}
+	if v := h.Get(HeaderStatus); v != "" {
+		code, err := strconv.Atoi(v)
+		if err != nil || code < 100 || code > 999 {
+			return f, false, fmt.Errorf("fault: invalid %s %q", HeaderStatus, v)
+		}
+		f.Status, ok = code, true
+	}
+	if v := h.Get(HeaderReset); v != "" {
+		reset, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, false, fmt.Errorf("fault: invalid %s: %w", HeaderReset, err)
+		}
+		f.Reset, ok = reset, true
+	}
+	if v := h.Get(HeaderTruncate); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return f, false, fmt.Errorf("fault: invalid %s %q", HeaderTruncate, v)
+		}
+		f.Truncate, ok = n, true
+	}
+	if v := h.Get(HeaderDrip); v != "" {
+		parts := strings.SplitN(v, "/", 2)
+		if len(parts) != 2 {
+			return f, false, fmt.Errorf("fault: invalid %s %q, want chunk/interval", HeaderDrip, v)
+		}
+		chunk, err1 := strconv.Atoi(parts[0])
+		interval, err2 := time.ParseDuration(parts[1])
+		if err1 != nil || err2 != nil || chunk <= 0 {
+			return f, false, fmt.Errorf("fault: invalid %s %q, want chunk/interval", HeaderDrip, v)
+		}
+		f.Drip, ok = Drip{Chunk: chunk, Interval: Duration(interval)}, true
+	}
+	return f, ok, nil
+}
+
+// drip 按 Drip 分块写 body，客户端断开时返回 false
+func drip(w http.ResponseWriter, r *http.Request, body []byte, d Drip) bool {
+	if d.Chunk <= 0 {
+		w.Write(body)
+		return true
+	}
+	fl, _ := w.(http.Flusher)
+	for len(body) > 0 {
+		n := d.Chunk
+		if n > len(body) {
+			n = len(body)
+		}
+		if _, err := w.Write(body[:n]); err != nil {
+			return false
+		}
+		if fl != nil {
+			fl.Flush()
+		}
+		body = body[n:]
+		if len(body) == 0 {
+			break
+		}
+		t := time.NewTimer(time.Duration(d.Interval))
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			t.Stop()
+			return false
+		}
+	}
+	return true
+}
+
+// reset 设置 SO_LINGER 为 0 后关闭连接，对方会收到 RST 而不是正常的 FIN
+func reset(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
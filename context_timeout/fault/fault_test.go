@@ -0,0 +1,134 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, url string, header map[string]string) (*http.Response, string, error) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	return resp, string(b), err
+}
+
+func TestRules(t *testing.T) {
+	srv := httptest.NewServer(Handler(Config{
+		Seed: 1,
+		Rules: []Rule{
+			{Path: "/slow", Fault: Fault{Latency: Latency{Mean: Duration(50 * time.Millisecond)}}},
+			{Path: "/api/", Method: "GET", Fault: Fault{Status: http.StatusServiceUnavailable}},
+			{Path: "/half/", Probability: 0.5, Fault: Fault{Status: http.StatusInternalServerError}},
+		},
+	}, nil))
+	defer srv.Close()
+
+	start := time.Now()
+	if _, body, err := get(t, srv.URL+"/slow", nil); err != nil || body != "ok" || time.Since(start) < 50*time.Millisecond {
+		t.Fatalf("body = %q, err = %v, took %s", body, err, time.Since(start))
+	}
+	if resp, _, err := get(t, srv.URL+"/api/user", nil); err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("resp = %v, err = %v", resp, err)
+	}
+	// 路径不匹配的请求不受影响
+	if resp, _, err := get(t, srv.URL+"/apix", nil); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("resp = %v, err = %v", resp, err)
+	}
+
+	failed := 0
+	for i := 0; i < 200; i++ {
+		resp, _, err := get(t, srv.URL+"/half/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode == http.StatusInternalServerError {
+			failed++
+		}
+	}
+	if failed < 70 || failed > 130 {
+		t.Fatalf("%d of 200 requests failed, want about 100", failed)
+	}
+}
+
+func TestHeaderFaults(t *testing.T) {
+	srv := httptest.NewServer(Handler(Config{Headers: true}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "0123456789")
+	})))
+	defer srv.Close()
+
+	if _, _, err := get(t, srv.URL, map[string]string{HeaderReset: "1"}); err == nil {
+		t.Fatal("want error for reset connection")
+	}
+
+	resp, body, err := get(t, srv.URL, map[string]string{HeaderTruncate: "4"})
+	if !errors.Is(err, io.ErrUnexpectedEOF) || body != "0123" || resp.ContentLength != 10 {
+		t.Fatalf("body = %q, err = %v", body, err)
+	}
+
+	start := time.Now()
+	_, body, err = get(t, srv.URL, map[string]string{HeaderDrip: "4/30ms"})
+	if err != nil || body != "0123456789" || time.Since(start) < 60*time.Millisecond {
+		t.Fatalf("body = %q, err = %v, took %s", body, err, time.Since(start))
+	}
+
+	if resp, _, _ := get(t, srv.URL, map[string]string{HeaderDrip: "fast"}); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestDelayHonorsClientCancel(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(Handler(Config{Headers: true}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(done)
+	})))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	req.Header.Set(HeaderDelay, "10s")
+	if _, err := http.DefaultClient.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+	select {
+	case <-done:
+		t.Fatal("next handler should not run after the client gave up")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load("../faultserver/faults.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Rules) == 0 || time.Duration(cfg.Rules[0].Latency.Mean) != 10*time.Second {
+		t.Fatalf("cfg = %+v", cfg)
+	}
+
+	l := Latency{Distribution: "exponential", Min: Duration(time.Millisecond), Mean: Duration(time.Second), Max: Duration(2 * time.Second)}
+	if err := (Config{Rules: []Rule{{Fault: Fault{Latency: Latency{Distribution: "pareto"}}}}}).validate(); err == nil || !strings.Contains(err.Error(), "pareto") {
+		t.Fatalf("err = %v", err)
+	}
+	in := Handler(Config{Seed: 1}, nil).(*injector)
+	for i := 0; i < 100; i++ {
+		if d := in.latency(l); d < time.Millisecond || d > 2*time.Second {
+			t.Fatalf("latency %s out of range", d)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// faultserver 按配置文件注入故障的测试服务
+//
+//	go run ./faultserver -config faultserver/faults.json
+//	curl -H 'X-Fault-Status: 503' http://127.0.0.1:8001/
+//	curl -H 'X-Fault-Drip: 4/500ms' http://127.0.0.1:8001/
+package main
+
+import (
+	"flag"
+	"log"
+	"main/fault"
+	"net/http"
+)
+
+func main() {
+	addr := flag.String("addr", ":8001", "listen address")
+	config := flag.String("config", "", "fault config file (json), empty means header-driven only")
+	seed := flag.Int64("seed", 0, "random seed, overrides the config file")
+	flag.Parse()
+
+	cfg := fault.Config{Headers: true}
+	if *config != "" {
+		var err error
+		if cfg, err = fault.Load(*config); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *seed != 0 {
+		cfg.Seed = *seed
+	}
+
+	log.Printf("fault server listening on %s, %d rules", *addr, len(cfg.Rules))
+	if err := http.ListenAndServe(*addr, fault.Handler(cfg, nil)); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,11 @@
+module main
+
+go 1.14
+
+require (
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.7.0
+	go.opentelemetry.io/otel/sdk v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
+	golang.org/x/sys v0.0.0-20220319134239-a9b59b0215f8 // indirect
+)
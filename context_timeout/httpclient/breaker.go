@@ -0,0 +1,156 @@
+package httpclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断器打开，请求没有发出去
+var ErrCircuitOpen = errors.New("httpclient: circuit breaker is open")
+
+// State 熔断器状态
+type State int
+
+const (
+	// StateClosed 正常放行
+	StateClosed State = iota
+	// StateOpen 熔断中，请求直接失败
+	StateOpen
+	// StateHalfOpen 熔断时间到了，放少量请求试探
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerConfig 熔断器配置，每个 host 一个熔断器
+type BreakerConfig struct {
+	// Disabled 关闭熔断
+	Disabled bool
+	// FailureThreshold 连续失败多少次后打开，为 0 时使用 5
+	FailureThreshold int
+	// OpenTimeout 打开多久后进入半开状态，为 0 时使用 30 秒
+	OpenTimeout time.Duration
+	// HalfOpenRequests 半开状态下同时放行的请求数，为 0 时使用 1
+	HalfOpenRequests int
+}
+
+type breaker struct {
+	cfg      BreakerConfig
+	onChange func(from, to State)
+	now      func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+func newBreaker(cfg BreakerConfig, onChange func(from, to State)) *breaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+	return &breaker{cfg: cfg, onChange: onChange, now: time.Now}
+}
+
+// allow 判断请求能否发出，能发出时调用方必须在请求结束后调用 done
+// probe 为 true 表示这是半开状态下的试探请求
+func (b *breaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cfg.OpenTimeout {
+			return false, ErrCircuitOpen
+		}
+		b.setState(StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if b.probes >= b.cfg.HalfOpenRequests {
+			return false, ErrCircuitOpen
+		}
+		b.probes++
+		return true, nil
+	}
+	return false, nil
+}
+
+// done 记录请求结果，状态已经变化时忽略之前放行的请求的结果
+func (b *breaker) done(probe, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		if b.state != StateHalfOpen {
+			return
+		}
+		b.probes--
+		if success {
+			b.setState(StateClosed)
+		} else {
+			b.setState(StateOpen)
+		}
+		return
+	}
+	if b.state != StateClosed {
+		return
+	}
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.cfg.FailureThreshold {
+		b.setState(StateOpen)
+	}
+}
+
+// release 请求被调用方主动取消，不计入成功或失败，只归还试探名额
+func (b *breaker) release(probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe && b.state == StateHalfOpen {
+		b.probes--
+	}
+}
+
+func (b *breaker) current() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// setState 调用时需要持有锁
+func (b *breaker) setState(to State) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	b.failures = 0
+	if to == StateOpen {
+		b.openedAt = b.now()
+	}
+	if to != StateHalfOpen {
+		b.probes = 0
+	}
+	if b.onChange != nil {
+		b.onChange(from, to)
+	}
+}
@@ -0,0 +1,337 @@
+// Package httpclient 带超时、重试、退避和熔断的 HTTP 客户端
+//
+// 整体超时取 ctx 的截止时间和 Config.Timeout 中较早的一个，每次尝试另有 AttemptTimeout，
+// 幂等请求失败后按指数退避加随机抖动重试，慢的时候可以发对冲请求，每个 host 一个熔断器，Hooks 用来接入监控。
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"main/deadline"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy 重试策略
+type RetryPolicy struct {
+	// MaxAttempts 最多尝试几次（包括第一次），为 0 时使用 3，为 1 时不重试
+	MaxAttempts int
+	// BaseDelay 第一次重试前的最大等待时间，之后每次翻倍，为 0 时使用 100 毫秒
+	BaseDelay time.Duration
+	// MaxDelay 单次等待的上限，也是 Retry-After 的上限，为 0 时使用 2 秒
+	MaxDelay time.Duration
+	// RetryNonIdempotent 为 true 时 POST、PATCH 也重试，默认只重试幂等请求和带 Idempotency-Key 的请求
+	RetryNonIdempotent bool
+	// ShouldRetry 判断这次结果是否需要重试，为 nil 时使用 DefaultShouldRetry
+	ShouldRetry func(resp *http.Response, err error) bool
+}
+
+// AttemptInfo 一次尝试的结果，传给 Hooks.OnAttempt
+type AttemptInfo struct {
+	Method     string
+	Host       string
+	Attempt    int
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+	// WillRetry 后面还会重试
+	WillRetry bool
+}
+
+// Hooks 监控埋点，回调在请求的 goroutine 中同步执行，不能阻塞
+type Hooks struct {
+	// OnAttempt 每次尝试结束后调用，包括被熔断拒绝的尝试
+	OnAttempt func(AttemptInfo)
+	// OnStateChange 熔断器状态变化时调用，调用时持有熔断器的锁，不能再用这个 Client 发请求
+	OnStateChange func(host string, from, to State)
+	// OnHedge 发出对冲请求时调用
+	OnHedge func(host string)
+}
+
+// Config Client 的配置
+type Config struct {
+	// Transport 为 nil 时使用共享的 DefaultTransport
+	Transport http.RoundTripper
+	// Timeout 整个调用（包括重试）的超时，ctx 的截止时间更早时以 ctx 为准
+	Timeout time.Duration
+	// AttemptTimeout 单次尝试的超时，为 0 时不单独限制
+	AttemptTimeout time.Duration
+	Retry          RetryPolicy
+	Breaker        BreakerConfig
+	Hedge          HedgePolicy
+	// PropagateDeadline 把每次尝试剩余的时间通过 deadline.Header 传给服务端
+	PropagateDeadline bool
+	Hooks             Hooks
+}
+
+// Client 可以并发使用，应该在进程内复用
+type Client struct {
+	cfg  Config
+	http *http.Client
+
+	mu        sync.Mutex
+	breakers  map[string]*breaker
+	latencies map[string]*latencyWindow
+	rand      *rand.Rand
+}
+
+// New 创建 Client
+func New(cfg Config) *Client {
+	if cfg.Transport == nil {
+		cfg.Transport = DefaultTransport
+	}
+	if cfg.Retry.MaxAttempts <= 0 {
+		cfg.Retry.MaxAttempts = 3
+	}
+	if cfg.Retry.BaseDelay <= 0 {
+		cfg.Retry.BaseDelay = 100 * time.Millisecond
+	}
+	if cfg.Retry.MaxDelay <= 0 {
+		cfg.Retry.MaxDelay = 2 * time.Second
+	}
+	if cfg.Retry.ShouldRetry == nil {
+		cfg.Retry.ShouldRetry = DefaultShouldRetry
+	}
+	return &Client{
+		cfg:       cfg,
+		http:      &http.Client{Transport: cfg.Transport},
+		breakers:  make(map[string]*breaker),
+		latencies: make(map[string]*latencyWindow),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// DefaultShouldRetry 网络错误、429 和 502、503、504 时重试，ctx 被取消时不重试
+func DefaultShouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, ErrCircuitOpen)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Get 发送 GET 请求
+func (c *Client) Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Do 发送请求，超时和取消由 req.Context() 控制
+// 重试次数用完时返回最后一次的响应或错误；返回的 Body 读完后必须关闭，关闭时才会释放超时计时器
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if c.cfg.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+	}
+
+	attempts := c.cfg.Retry.MaxAttempts
+	idempotent := c.retryable(req)
+	if !idempotent {
+		attempts = 1
+	}
+	host := req.URL.Host
+	br := c.breaker(host)
+
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		var resp *http.Response
+		var attemptCancel context.CancelFunc
+		var err error
+		if idempotent && c.cfg.Hedge.enabled() {
+			resp, attemptCancel, err = c.hedged(ctx, req, br, host)
+		} else {
+			resp, attemptCancel, err = c.attempt(ctx, req, br)
+		}
+		retry := attempt < attempts && c.cfg.Retry.ShouldRetry(resp, err)
+		var wait time.Duration
+		if retry {
+			wait = c.backoff(attempt, resp)
+			// 等待结束时已经超过截止时间，没必要再试
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+				retry = false
+			}
+		}
+		if hook := c.cfg.Hooks.OnAttempt; hook != nil {
+			info := AttemptInfo{
+				Method:    req.Method,
+				Host:      host,
+				Attempt:   attempt,
+				Err:       err,
+				Duration:  time.Since(start),
+				WillRetry: retry,
+			}
+			if resp != nil {
+				info.StatusCode = resp.StatusCode
+			}
+			hook(info)
+		}
+		if !retry {
+			if err != nil {
+				attemptCancel()
+				cancel()
+				return nil, err
+			}
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: func() {
+				attemptCancel()
+				cancel()
+			}}
+			return resp, nil
+		}
+		if resp != nil {
+			// 读完剩余的 body，连接才能放回连接池
+			io.CopyN(ioutil.Discard, resp.Body, 4<<10)
+			resp.Body.Close()
+		}
+		attemptCancel()
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			cancel()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// attempt 经过熔断器发送一次请求，返回的 cancel 在响应 body 用完后调用
+func (c *Client) attempt(ctx context.Context, req *http.Request, br *breaker) (*http.Response, context.CancelFunc, error) {
+	var probe bool
+	if br != nil {
+		var err error
+		if probe, err = br.allow(); err != nil {
+			return nil, func() {}, err
+		}
+	}
+
+	if c.cfg.AttemptTimeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, c.cfg.AttemptTimeout)
+		resp, err := c.send(ctx, req)
+		record(br, probe, resp, err)
+		if err != nil {
+			cancel()
+			return nil, func() {}, err
+		}
+		return resp, cancel, nil
+	}
+	resp, err := c.send(ctx, req)
+	record(br, probe, resp, err)
+	return resp, func() {}, err
+}
+
+// record 把结果告诉熔断器，被取消的请求（比如输掉的对冲请求）不算失败
+func record(br *breaker, probe bool, resp *http.Response, err error) {
+	if br == nil {
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		br.release(probe)
+		return
+	}
+	br.done(probe, success(resp, err))
+}
+
+// send 每次尝试都用新的 body，需要 req.GetBody
+func (c *Client) send(ctx context.Context, req *http.Request) (*http.Response, error) {
+	r := req.Clone(ctx)
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	if c.cfg.PropagateDeadline {
+		deadline.Inject(r)
+	}
+	return c.http.Do(r)
+}
+
+// success 网络错误和 5xx 记为熔断器的失败，4xx 是调用方的问题，不算
+func success(resp *http.Response, err error) bool {
+	return err == nil && resp.StatusCode < 500
+}
+
+// retryable 幂等请求才能重试，有 body 的请求还要能重新读取 body
+func (c *Client) retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return c.cfg.Retry.RetryNonIdempotent || req.Header.Get("Idempotency-Key") != ""
+}
+
+// backoff 指数退避加全抖动：在 [0, min(MaxDelay, BaseDelay*2^(attempt-1))) 之间随机等待
+// 服务端返回 Retry-After 时按它等待，但不超过 MaxDelay
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	max := c.cfg.Retry.MaxDelay
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			if d := time.Duration(secs) * time.Second; d < max {
+				return d
+			}
+			return max
+		}
+	}
+	d := c.cfg.Retry.BaseDelay << uint(attempt-1)
+	if d <= 0 || d > max {
+		d = max
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Duration(c.rand.Int63n(int64(d)))
+}
+
+// breaker 返回 host 对应的熔断器，关闭熔断时返回 nil
+func (c *Client) breaker(host string) *breaker {
+	if c.cfg.Breaker.Disabled {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[host]
+	if !ok {
+		var onChange func(from, to State)
+		if hook := c.cfg.Hooks.OnStateChange; hook != nil {
+			onChange = func(from, to State) { hook(host, from, to) }
+		}
+		b = newBreaker(c.cfg.Breaker, onChange)
+		c.breakers[host] = b
+	}
+	return b
+}
+
+// BreakerState 返回 host 的熔断器状态
+func (c *Client) BreakerState(host string) State {
+	if b := c.breaker(host); b != nil {
+		return b.current()
+	}
+	return StateClosed
+}
+
+// cancelBody 关闭 body 时释放超时计时器
+type cancelBody struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
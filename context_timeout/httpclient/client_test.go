@@ -0,0 +1,211 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"main/deadline"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func fastRetry(cfg Config) Config {
+	cfg.Retry.BaseDelay = time.Millisecond
+	cfg.Retry.MaxDelay = 5 * time.Millisecond
+	return cfg
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(append([]byte("ok "), b...))
+	}))
+	defer srv.Close()
+
+	var attempts []AttemptInfo
+	c := New(fastRetry(Config{Hooks: Hooks{OnAttempt: func(info AttemptInfo) {
+		attempts = append(attempts, info)
+	}}}))
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("body"))
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	// 每次重试都要重新发送 body
+	if string(b) != "ok body" || len(attempts) != 3 {
+		t.Fatalf("body = %q, attempts = %+v", b, attempts)
+	}
+	if !attempts[0].WillRetry || attempts[0].StatusCode != http.StatusServiceUnavailable || attempts[2].WillRetry {
+		t.Fatalf("unexpected attempts %+v", attempts)
+	}
+
+	// POST 不是幂等的，不重试
+	atomic.StoreInt32(&calls, 0)
+	req, _ = http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("body"))
+	resp, err = c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("status = %d, calls = %d", resp.StatusCode, calls)
+	}
+}
+
+func TestAttemptAndOverallTimeout(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 第一次很慢，触发单次超时后重试
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Write([]byte("quick"))
+	}))
+	defer srv.Close()
+
+	c := New(fastRetry(Config{AttemptTimeout: 50 * time.Millisecond}))
+	resp, err := c.Get(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "quick" {
+		t.Fatalf("body = %q", b)
+	}
+
+	// ctx 的截止时间比单次超时更早时以 ctx 为准
+	atomic.StoreInt32(&calls, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = c.Get(ctx, srv.URL)
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("err = %v after %s", err, time.Since(start))
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	var changes []string
+	c := New(Config{
+		Retry:   RetryPolicy{MaxAttempts: 1},
+		Breaker: BreakerConfig{FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond},
+		Hooks: Hooks{OnStateChange: func(host string, from, to State) {
+			changes = append(changes, from.String()+">"+to.String())
+		}},
+	})
+	host := strings.TrimPrefix(srv.URL, "http://")
+	for i := 0; i < 2; i++ {
+		resp, err := c.Get(context.Background(), srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if _, err := c.Get(context.Background(), srv.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(60 * time.Millisecond)
+	resp, err := c.Get(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if c.BreakerState(host) != StateClosed {
+		t.Fatalf("state = %s", c.BreakerState(host))
+	}
+	want := "closed>open,open>half-open,half-open>closed"
+	if got := strings.Join(changes, ","); got != want {
+		t.Fatalf("changes = %s, want %s", got, want)
+	}
+}
+
+func TestHedgedRequest(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 第一个请求卡住，对冲出去的第二个请求很快返回
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("hedge"))
+	}))
+	defer srv.Close()
+
+	var hedges int32
+	c := New(Config{
+		Hedge: HedgePolicy{Delay: 20 * time.Millisecond},
+		Hooks: Hooks{OnHedge: func(string) { atomic.AddInt32(&hedges, 1) }},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	resp, err := c.Get(ctx, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "hedge" || atomic.LoadInt32(&hedges) != 1 || time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("body = %q, hedges = %d, took %s", b, hedges, time.Since(start))
+	}
+}
+
+func TestLatencyPercentile(t *testing.T) {
+	var w latencyWindow
+	if _, ok := w.percentile(0.9, 1); ok {
+		t.Fatal("empty window has no percentile")
+	}
+	for i := 1; i <= 100; i++ {
+		w.add(time.Duration(i) * time.Millisecond)
+	}
+	if d, ok := w.percentile(0.9, 20); !ok || d != 90*time.Millisecond {
+		t.Fatalf("p90 = %s", d)
+	}
+}
+
+func TestPropagateDeadline(t *testing.T) {
+	remaining := make(chan time.Duration, 1)
+	srv := httptest.NewServer(deadline.Middleware(0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, _ := r.Context().Deadline()
+		remaining <- time.Until(d)
+	})))
+	defer srv.Close()
+
+	c := New(Config{PropagateDeadline: true})
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	resp, err := c.Get(ctx, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if d := <-remaining; d <= 0 || d > 300*time.Millisecond {
+		t.Fatalf("server deadline in %s", d)
+	}
+}
@@ -0,0 +1,193 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HedgePolicy 对冲请求：第一次尝试超过阈值还没返回时再发一份，谁先成功用谁，其余的取消
+// 只对幂等请求生效，用来削掉长尾延迟
+type HedgePolicy struct {
+	// Percentile 按这个 host 最近成功请求延迟的分位数作为阈值，比如 0.95，为 0 时只用 Delay
+	Percentile float64
+	// MinSamples 样本数不够时不按分位数计算，为 0 时使用 20
+	MinSamples int
+	// Delay 固定阈值，和 Percentile 同时设置时作为阈值的下限，都为 0 时不对冲
+	Delay time.Duration
+	// MaxHedges 最多额外发几份，为 0 时使用 1
+	MaxHedges int
+}
+
+func (p HedgePolicy) enabled() bool {
+	return p.Percentile > 0 || p.Delay > 0
+}
+
+// latencyWindow 保存最近的请求延迟
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples [128]time.Duration
+	n       int
+	next    int
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	w.mu.Lock()
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % len(w.samples)
+	if w.n < len(w.samples) {
+		w.n++
+	}
+	w.mu.Unlock()
+}
+
+// percentile 样本数少于 min 时返回 false
+func (w *latencyWindow) percentile(p float64, min int) (time.Duration, bool) {
+	w.mu.Lock()
+	if w.n < min || w.n == 0 {
+		w.mu.Unlock()
+		return 0, false
+	}
+	s := append([]time.Duration(nil), w.samples[:w.n]...)
+	w.mu.Unlock()
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	i := int(p*float64(len(s))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(s) {
+		i = len(s) - 1
+	}
+	return s[i], true
+}
+
+// hedgeDelay 计算 host 的对冲阈值，返回 0 表示这次不对冲
+func (c *Client) hedgeDelay(host string) time.Duration {
+	p := c.cfg.Hedge
+	d := p.Delay
+	if p.Percentile > 0 {
+		min := p.MinSamples
+		if min <= 0 {
+			min = 20
+		}
+		if v, ok := c.latency(host).percentile(p.Percentile, min); ok && v > d {
+			d = v
+		}
+	}
+	return d
+}
+
+func (c *Client) latency(host string) *latencyWindow {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w, ok := c.latencies[host]
+	if !ok {
+		w = &latencyWindow{}
+		c.latencies[host] = w
+	}
+	return w
+}
+
+type hedgeResult struct {
+	index  int
+	resp   *http.Response
+	cancel context.CancelFunc
+	err    error
+}
+
+// hedged 按对冲策略发送请求，返回第一个成功的结果；都不成功时返回最后一个结果，交给调用方决定是否重试
+func (c *Client) hedged(ctx context.Context, req *http.Request, br *breaker, host string) (*http.Response, context.CancelFunc, error) {
+	delay := c.hedgeDelay(host)
+	if delay <= 0 {
+		return c.timed(ctx, req, br, host)
+	}
+	max := c.cfg.Hedge.MaxHedges
+	if max <= 0 {
+		max = 1
+	}
+
+	results := make(chan hedgeResult, max+1)
+	var cancels []context.CancelFunc
+	launch := func() {
+		hctx, hcancel := context.WithCancel(ctx)
+		index := len(cancels)
+		cancels = append(cancels, hcancel)
+		go func() {
+			resp, cancel, err := c.timed(hctx, req, br, host)
+			results <- hedgeResult{index: index, resp: resp, err: err, cancel: func() {
+				cancel()
+				hcancel()
+			}}
+		}()
+	}
+	launch()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	pending := 1
+	var last *hedgeResult
+	for {
+		select {
+		case r := <-results:
+			pending--
+			if success(r.resp, r.err) || pending == 0 {
+				if last != nil {
+					last.close()
+				}
+				// 取消其他还在进行的请求，后台回收它们的结果
+				for i, cancel := range cancels {
+					if i != r.index {
+						cancel()
+					}
+				}
+				go drain(results, pending)
+				return r.resp, r.cancel, r.err
+			}
+			// 还有请求没返回，先留着这个失败的结果
+			if last != nil {
+				last.close()
+			}
+			last = &r
+		case <-timer.C:
+			if len(cancels) <= max {
+				if hook := c.cfg.Hooks.OnHedge; hook != nil {
+					hook(host)
+				}
+				launch()
+				pending++
+				timer.Reset(delay)
+			}
+		}
+	}
+}
+
+// close 丢弃这个结果
+func (r *hedgeResult) close() {
+	if r.resp != nil {
+		// 读完剩余的 body，连接才能放回连接池
+		io.CopyN(ioutil.Discard, r.resp.Body, 4<<10)
+		r.resp.Body.Close()
+	}
+	r.cancel()
+}
+
+// timed 发送一次请求，成功时记录延迟
+func (c *Client) timed(ctx context.Context, req *http.Request, br *breaker, host string) (*http.Response, context.CancelFunc, error) {
+	start := time.Now()
+	resp, cancel, err := c.attempt(ctx, req, br)
+	if err == nil && resp.StatusCode < 500 && c.cfg.Hedge.Percentile > 0 {
+		c.latency(host).add(time.Since(start))
+	}
+	return resp, cancel, err
+}
+
+func drain(results <-chan hedgeResult, n int) {
+	for i := 0; i < n; i++ {
+		r := <-results
+		r.close()
+	}
+}
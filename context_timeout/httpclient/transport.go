@@ -0,0 +1,54 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// TransportConfig 连接池相关的配置
+type TransportConfig struct {
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+}
+
+// DefaultTransportConfig 默认配置，和 http.DefaultTransport 相比调大了每个 host 的空闲连接数
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		DialTimeout:         3 * time.Second,
+		KeepAlive:           30 * time.Second,
+		TLSHandshakeTimeout: 5 * time.Second,
+		IdleConnTimeout:     90 * time.Second,
+		MaxIdleConns:        200,
+		MaxIdleConnsPerHost: 50,
+	}
+}
+
+// NewTransport 创建开启长连接的 Transport，应该在进程内共享，不要每个请求创建一个
+func NewTransport(cfg TransportConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// DefaultTransport 没有指定 Transport 的 Client 共享这一个
+var DefaultTransport = NewTransport(DefaultTransportConfig())
@@ -1,18 +1,28 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"main/deadline"
+	"main/tracing"
 	"math/rand"
 	"net/http"
 	"time"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 )
 
 func indexHandler(w http.ResponseWriter, r *http.Request) {
-	rand.Seed(time.Now().UnixNano())
 	number := rand.Intn(2)
 	if number == 0 {
-		time.Sleep(time.Second * 10)
-		fmt.Fprintf(w, "slow response")
+		// 调用方已经放弃（超过了它传来的截止时间）就不用再等了
+		select {
+		case <-time.After(time.Second * 10):
+			fmt.Fprintf(w, "slow response")
+		case <-r.Context().Done():
+			http.Error(w, r.Context().Err().Error(), http.StatusGatewayTimeout)
+		}
 		return
 	}
 	fmt.Fprintf(w, "quick response")
@@ -21,9 +31,20 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 func main() {
 	rand.Seed(time.Now().UnixNano())
 
-	http.HandleFunc("/", indexHandler)
-	err := http.ListenAndServe(":8001", nil)
+	// span 打印到标准输出，生产环境换成 OTLP、Jaeger 等 exporter
+	exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+	if err != nil {
+		log.Fatal(err)
+	}
+	tp := tracing.Init("server", exporter)
+	defer tp.Shutdown(context.Background())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", indexHandler)
+	// 按客户端传来的剩余时间设置处理函数 ctx 的截止时间，最长 30 秒
+	// 从 traceparent 头取出 client 的链路，服务端 span 挂在对应的那次尝试下面
+	err = http.ListenAndServe(":8001", tracing.Handler("server", deadline.Middleware(30*time.Second, mux)))
 	if err != nil {
 		panic(err)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,99 @@
+// Package tracing 给 HTTP 客户端和服务端创建 OpenTelemetry span，通过 W3C traceparent 头串起整条链路
+//
+// Init 和 Transport 与 gin/default/tracing 相同，两个 demo 是独立的 module（都叫 main）不能互相引用，
+// 这里只保留 net/http 需要的部分，gin 写的服务端用那边的 Middleware 也能接上同一条链路。
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName 本包创建的 span 使用的 tracer 名称
+const instrumentationName = "main/tracing"
+
+// Init 创建 TracerProvider 并设置为全局，同时设置 W3C traceparent/baggage 传播
+// 程序退出前需要调用返回值的 Shutdown，把还没导出的 span 发送出去
+func Init(serviceName string, exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp
+}
+
+// Handler 为每个请求创建服务端 span，上游通过 traceparent 头传过来的链路会被延续
+// span 的 context 会替换 r 的 context，处理函数中用 r.Context() 发起的调用都会成为子 span
+func Handler(serverName string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(instrumentationName).Start(ctx, "HTTP "+r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(serverName, "", r)...),
+		)
+		defer span.End()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(sw.status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(sw.status, trace.SpanKindServer))
+	})
+}
+
+// statusWriter 记录响应的状态码
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Transport 给每次 RoundTrip 创建客户端 span，并注入 traceparent 头
+// 放在 httpclient 的重试和对冲下面时，每次尝试都是一个单独的 span
+type Transport struct {
+	// Base 默认 http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// NewTransport 包装 base
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{Base: base}
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ctx, span := otel.Tracer(instrumentationName).Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...),
+	)
+	// RoundTrip 不能修改传入的请求，克隆之后再注入头
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(resp.StatusCode, trace.SpanKindClient))
+	span.End()
+	return resp, nil
+}
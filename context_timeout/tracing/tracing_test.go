@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTransportPropagates(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := Init("test", exporter)
+	defer tp.Shutdown(context.Background())
+
+	// 下游的 Handler 从 traceparent 头中取出上游的 span
+	remote := make(chan trace.SpanContext, 1)
+	srv := httptest.NewServer(Handler("test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote <- trace.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusTeapot)
+	})))
+	defer srv.Close()
+
+	ctx, root := otel.Tracer("test").Start(context.Background(), "doCall")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := (&http.Client{Transport: NewTransport(nil)}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	root.End()
+	sc := <-remote
+	tp.ForceFlush(context.Background())
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	server, call := spans[0], spans[1]
+	if call.Name != "HTTP GET" || call.SpanKind != trace.SpanKindClient || call.Parent.SpanID() != root.SpanContext().SpanID() {
+		t.Errorf("unexpected client span: %+v", call)
+	}
+	if server.SpanKind != trace.SpanKindServer || server.Parent.SpanID() != call.SpanContext.SpanID() {
+		t.Errorf("unexpected server span: %+v", server)
+	}
+	if sc.TraceID() != root.SpanContext().TraceID() || sc.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("handler got %v, want span %v", sc, server.SpanContext)
+	}
+	for _, kv := range server.Attributes {
+		if kv.Key == "http.status_code" && kv.Value.AsInt64() != http.StatusTeapot {
+			t.Errorf("server span status = %v", kv.Value.Emit())
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	j := New([]byte("secret"))
+	r := gin.New()
+	r.GET("/home", j.Middleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(UserKey))
+	})
+
+	token, err := j.GenToken("jason")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// none 算法签名的 token
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, Claims{Username: "admin"}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	expired := &JWT{Secret: j.Secret, Issuer: j.Issuer, TTL: -time.Minute}
+	old, _ := expired.GenToken("jason")
+	other, _ := New([]byte("other")).GenToken("jason")
+
+	for _, tc := range []struct {
+		header string
+		code   int
+		body   string
+	}{
+		{"Bearer " + token, http.StatusOK, "jason"},
+		{"bearer " + token, http.StatusOK, "jason"},
+		{"", http.StatusUnauthorized, ""},
+		{"Token " + token, http.StatusUnauthorized, ""},
+		{"Bearer " + none, http.StatusUnauthorized, ""},
+		{"Bearer " + old, http.StatusUnauthorized, ""},
+		{"Bearer " + other, http.StatusUnauthorized, ""},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/home", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		r.ServeHTTP(w, req)
+		if w.Code != tc.code || (tc.body != "" && w.Body.String() != tc.body) {
+			t.Errorf("%q: code = %d, body = %s", tc.header, w.Code, w.Body)
+		}
+	}
+}
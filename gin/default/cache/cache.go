@@ -0,0 +1,277 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Entry 缓存的一个响应
+type Entry struct {
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	ETag         string      `json:"etag"`
+	LastModified time.Time   `json:"last_modified"`
+	Created      time.Time   `json:"created"`
+}
+
+// Store 缓存存储
+type Store interface {
+	// Get 没有命中（包括已过期）时返回 nil, nil
+	Get(ctx context.Context, key string) (*Entry, error)
+	Set(ctx context.Context, key string, e *Entry, ttl time.Duration) error
+	// DeletePrefix 删除以 prefix 开头的所有 key，返回删除的数量
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+}
+
+// Config 缓存配置
+type Config struct {
+	Store Store
+	// TTL 缓存时间，默认 1 分钟
+	TTL time.Duration
+	// VaryHeaders 参与缓存 key 的请求头，例如 Accept、Accept-Language
+	// 需要按用户区分的接口要加上 Authorization，否则会把一个用户的数据返回给另一个用户
+	VaryHeaders []string
+	// Prefix 缓存 key 的前缀，同一个 Store 给多个服务使用时区分开
+	Prefix string
+}
+
+// Cache 响应缓存
+// key 的格式为 Prefix + 路径 + ? + 排好序的查询参数 + 请求方法 + VaryHeaders，所以可以按路径前缀失效
+type Cache struct {
+	cfg Config
+	now func() time.Time
+}
+
+// New 创建响应缓存
+func New(cfg Config) *Cache {
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	// 复制一份，不修改调用方的切片
+	vary := make([]string, len(cfg.VaryHeaders))
+	for i, h := range cfg.VaryHeaders {
+		vary[i] = http.CanonicalHeaderKey(h)
+	}
+	cfg.VaryHeaders = vary
+	return &Cache{cfg: cfg, now: time.Now}
+}
+
+// Key 请求对应的缓存 key
+func (ca *Cache) Key(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(ca.cfg.Prefix)
+	b.WriteString(r.URL.Path)
+	b.WriteByte('?')
+	// 参数顺序不同的请求使用同一份缓存
+	b.WriteString(r.URL.Query().Encode())
+	b.WriteByte('|')
+	b.WriteString(r.Method)
+	for _, h := range ca.cfg.VaryHeaders {
+		b.WriteByte('|')
+		b.WriteString(h)
+		b.WriteByte('=')
+		b.WriteString(strings.Join(r.Header.Values(h), ","))
+	}
+	return b.String()
+}
+
+// Invalidate 删除 pathPrefix 以及它下面的路径的缓存，例如写接口成功后调用 Invalidate(ctx, "/book")
+// 会删除 /book、/book?id=1 和 /book/1，只在路径分隔处匹配，不会删除 /bookstore
+func (ca *Cache) Invalidate(ctx context.Context, pathPrefix string) (int, error) {
+	p := ca.cfg.Prefix + strings.TrimSuffix(pathPrefix, "/")
+	// key 中路径后面总是跟着 ?，子路径以 / 开头
+	n, err := ca.cfg.Store.DeletePrefix(ctx, p+"?")
+	if err != nil {
+		return n, err
+	}
+	m, err := ca.cfg.Store.DeletePrefix(ctx, p+"/")
+	return n + m, err
+}
+
+// Middleware 缓存 GET、HEAD 请求的 200 响应，自动生成 ETag 并处理 If-None-Match、If-Modified-Since
+// 响应会先完整缓冲在内存中，不要用在文件下载、SSE 这样的流式接口上
+// 设置了 Set-Cookie 或者 Cache-Control: no-store/private 的响应不会缓存
+// Store 出错时不影响请求，错误记录在 c.Errors 中
+func (ca *Cache) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+		key := ca.Key(c.Request)
+		if cc := c.GetHeader("Cache-Control"); !strings.Contains(cc, "no-cache") && !strings.Contains(cc, "no-store") {
+			e, err := ca.cfg.Store.Get(c.Request.Context(), key)
+			if err != nil {
+				c.Error(err)
+			}
+			if e != nil {
+				ca.serve(c, e, "HIT")
+				c.Abort()
+				return
+			}
+		}
+
+		orig := c.Writer
+		// 前面的中间件设置的头（CORS、X-Request-ID 等）和具体请求有关，只缓存处理函数设置的头
+		before := orig.Header().Clone()
+		w := &bufferWriter{ResponseWriter: orig, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = orig
+
+		if w.status != http.StatusOK || !cacheable(orig.Header()) {
+			orig.WriteHeader(w.status)
+			orig.WriteHeaderNow()
+			orig.Write(w.body.Bytes())
+			return
+		}
+		e := ca.entry(headerDiff(before, orig.Header()), w.body.Bytes())
+		if err := ca.cfg.Store.Set(c.Request.Context(), key, e, ca.cfg.TTL); err != nil {
+			c.Error(err)
+		}
+		ca.serve(c, e, "MISS")
+	}
+}
+
+func (ca *Cache) entry(header http.Header, body []byte) *Entry {
+	now := ca.now().UTC().Truncate(time.Second)
+	e := &Entry{Status: http.StatusOK, Header: header, Body: body, Created: now}
+	// 处理函数自己设置了 ETag、Last-Modified 的话以它为准
+	if e.ETag = header.Get("ETag"); e.ETag == "" {
+		sum := sha1.Sum(body)
+		e.ETag = `"` + hex.EncodeToString(sum[:10]) + `"`
+	}
+	e.LastModified = now
+	if lm, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		e.LastModified = lm
+	}
+	return e
+}
+
+// serve 输出缓存的响应，条件请求命中时返回 304
+func (ca *Cache) serve(c *gin.Context, e *Entry, state string) {
+	h := c.Writer.Header()
+	for k, vs := range e.Header {
+		h[k] = vs
+	}
+	ca.vary(h)
+	h.Set("ETag", e.ETag)
+	h.Set("Last-Modified", e.LastModified.UTC().Format(http.TimeFormat))
+	h.Set("X-Cache", state)
+	if state == "HIT" {
+		h.Set("Age", strconv.Itoa(int(ca.now().Sub(e.Created)/time.Second)))
+	}
+	if notModified(c.Request, e) {
+		// 304 不能带正文相关的头
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Status(e.Status)
+	if c.Request.Method == http.MethodHead {
+		h.Set("Content-Length", strconv.Itoa(len(e.Body)))
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Writer.Write(e.Body)
+}
+
+// vary 把参与缓存 key 的请求头加到 Vary 中，下游的代理和浏览器缓存才会按同样的规则区分
+func (ca *Cache) vary(h http.Header) {
+	if len(ca.cfg.VaryHeaders) == 0 {
+		return
+	}
+	seen := make(map[string]bool)
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			seen[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
+	for _, name := range ca.cfg.VaryHeaders {
+		if !seen[name] {
+			h.Add("Vary", name)
+			seen[name] = true
+		}
+	}
+}
+
+// notModified If-None-Match 优先于 If-Modified-Since
+func notModified(r *http.Request, e *Entry) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			// GET 使用弱比较，W/"x" 和 "x" 视为相同
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(e.ETag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !e.LastModified.Truncate(time.Second).After(ims)
+}
+
+// headerDiff 返回 after 中新增或者修改了的头
+func headerDiff(before, after http.Header) http.Header {
+	diff := make(http.Header)
+	for k, vs := range after {
+		if strings.Join(before[k], "\n") != strings.Join(vs, "\n") {
+			diff[k] = append([]string(nil), vs...)
+		}
+	}
+	return diff
+}
+
+func cacheable(h http.Header) bool {
+	if h.Get("Set-Cookie") != "" {
+		return false
+	}
+	cc := strings.ToLower(h.Get("Cache-Control"))
+	return !strings.Contains(cc, "no-store") && !strings.Contains(cc, "private")
+}
+
+// bufferWriter 缓冲处理函数的输出，生成 ETag 之后再写给客户端
+type bufferWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferWriter) WriteHeaderNow() {}
+
+func (w *bufferWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferWriter) Written() bool {
+	return w.body.Len() > 0
+}
@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestMemoryStoreLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	m := NewMemoryStore(2)
+	m.now = func() time.Time { return now }
+
+	m.Set(ctx, "a", &Entry{}, time.Minute)
+	m.Set(ctx, "b", &Entry{}, time.Minute)
+	m.Get(ctx, "a") // a 变为最近访问
+	m.Set(ctx, "c", &Entry{}, time.Minute)
+	if e, _ := m.Get(ctx, "b"); e != nil {
+		t.Fatal("b should be evicted")
+	}
+	if e, _ := m.Get(ctx, "a"); e == nil {
+		t.Fatal("a should still be cached")
+	}
+	now = now.Add(time.Minute)
+	if e, _ := m.Get(ctx, "a"); e != nil {
+		t.Fatal("a should be expired")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	store := NewMemoryStore(0)
+	ca := New(Config{Store: store, TTL: time.Minute})
+	calls := 0
+	r := gin.New()
+	r.Use(ca.Middleware())
+	r.GET("/book", func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusOK, gin.H{"message": "GET"})
+	})
+	r.GET("/book/:id", func(c *gin.Context) {
+		calls++
+		c.String(http.StatusOK, c.Param("id"))
+	})
+	r.GET("/bookstore", func(c *gin.Context) {
+		calls++
+		c.String(http.StatusOK, "bookstore")
+	})
+	r.GET("/posts/index", func(c *gin.Context) {
+		calls++
+		c.SetCookie("session", "x", 60, "/", "", false, true)
+		c.String(http.StatusOK, "posts")
+	})
+
+	do := func(path string, header ...string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/book?b=2&a=1")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "MISS" || etag == "" {
+		t.Fatalf("first request: %d %v", w.Code, w.Header())
+	}
+	// 参数顺序不同也能命中
+	w = do("/book?a=1&b=2")
+	if w.Header().Get("X-Cache") != "HIT" || w.Body.String() != `{"message":"GET"}` || w.Header().Get("Content-Type") == "" {
+		t.Fatalf("second request: %v %q", w.Header(), w.Body.String())
+	}
+	w = do("/book?a=1&b=2", "If-None-Match", etag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("If-None-Match: %d %q", w.Code, w.Body.String())
+	}
+	w = do("/book?a=1&b=2", "If-Modified-Since", w.Header().Get("Last-Modified"))
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("If-Modified-Since: %d", w.Code)
+	}
+	if calls != 1 {
+		t.Fatalf("handler called %d times", calls)
+	}
+
+	do("/book/1")
+	do("/book/2")
+	do("/bookstore")
+	// 只在路径分隔处匹配，/bookstore 不受影响
+	if n, err := ca.Invalidate(context.Background(), "/book"); err != nil || n != 3 {
+		t.Fatalf("Invalidate = %d, %v", n, err)
+	}
+	if w = do("/book?a=1&b=2"); w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("after invalidate: %v", w.Header())
+	}
+	if w = do("/bookstore"); w.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("/bookstore invalidated: %v", w.Header())
+	}
+
+	// 带 Set-Cookie 的响应不缓存
+	do("/posts/index")
+	if w = do("/posts/index"); w.Header().Get("X-Cache") != "" || calls != 7 {
+		t.Fatalf("set-cookie response cached: %v calls=%d", w.Header(), calls)
+	}
+}
+
+func TestEscapeGlob(t *testing.T) {
+	if got := escapeGlob("cache:/book?a=[1]*"); got != `cache:/book\?a=\[1\]\*` {
+		t.Fatalf("escapeGlob = %q", got)
+	}
+}
+
+func TestVaryHeaders(t *testing.T) {
+	vary := []string{"accept-language"}
+	ca := New(Config{Store: NewMemoryStore(0), VaryHeaders: vary})
+	if vary[0] != "accept-language" {
+		t.Fatalf("New modified VaryHeaders: %v", vary)
+	}
+	r := gin.New()
+	r.Use(ca.Middleware())
+	r.GET("/hello", func(c *gin.Context) {
+		c.Header("Vary", "Accept-Encoding")
+		c.String(http.StatusOK, "hello "+c.GetHeader("Accept-Language"))
+	})
+
+	for _, state := range []string{"MISS", "HIT"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+		req.Header.Set("Accept-Language", "zh")
+		r.ServeHTTP(w, req)
+		if got := w.Header().Values("Vary"); w.Header().Get("X-Cache") != state || len(got) != 2 || got[0] != "Accept-Encoding" || got[1] != "Accept-Language" {
+			t.Fatalf("%s: Vary = %v, headers = %v", state, got, w.Header())
+		}
+	}
+}
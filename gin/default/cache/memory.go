@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+type lruItem struct {
+	key     string
+	entry   *Entry
+	expires time.Time
+}
+
+// MemoryStore 进程内的 LRU 缓存，超过 MaxEntries 时淘汰最久没有访问的条目
+type MemoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+	now        func() time.Time
+}
+
+// NewMemoryStore 创建内存 Store，maxEntries <= 0 时默认 1000
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// Get 实现 Store
+func (m *MemoryStore) Get(ctx context.Context, key string) (*Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.items[key]
+	if !ok {
+		return nil, nil
+	}
+	it := el.Value.(*lruItem)
+	if !m.now().Before(it.expires) {
+		m.remove(el)
+		return nil, nil
+	}
+	m.ll.MoveToFront(el)
+	return it.entry, nil
+}
+
+// Set 实现 Store
+func (m *MemoryStore) Set(ctx context.Context, key string, e *Entry, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expires := m.now().Add(ttl)
+	if el, ok := m.items[key]; ok {
+		it := el.Value.(*lruItem)
+		it.entry, it.expires = e, expires
+		m.ll.MoveToFront(el)
+		return nil
+	}
+	m.items[key] = m.ll.PushFront(&lruItem{key: key, entry: e, expires: expires})
+	for m.ll.Len() > m.maxEntries {
+		m.remove(m.ll.Back())
+	}
+	return nil
+}
+
+// DeletePrefix 实现 Store
+func (m *MemoryStore) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for key, el := range m.items {
+		if strings.HasPrefix(key, prefix) {
+			m.remove(el)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Len 当前的条目数，包括已过期但还没有被淘汰的
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ll.Len()
+}
+
+func (m *MemoryStore) remove(el *list.Element) {
+	m.ll.Remove(el)
+	delete(m.items, el.Value.(*lruItem).key)
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisStore 缓存保存在 Redis 中，多个实例共享，过期由 Redis 的 TTL 处理
+type RedisStore struct {
+	rdb    redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore 创建 Redis Store，prefix 为空时默认 "cache:"
+func NewRedisStore(rdb redis.UniversalClient, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = "cache:"
+	}
+	return &RedisStore{rdb: rdb, prefix: prefix}
+}
+
+// Get 实现 Store
+func (s *RedisStore) Get(ctx context.Context, key string) (*Entry, error) {
+	b, err := s.rdb.Get(ctx, s.prefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var e Entry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Set 实现 Store
+func (s *RedisStore) Set(ctx context.Context, key string, e *Entry, ttl time.Duration) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, s.prefix+key, b, ttl).Err()
+}
+
+// DeletePrefix 实现 Store，使用 SCAN 分批查找，不会像 KEYS 一样阻塞 Redis
+// 集群模式下需要在每个主节点上执行，这里只处理单机和哨兵
+func (s *RedisStore) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	match := escapeGlob(s.prefix+prefix) + "*"
+	n := 0
+	var cursor uint64
+	for {
+		keys, next, err := s.rdb.Scan(ctx, cursor, match, 500).Result()
+		if err != nil {
+			return n, err
+		}
+		if len(keys) > 0 {
+			deleted, err := s.rdb.Del(ctx, keys...).Result()
+			if err != nil {
+				return n, err
+			}
+			n += int(deleted)
+		}
+		if next == 0 {
+			return n, nil
+		}
+		cursor = next
+	}
+}
+
+// escapeGlob 转义 SCAN MATCH 的通配符，路径和查询参数中可能出现 ? * [ ]
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
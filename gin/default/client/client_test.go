@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGeneratedClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/loginJSON", func(c *gin.Context) {
+		var login Login
+		if err := c.ShouldBindJSON(&login); err != nil || login.Password == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "password is required"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"user": login.User})
+	})
+	r.GET("/user/search/:username/:address", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "ok", "username": c.Param("username"), "address": c.Param("address")})
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	cli := New(srv.URL+"/", nil)
+	ctx := context.Background()
+	user, err := cli.PostLoginJSON(ctx, &Login{User: "jason", Password: "123"})
+	if err != nil || user.User != "jason" {
+		t.Fatalf("user = %+v, err = %v", user, err)
+	}
+	// path 参数需要转义
+	result, err := cli.GetUserSearchUsernameAddress(ctx, "小 王子", "沙河")
+	if err != nil || result.Username != "小 王子" || result.Address != "沙河" {
+		t.Fatalf("result = %+v, err = %v", result, err)
+	}
+
+	_, err = cli.PostLoginJSON(ctx, &Login{User: "jason"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "password is required" {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestValues(t *testing.T) {
+	type embedded struct {
+		Tags []string `form:"tag"`
+	}
+	v := values(&struct {
+		embedded
+		Page    int    `form:"page"`
+		Keyword string `form:"q"`
+		Skip    string `form:"-"`
+		Name    string
+		Empty   string `form:"empty"`
+	}{embedded: embedded{Tags: []string{"a", "b"}}, Page: 2, Keyword: "go", Skip: "x", Name: "n"}, "form")
+	if got := v.Encode(); got != "Name=n&page=2&q=go&tag=a&tag=b" {
+		t.Fatalf("values = %s", got)
+	}
+}
@@ -0,0 +1,585 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// gen 生成客户端代码
+type gen struct {
+	p *pkg
+	// decls 按出现顺序生成的类型定义
+	decls   []string
+	named   map[*ast.TypeSpec]string
+	used    map[string]bool
+	imports map[string]string
+}
+
+func newGen(p *pkg) *gen {
+	g := &gen{p: p, named: make(map[*ast.TypeSpec]string), used: make(map[string]bool), imports: make(map[string]string)}
+	for _, name := range []string{"Client", "APIError", "New"} {
+		g.used[name] = true
+	}
+	return g
+}
+
+// generate 生成 pkgName 包的源码，source 写在文件头的注释里
+func generate(p *pkg, eps []endpoint, pkgName, source string) ([]byte, error) {
+	g := newGen(p)
+	var methods bytes.Buffer
+	names := make(map[string]bool)
+	for _, ep := range eps {
+		name := uniqueName(names, exportName(operationID(ep.Method, ep.Path)))
+		g.method(&methods, name, ep)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by ginclient from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&out, "// Package %s 调用 gin 服务的客户端，由 ginclient 根据路由和参数绑定结构体生成\n", pkgName)
+	fmt.Fprintf(&out, "package %s\n\n", pkgName)
+	out.WriteString("import (\n")
+	imports := map[string]string{}
+	for _, path := range runtimeImports {
+		imports[path] = ""
+	}
+	for path, name := range g.imports {
+		imports[path] = name
+	}
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(&out, "\t%s %q\n", imports[path], path)
+	}
+	out.WriteString(")\n")
+	out.WriteString(runtime)
+	for _, decl := range g.decls {
+		out.WriteString("\n")
+		out.WriteString(decl)
+		out.WriteString("\n")
+	}
+	out.Write(methods.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return out.Bytes(), fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+// method 生成一个接口的方法
+func (g *gen) method(w *bytes.Buffer, name string, ep endpoint) {
+	var (
+		params  []string
+		pathSrc = g.pathExpr(ep.Path, &params)
+		args    = []string{"ctx context.Context"}
+		prepare []string
+		query   = "nil"
+		ctype   = `""`
+		body    = "nil"
+	)
+	if len(params) > 0 {
+		args = append(args, strings.Join(params, ", ")+" string")
+	}
+
+	in := ep.BindIn
+	if in == "auto" {
+		// ShouldBind 对 GET 请求读 query，其他请求按 Content-Type 绑定，这里统一发 JSON
+		in = "json"
+		if ep.Method == "GET" || ep.Method == "HEAD" || ep.Method == "DELETE" {
+			in = "query"
+		}
+	}
+	switch {
+	case ep.Bind != nil:
+		reqType := g.typeSrc(ep.Bind, ep.scope)
+		args = append(args, "req *"+reqType)
+		switch in {
+		case "query":
+			query = `values(req, "form")`
+		case "json":
+			ctype = `"application/json"`
+			body = "data"
+			prepare = append(prepare, "data, err := json.Marshal(req)", "if err != nil {", "return %s, err", "}")
+		}
+	case len(ep.Query) > 0 || len(ep.Form) > 0:
+		reqType := g.looseRequest(name, ep)
+		args = append(args, "req *"+reqType)
+		if len(ep.Query) > 0 {
+			query = `values(req, "query")`
+		}
+		if len(ep.Form) > 0 {
+			ctype = `"application/x-www-form-urlencoded"`
+			body = `[]byte(values(req, "form").Encode())`
+		}
+	}
+
+	ret := g.responseType(name, ep)
+	summary := ep.Method + " " + ep.Path
+	fmt.Fprintf(w, "\n// %s %s\n", name, summary)
+	if ep.Doc != "" {
+		w.WriteString("//\n")
+		for _, line := range strings.Split(ep.Doc, "\n") {
+			fmt.Fprintf(w, "// %s\n", line)
+		}
+	}
+	fmt.Fprintf(w, "func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), ret)
+	for _, line := range prepare {
+		if strings.Contains(line, "%s") {
+			line = fmt.Sprintf(line, zeroValue(ret))
+		}
+		w.WriteString(line + "\n")
+	}
+	call := fmt.Sprintf("c.do(ctx, %q, %s, %s, %s, %s, &out)", ep.Method, pathSrc, query, ctype, body)
+	if strings.HasPrefix(ret, "*") {
+		fmt.Fprintf(w, "var out %s\n", ret[1:])
+		fmt.Fprintf(w, "if err := %s; err != nil {\nreturn nil, err\n}\nreturn &out, nil\n}\n", call)
+		return
+	}
+	fmt.Fprintf(w, "var out %s\n", ret)
+	fmt.Fprintf(w, "if err := %s; err != nil {\nreturn %s, err\n}\nreturn out, nil\n}\n", call, zeroValue(ret))
+}
+
+func zeroValue(typ string) string {
+	switch {
+	case strings.HasPrefix(typ, "*"), strings.HasPrefix(typ, "[]"), strings.HasPrefix(typ, "map["), typ == "json.RawMessage", typ == "interface{}":
+		return "nil"
+	case typ == "string":
+		return `""`
+	case typ == "bool":
+		return "false"
+	case basicTypes[typ]:
+		return "0"
+	}
+	return typ + "{}"
+}
+
+// pathExpr 把 /user/:name/*path 变成拼接字符串的表达式，params 返回参数名
+func (g *gen) pathExpr(path string, params *[]string) string {
+	var parts []string
+	static := ""
+	for _, seg := range strings.SplitAfter(path, "/") {
+		if seg == "" || (seg[0] != ':' && seg[0] != '*') {
+			static += seg
+			continue
+		}
+		slash := strings.HasSuffix(seg, "/")
+		name := goIdent(strings.TrimSuffix(seg[1:], "/"))
+		*params = append(*params, name)
+		parts = append(parts, strconv.Quote(static))
+		static = ""
+		if seg[0] == '*' {
+			parts = append(parts, "escapePath("+name+")")
+		} else {
+			parts = append(parts, "url.PathEscape("+name+")")
+		}
+		if slash {
+			static = "/"
+		}
+	}
+	if static != "" || len(parts) == 0 {
+		parts = append(parts, strconv.Quote(static))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// responseType 方法的返回值类型，结构体返回指针，推断不出来时返回原始的 body
+func (g *gen) responseType(name string, ep endpoint) string {
+	if ep.Response == nil {
+		return "[]byte"
+	}
+	if keys, values, ok := ginH(ep.Response); ok {
+		typeName := g.reserve(name + "Response")
+		g.decls = append(g.decls, fmt.Sprintf("// %s %s 的响应\ntype %s %s", typeName, name, typeName, g.structOf(keys, values, ep.respScope)))
+		return "*" + typeName
+	}
+	src := g.valueType(ep.Response, ep.respScope)
+	switch {
+	case src == "interface{}":
+		return "json.RawMessage"
+	case strings.HasPrefix(src, "*"), strings.HasPrefix(src, "[]"), strings.HasPrefix(src, "map["), strings.HasPrefix(src, "struct"):
+		return src
+	case g.isStruct(src):
+		return "*" + src
+	}
+	return src
+}
+
+func (g *gen) isStruct(name string) bool {
+	for spec, n := range g.named {
+		if n == name {
+			_, ok := spec.Type.(*ast.StructType)
+			return ok
+		}
+	}
+	return false
+}
+
+// looseRequest 为 c.Query、c.PostForm 读取的参数生成请求结构体
+func (g *gen) looseRequest(name string, ep endpoint) string {
+	typeName := g.reserve(name + "Request")
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s %s 的参数，零值不发送\ntype %s struct {\n", typeName, name, typeName)
+	fields := make(map[string]bool)
+	for _, q := range ep.Query {
+		fmt.Fprintf(&b, "%s string `query:%q`\n", uniqueName(fields, goName(q)), q)
+	}
+	for _, f := range ep.Form {
+		fmt.Fprintf(&b, "%s string `form:%q`\n", uniqueName(fields, goName(f)), f)
+	}
+	b.WriteString("}")
+	g.decls = append(g.decls, b.String())
+	return typeName
+}
+
+// typeSrc 把类型表达式转换成生成代码中的类型
+func (g *gen) typeSrc(e ast.Expr, sc *scope) string {
+	switch e := e.(type) {
+	case *scopedType:
+		return g.typeSrc(e.Expr, e.scope)
+	case *ast.ParenExpr:
+		return g.typeSrc(e.X, sc)
+	case *ast.Ident:
+		switch {
+		case basicTypes[e.Name]:
+			return e.Name
+		case e.Name == "any":
+			return "interface{}"
+		}
+		if td, ok := sc.lookupType(e.Name); ok {
+			return g.declare(td, sc)
+		}
+		if td, ok := g.p.types[e.Name]; ok {
+			return g.declare(td, newScope(nil, td.file))
+		}
+	case *ast.StarExpr:
+		return "*" + g.typeSrc(e.X, sc)
+	case *ast.ArrayType:
+		if lit, ok := e.Len.(*ast.BasicLit); ok {
+			return "[" + lit.Value + "]" + g.typeSrc(e.Elt, sc)
+		}
+		return "[]" + g.typeSrc(e.Elt, sc)
+	case *ast.MapType:
+		return "map[" + g.typeSrc(e.Key, sc) + "]" + g.typeSrc(e.Value, sc)
+	case *ast.StructType:
+		return g.structType(e, sc)
+	case *ast.SelectorExpr:
+		return g.qualified(e, sc)
+	}
+	return "interface{}"
+}
+
+// qualified 其他包的类型，只保留标准库的类型，其他的用 json.RawMessage 原样保留
+func (g *gen) qualified(e *ast.SelectorExpr, sc *scope) string {
+	x, ok := e.X.(*ast.Ident)
+	if !ok {
+		return "interface{}"
+	}
+	if x.Name == "gin" && e.Sel.Name == "H" {
+		return "map[string]interface{}"
+	}
+	if sc == nil || sc.file == nil {
+		return "json.RawMessage"
+	}
+	for _, imp := range sc.file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := path[strings.LastIndex(path, "/")+1:]
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if name != x.Name {
+			continue
+		}
+		if strings.Contains(strings.Split(path, "/")[0], ".") || strings.HasPrefix(path, "main/") {
+			break
+		}
+		if imp.Name != nil {
+			g.imports[path] = name
+		} else if _, ok := g.imports[path]; !ok {
+			g.imports[path] = ""
+		}
+		return x.Name + "." + e.Sel.Name
+	}
+	return "json.RawMessage"
+}
+
+// declare 生成类型定义，返回导出后的类型名
+func (g *gen) declare(td typeDecl, sc *scope) string {
+	if name, ok := g.named[td.spec]; ok {
+		return name
+	}
+	name := g.reserve(exportName(td.spec.Name.Name))
+	g.named[td.spec] = name
+	// 先占位，保证类型定义的顺序和依赖无关
+	index := len(g.decls)
+	g.decls = append(g.decls, "")
+	body := g.typeSrc(td.spec.Type, sc)
+
+	var b strings.Builder
+	if td.doc != nil {
+		lines := strings.Split(strings.TrimSpace(td.doc.Text()), "\n")
+		// 注释以原来的类型名开头时换成导出后的名字
+		if strings.HasPrefix(lines[0], td.spec.Name.Name) {
+			lines[0] = name + strings.TrimPrefix(lines[0], td.spec.Name.Name)
+		}
+		for _, line := range lines {
+			fmt.Fprintf(&b, "// %s\n", line)
+		}
+	} else {
+		fmt.Fprintf(&b, "// %s 对应服务端的 %s\n", name, td.spec.Name.Name)
+	}
+	fmt.Fprintf(&b, "type %s %s", name, body)
+	g.decls[index] = b.String()
+	return name
+}
+
+func (g *gen) structType(st *ast.StructType, sc *scope) string {
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	for _, field := range st.Fields.List {
+		tag := ""
+		if field.Tag != nil {
+			tag = filterTag(field.Tag.Value)
+		}
+		typ := g.typeSrc(field.Type, sc)
+		if len(field.Names) == 0 {
+			// 嵌入的字段只保留能生成出来的类型
+			if typ != "interface{}" && typ != "json.RawMessage" {
+				fmt.Fprintf(&b, "%s %s\n", typ, tag)
+			}
+			continue
+		}
+		for _, name := range field.Names {
+			if name.IsExported() {
+				fmt.Fprintf(&b, "%s %s %s\n", name.Name, typ, tag)
+			}
+		}
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// filterTag 只保留和序列化有关的 tag
+func filterTag(lit string) string {
+	raw, err := strconv.Unquote(lit)
+	if err != nil {
+		return ""
+	}
+	tag := reflect.StructTag(raw)
+	var parts []string
+	for _, key := range []string{"json", "form", "uri", "xml"} {
+		if v, ok := tag.Lookup(key); ok {
+			parts = append(parts, fmt.Sprintf("%s:%q", key, v))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "`" + strings.Join(parts, " ") + "`"
+}
+
+// ginH 判断是否是 gin.H{"k": v} 这样 key 都是字符串的字面量
+func ginH(e ast.Expr) (keys []string, values []ast.Expr, ok bool) {
+	lit, ok := e.(*ast.CompositeLit)
+	if !ok {
+		return nil, nil, false
+	}
+	switch t := lit.Type.(type) {
+	case *ast.SelectorExpr:
+		if t.Sel.Name != "H" {
+			return nil, nil, false
+		}
+	case *ast.MapType:
+		if id, ok := t.Key.(*ast.Ident); !ok || id.Name != "string" {
+			return nil, nil, false
+		}
+	default:
+		return nil, nil, false
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, nil, false
+		}
+		key, ok := stringLit(kv.Key)
+		if !ok {
+			return nil, nil, false
+		}
+		keys = append(keys, key)
+		values = append(values, kv.Value)
+	}
+	return keys, values, true
+}
+
+func (g *gen) structOf(keys []string, values []ast.Expr, sc *scope) string {
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	fields := make(map[string]bool)
+	for i, key := range keys {
+		fmt.Fprintf(&b, "%s %s `json:%q`\n", uniqueName(fields, goName(key)), g.valueType(values[i], sc), key)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// valueType 推断 JSON 输出的值的类型
+func (g *gen) valueType(e ast.Expr, sc *scope) string {
+	if keys, values, ok := ginH(e); ok {
+		return g.structOf(keys, values, sc)
+	}
+	if name := basicType(e); name != "" {
+		return name
+	}
+	if sel, ok := e.(*ast.SelectorExpr); ok {
+		if t, fsc := g.fieldType(sel, sc); t != nil {
+			return g.typeSrc(t, fsc)
+		}
+	}
+	if t := g.p.exprType(e, sc); t != nil {
+		return g.typeSrc(t, sc)
+	}
+	return "interface{}"
+}
+
+// fieldType x.F 中 F 字段的类型，x 是类型已知的局部变量
+func (g *gen) fieldType(e *ast.SelectorExpr, sc *scope) (ast.Expr, *scope) {
+	x, ok := e.X.(*ast.Ident)
+	if !ok {
+		return nil, nil
+	}
+	t := sc.lookupVar(x.Name)
+	if t == nil {
+		return nil, nil
+	}
+	tsc := sc
+	t = deref(t)
+	if st, ok := t.(*scopedType); ok {
+		t, tsc = st.Expr, st.scope
+	}
+	id, ok := t.(*ast.Ident)
+	if !ok {
+		return nil, nil
+	}
+	td, ok := tsc.lookupType(id.Name)
+	if !ok {
+		if td, ok = g.p.types[id.Name]; !ok {
+			return nil, nil
+		}
+		tsc = newScope(nil, td.file)
+	}
+	st, ok := td.spec.Type.(*ast.StructType)
+	if !ok {
+		return nil, nil
+	}
+	for _, field := range st.Fields.List {
+		for _, name := range field.Names {
+			if name.Name == e.Sel.Name {
+				return field.Type, tsc
+			}
+		}
+	}
+	return nil, nil
+}
+
+var basicTypes = map[string]bool{
+	"bool": true, "string": true, "error": false, "byte": true, "rune": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"float32": true, "float64": true, "complex64": true, "complex128": true,
+}
+
+// reserve 占用一个类型名，重复时加数字后缀
+func (g *gen) reserve(name string) string {
+	return uniqueName(g.used, name)
+}
+
+func uniqueName(used map[string]bool, name string) string {
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = name + strconv.Itoa(i)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// operationID 和 openapi 包的规则一致，例如 GET /user/search/:username/:address 为 getUserSearchUsernameAddress
+func operationID(method, p string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	upper := true
+	for _, r := range p {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func exportName(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// initialisms 按 Go 的习惯全部大写的缩写
+var initialisms = map[string]bool{"id": true, "url": true, "ip": true, "api": true, "http": true, "json": true, "uri": true}
+
+// goName 把 JSON 的 key（例如 user_id）转换成字段名（UserID）
+func goName(key string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if initialisms[strings.ToLower(part)] {
+			b.WriteString(strings.ToUpper(part))
+		} else {
+			b.WriteString(exportName(part))
+		}
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "F" + name
+	}
+	return name
+}
+
+// goIdent 路由参数名作为方法参数名
+func goIdent(s string) string {
+	name := goName(s)
+	if strings.ToUpper(name) == name {
+		name = strings.ToLower(name)
+	} else {
+		r := []rune(name)
+		r[0] = unicode.ToLower(r[0])
+		name = string(r)
+	}
+	// 不能和关键字以及生成的方法里用到的变量重名
+	switch name {
+	case "ctx", "req", "out", "data", "err", "c", "url", "json", "values", "escapePath":
+		name += "Param"
+	default:
+		if token.Lookup(name).IsKeyword() {
+			name += "Param"
+		}
+	}
+	return name
+}
@@ -0,0 +1,78 @@
+// ginclient 根据 gin 服务的路由注册和参数绑定结构体生成带类型的 Go 客户端
+//
+// 用法：
+//
+//	go run ./cmd/ginclient -func apiDocs -pkg client -o client/client_gen.go .
+//
+// 只分析源码，不需要运行服务：找出 r.GET、r.Group、r.Handle 以及 openapi 的 doc.Handle 注册的路由，
+// 从处理函数中的 ShouldBind*、c.Query、c.PostForm 得到参数，从 c.JSON(http.StatusOK, ...) 推断响应的结构。
+// 推断不出来的响应返回原始的 body，非 2xx 的响应解析成 *APIError。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	pkgName := flag.String("pkg", "client", "package name of the generated code")
+	output := flag.String("o", "", "output file, empty means stdout")
+	funcs := flag.String("func", "", "comma separated functions to collect routes from, empty means all")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ginclient [flags] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("ginclient: ")
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	src, err := run(dir, *pkgName, *funcs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *output == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run 分析 dir 中的路由并生成代码
+func run(dir, pkgName, funcs string) ([]byte, error) {
+	p, err := loadPkg(dir)
+	if err != nil {
+		return nil, err
+	}
+	filter := make(map[string]bool)
+	for _, name := range strings.Split(funcs, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			filter[name] = true
+		}
+	}
+	eps, dups := p.routes(filter)
+	for _, dup := range dups {
+		log.Printf("skip duplicate route %s", dup)
+	}
+	if len(eps) == 0 {
+		return nil, fmt.Errorf("no routes found in %s", dir)
+	}
+	source := filepath.Base(dir)
+	if abs, err := filepath.Abs(dir); err == nil {
+		source = filepath.Base(abs)
+	}
+	if funcs != "" {
+		source += " (" + funcs + ")"
+	}
+	return generate(p, eps, pkgName, source)
+}
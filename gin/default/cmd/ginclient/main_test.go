@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	src, err := run("testdata/service", "client", "")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, src)
+	}
+	code := string(src)
+	for _, want := range []string{
+		"func (c *Client) PostApiV1Orders(ctx context.Context, req *Order) (*Order, error)",
+		"func (c *Client) GetApiV1Orders(ctx context.Context, req *ListQuery) (*GetApiV1OrdersResponse, error)",
+		`values(req, "form")`,
+		"func (c *Client) DeleteApiV1OrdersId(ctx context.Context, id string) ([]byte, error)",
+		`"/api/v1/orders/"+url.PathEscape(id)`,
+		`"/files/"+escapePath(path)`,
+		"// Order 订单",
+		"// PostApiV1Orders POST /api/v1/orders\n//\n// createOrder 创建订单",
+		"CreatedAt time.Time `json:\"created_at\"`",
+		"Orders []Order `json:\"orders\"`",
+		"Sort   string  `json:\"sort\"`",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	// 匿名处理函数不使用注册路由的函数的注释
+	if strings.Contains(code, "注册订单服务的路由") {
+		t.Error("inline handler documented with the registering function's comment")
+	}
+	// 未导出的字段和 binding tag 不出现在客户端中
+	if strings.Contains(code, "internal") || strings.Contains(code, "binding:") {
+		t.Error("generated code contains server-only details")
+	}
+}
+
+// TestClientUpToDate 接口改了但是没有重新执行 go generate 时失败
+func TestClientUpToDate(t *testing.T) {
+	want, err := run("../..", "client", "apiDocs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile("../../client/client_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("client/client_gen.go is out of date, run go generate")
+	}
+}
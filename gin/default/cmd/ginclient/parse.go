@@ -0,0 +1,661 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// endpoint 一个路由
+type endpoint struct {
+	Method string
+	// Path gin 的路由模板，例如 /user/search/:username/:address
+	Path string
+	Doc  string
+	handler
+}
+
+// handler 从处理函数中分析出的参数和响应
+type handler struct {
+	// Bind 参数绑定结构体的类型，BindIn 为 json、query、form 或 auto（ShouldBind，按方法决定）
+	Bind   ast.Expr
+	BindIn string
+	// Query、Form 处理函数中用 c.Query、c.PostForm 单独读取的参数
+	Query []string
+	Form  []string
+	// Response 200 时 c.JSON 输出的值，为空时返回原始的 body
+	Response ast.Expr
+	// scope 分析 Bind 类型时需要的局部变量和类型，respScope 是 Response 所在的作用域
+	scope     *scope
+	respScope *scope
+	// doc 具名处理函数的注释
+	doc string
+}
+
+// typeDecl 包级别或者处理函数内定义的类型
+type typeDecl struct {
+	spec *ast.TypeSpec
+	file *ast.File
+	doc  *ast.CommentGroup
+}
+
+// pkg 解析后的 gin 服务源码
+type pkg struct {
+	fset  *token.FileSet
+	files []*ast.File
+	// funcs 包级别的函数和方法，方法只按名字索引
+	funcs map[string]*ast.FuncDecl
+	types map[string]typeDecl
+	// fileOf 每个函数所在的文件，用来查找 import
+	fileOf map[*ast.FuncDecl]*ast.File
+}
+
+// scope 处理函数内的局部变量类型和局部类型定义
+type scope struct {
+	parent *scope
+	file   *ast.File
+	vars   map[string]ast.Expr
+	types  map[string]typeDecl
+}
+
+func newScope(parent *scope, file *ast.File) *scope {
+	return &scope{parent: parent, file: file, vars: make(map[string]ast.Expr), types: make(map[string]typeDecl)}
+}
+
+func (s *scope) lookupVar(name string) ast.Expr {
+	for ; s != nil; s = s.parent {
+		if t, ok := s.vars[name]; ok {
+			return t
+		}
+	}
+	return nil
+}
+
+func (s *scope) lookupType(name string) (typeDecl, bool) {
+	for ; s != nil; s = s.parent {
+		if t, ok := s.types[name]; ok {
+			return t, true
+		}
+	}
+	return typeDecl{}, false
+}
+
+// loadPkg 解析目录下所有非测试的 go 文件
+func loadPkg(dir string) (*pkg, error) {
+	fset := token.NewFileSet()
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	p := &pkg{
+		fset:   fset,
+		funcs:  make(map[string]*ast.FuncDecl),
+		types:  make(map[string]typeDecl),
+		fileOf: make(map[*ast.FuncDecl]*ast.File),
+	}
+	for _, name := range matches {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		p.files = append(p.files, f)
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				p.funcs[decl.Name.Name] = decl
+				p.fileOf[decl] = f
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						doc := ts.Doc
+						if doc == nil && len(decl.Specs) == 1 {
+							doc = decl.Doc
+						}
+						p.types[ts.Name.Name] = typeDecl{spec: ts, file: f, doc: doc}
+					}
+				}
+			}
+		}
+	}
+	return p, nil
+}
+
+var routeMethods = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "DELETE": true, "PATCH": true, "HEAD": true, "OPTIONS": true,
+}
+
+// routes 找出 funcs 中（为空时是所有函数）注册的路由，同一个 method + path 只保留第一个
+func (p *pkg) routes(funcs map[string]bool) (eps []endpoint, dups []string) {
+	seen := make(map[string]bool)
+	for _, f := range p.files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil || (len(funcs) > 0 && !funcs[fn.Name.Name]) {
+				continue
+			}
+			for _, ep := range p.funcRoutes(fn, f) {
+				key := ep.Method + " " + ep.Path
+				if seen[key] {
+					dups = append(dups, key+" in "+fn.Name.Name)
+					continue
+				}
+				seen[key] = true
+				eps = append(eps, ep)
+			}
+		}
+	}
+	return eps, dups
+}
+
+// funcRoutes 按源码顺序找出一个函数中注册的路由，记录 Group 返回的路由组前缀
+func (p *pkg) funcRoutes(fn *ast.FuncDecl, file *ast.File) []endpoint {
+	prefixes := make(map[string]string)
+	var prefixOf func(e ast.Expr) string
+	prefixOf = func(e ast.Expr) string {
+		switch e := e.(type) {
+		case *ast.Ident:
+			return prefixes[e.Name]
+		case *ast.CallExpr:
+			if sel, ok := e.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Group" && len(e.Args) > 0 {
+				if s, ok := stringLit(e.Args[0]); ok {
+					return joinPath(prefixOf(sel.X), s)
+				}
+			}
+		}
+		return ""
+	}
+
+	var eps []endpoint
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) == 1 && len(n.Rhs) == 1 {
+				if id, ok := n.Lhs[0].(*ast.Ident); ok {
+					if call, ok := n.Rhs[0].(*ast.CallExpr); ok {
+						if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Group" {
+							prefixes[id.Name] = prefixOf(call)
+						}
+					}
+				}
+			}
+		case *ast.CallExpr:
+			sel, ok := n.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			var (
+				method, path string
+				group        ast.Expr = sel.X
+				handlers     []ast.Expr
+				op           *ast.CompositeLit
+			)
+			switch {
+			case routeMethods[sel.Sel.Name] && len(n.Args) >= 2:
+				method = sel.Sel.Name
+				path, ok = stringLit(n.Args[0])
+				handlers = n.Args[1:]
+			case sel.Sel.Name == "Handle" && len(n.Args) >= 3 && httpMethod(n.Args[0]) != "":
+				// gin 的 r.Handle("GET", "/path", handlers...)
+				method = httpMethod(n.Args[0])
+				path, ok = stringLit(n.Args[1])
+				handlers = n.Args[2:]
+			case sel.Sel.Name == "Handle" && len(n.Args) >= 5 && httpMethod(n.Args[1]) != "":
+				// openapi 的 doc.Handle(r, http.MethodPost, "/path", openapi.Operation{...}, handlers...)
+				method = httpMethod(n.Args[1])
+				path, ok = stringLit(n.Args[2])
+				group = n.Args[0]
+				op, _ = n.Args[3].(*ast.CompositeLit)
+				handlers = n.Args[4:]
+			default:
+				return true
+			}
+			if !ok {
+				return true
+			}
+			sc := newScope(nil, file)
+			ep := endpoint{
+				Method:  method,
+				Path:    joinPath(prefixOf(group), path),
+				handler: p.analyze(handlers[len(handlers)-1], sc, 0),
+			}
+			if _, ok := handlers[len(handlers)-1].(*ast.FuncLit); !ok {
+				// 具名处理函数用它自己的注释；匿名函数没有注释，注册路由的函数的注释说的不是这个接口，
+				// 只使用 Operation.Summary
+				ep.Doc = ep.doc
+			}
+			if ep.scope == nil {
+				// 处理函数不在这个包里，Operation 中的类型按注册路由的文件解析
+				ep.scope, ep.respScope = sc, sc
+			}
+			applyOperation(&ep, op)
+			eps = append(eps, ep)
+			return false
+		}
+		return true
+	})
+	return eps
+}
+
+// applyOperation openapi.Operation 中写明的 Request、Response 和 Summary 优先
+func applyOperation(ep *endpoint, op *ast.CompositeLit) {
+	if op == nil {
+		return
+	}
+	for _, elt := range op.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, _ := kv.Key.(*ast.Ident)
+		if key == nil {
+			continue
+		}
+		switch key.Name {
+		case "Summary":
+			if s, ok := stringLit(kv.Value); ok {
+				ep.Doc = s
+			}
+		case "Request":
+			if lit, ok := kv.Value.(*ast.CompositeLit); ok && lit.Type != nil {
+				ep.Bind = lit.Type
+				if ep.BindIn == "" {
+					ep.BindIn = "auto"
+				}
+			}
+		case "Response":
+			ep.Response, ep.respScope = kv.Value, ep.scope
+		}
+	}
+}
+
+// analyze 分析处理函数，depth 限制跟进调用的层数
+func (p *pkg) analyze(h ast.Expr, parent *scope, depth int) handler {
+	var (
+		body *ast.BlockStmt
+		doc  string
+	)
+	sc := parent
+	switch h := h.(type) {
+	case *ast.FuncLit:
+		body = h.Body
+		sc = newScope(parent, parent.file)
+	case *ast.Ident, *ast.SelectorExpr:
+		fn := p.funcs[calleeName(h)]
+		if fn == nil || fn.Body == nil || !isHandlerFunc(fn) {
+			return handler{}
+		}
+		body = fn.Body
+		sc = newScope(nil, p.fileOf[fn])
+		// 参数和具名返回值也是局部变量，例如 listGoods 中的 q goodsQuery
+		for _, list := range []*ast.FieldList{fn.Type.Params, fn.Type.Results} {
+			if list == nil {
+				continue
+			}
+			for _, field := range list.List {
+				for _, name := range field.Names {
+					sc.vars[name.Name] = field.Type
+				}
+			}
+		}
+		if fn.Doc != nil {
+			doc = strings.TrimSpace(fn.Doc.Text())
+		}
+	default:
+		return handler{}
+	}
+
+	res := handler{scope: sc, respScope: sc, doc: doc}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// 处理函数里的回调不是这次请求的逻辑
+			return false
+		case *ast.DeclStmt:
+			gd, ok := n.Decl.(*ast.GenDecl)
+			if !ok {
+				return true
+			}
+			for _, spec := range gd.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					sc.types[spec.Name.Name] = typeDecl{spec: spec, file: sc.file}
+				case *ast.ValueSpec:
+					for i, name := range spec.Names {
+						if spec.Type != nil {
+							sc.vars[name.Name] = spec.Type
+						} else if i < len(spec.Values) {
+							sc.vars[name.Name] = p.exprType(spec.Values[i], sc)
+						}
+					}
+				}
+			}
+		case *ast.AssignStmt:
+			p.assign(n, sc)
+		case *ast.CallExpr:
+			p.call(n, sc, &res, depth)
+		}
+		return true
+	})
+	return res
+}
+
+// assign 记录 := 定义的局部变量类型
+func (p *pkg) assign(n *ast.AssignStmt, sc *scope) {
+	if n.Tok != token.DEFINE {
+		return
+	}
+	if len(n.Lhs) == len(n.Rhs) {
+		for i, lhs := range n.Lhs {
+			if id, ok := lhs.(*ast.Ident); ok && id.Name != "_" {
+				if t := p.exprType(n.Rhs[i], sc); t != nil {
+					sc.vars[id.Name] = t
+				}
+			}
+		}
+		return
+	}
+	// a, b := m.f(c)，按包内函数的返回值类型记录
+	call, ok := n.Rhs[0].(*ast.CallExpr)
+	if !ok || len(n.Rhs) != 1 {
+		return
+	}
+	results := p.results(call)
+	if len(results) != len(n.Lhs) {
+		return
+	}
+	for i, lhs := range n.Lhs {
+		if id, ok := lhs.(*ast.Ident); ok && id.Name != "_" {
+			sc.vars[id.Name] = results[i]
+		}
+	}
+}
+
+// call 识别参数绑定、读取参数和输出 JSON 的调用
+func (p *pkg) call(n *ast.CallExpr, sc *scope, res *handler, depth int) {
+	name := calleeName(n.Fun)
+	switch name {
+	case "ShouldBind", "Bind", "ShouldBindJSON", "BindJSON", "ShouldBindQuery", "BindQuery", "ShouldBindWith", "MustBindWith":
+		if res.Bind != nil || len(n.Args) == 0 {
+			return
+		}
+		if t := p.exprType(n.Args[0], sc); t != nil {
+			res.Bind = deref(t)
+			res.BindIn = map[string]string{
+				"ShouldBindJSON": "json", "BindJSON": "json",
+				"ShouldBindQuery": "query", "BindQuery": "query",
+			}[name]
+			if res.BindIn == "" {
+				res.BindIn = "auto"
+			}
+		}
+	case "Query", "DefaultQuery", "GetQuery", "QueryArray":
+		if s, ok := stringLit(arg(n, 0)); ok && isContext(n.Fun) {
+			res.Query = appendUnique(res.Query, s)
+		}
+	case "PostForm", "DefaultPostForm", "GetPostForm", "PostFormArray":
+		if s, ok := stringLit(arg(n, 0)); ok && isContext(n.Fun) {
+			res.Form = appendUnique(res.Form, s)
+		}
+	case "JSON", "IndentedJSON", "PureJSON", "SecureJSON", "AsciiJSON", "Render":
+		// c.JSON(http.StatusOK, v) 和 negotiate.Render(c, http.StatusOK, v)
+		args := n.Args
+		if name == "Render" {
+			if len(args) != 3 {
+				return
+			}
+			args = args[1:]
+		}
+		if res.Response == nil && len(args) == 2 && isOK(args[0]) {
+			res.Response = args[1]
+		}
+	default:
+		// 跟进包内接收 *gin.Context 的函数，例如 m.listGoods(c)
+		if depth >= 2 || !passesContext(n) {
+			return
+		}
+		fn := p.funcs[name]
+		if fn == nil || fn.Body == nil || !isHandlerFunc(fn) {
+			return
+		}
+		sub := p.analyze(n.Fun, sc, depth+1)
+		if res.Bind == nil && sub.Bind != nil {
+			res.Bind, res.BindIn = sub.Bind, sub.BindIn
+			// 被调用函数里的类型在它自己的文件和作用域中解析
+			res.Bind = qualify(sub.Bind, sub.scope)
+		}
+		if res.Response == nil && sub.Response != nil {
+			res.Response, res.respScope = sub.Response, sub.scope
+		}
+		for _, q := range sub.Query {
+			res.Query = appendUnique(res.Query, q)
+		}
+		for _, f := range sub.Form {
+			res.Form = appendUnique(res.Form, f)
+		}
+	}
+}
+
+// scopedType 记住类型表达式应该在哪个作用域中解析
+type scopedType struct {
+	ast.Expr
+	scope *scope
+}
+
+func qualify(t ast.Expr, sc *scope) ast.Expr {
+	if _, ok := t.(*scopedType); ok {
+		return t
+	}
+	return &scopedType{Expr: t, scope: sc}
+}
+
+// exprType 推断表达式的类型，推断不出来时返回 nil
+func (p *pkg) exprType(e ast.Expr, sc *scope) ast.Expr {
+	switch e := e.(type) {
+	case *ast.CompositeLit:
+		if e.Type != nil {
+			return e.Type
+		}
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			if t := p.exprType(e.X, sc); t != nil {
+				return &ast.StarExpr{X: t}
+			}
+		}
+	case *ast.Ident:
+		return sc.lookupVar(e.Name)
+	case *ast.CallExpr:
+		if id, ok := e.Fun.(*ast.Ident); ok && id.Name == "new" && len(e.Args) == 1 {
+			return &ast.StarExpr{X: e.Args[0]}
+		}
+		if results := p.results(e); len(results) == 1 {
+			return results[0]
+		}
+	}
+	if name := basicType(e); name != "" {
+		return ast.NewIdent(name)
+	}
+	return nil
+}
+
+// stringFuncs 返回字符串的常见函数和方法
+var stringFuncs = map[string]bool{
+	"Error": true, "Param": true, "Query": true, "DefaultQuery": true, "PostForm": true, "DefaultPostForm": true,
+	"Sprintf": true, "Sprint": true, "Itoa": true, "FormatInt": true, "String": true, "Join": true,
+	"GetHeader": true, "ClientIP": true, "FullPath": true, "ContentType": true,
+}
+
+// basicType 字面量、状态码常量和常见函数调用的类型
+func basicType(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		switch e.Kind {
+		case token.STRING:
+			return "string"
+		case token.INT:
+			return "int"
+		case token.FLOAT:
+			return "float64"
+		case token.CHAR:
+			return "rune"
+		}
+	case *ast.Ident:
+		if e.Name == "true" || e.Name == "false" {
+			return "bool"
+		}
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok && x.Name == "http" && strings.HasPrefix(e.Sel.Name, "Status") {
+			return "int"
+		}
+	case *ast.CallExpr:
+		name := calleeName(e.Fun)
+		switch {
+		case stringFuncs[name]:
+			return "string"
+		case name == "len" || name == "cap":
+			return "int"
+		}
+		if id, ok := e.Fun.(*ast.Ident); ok && basicTypes[id.Name] && len(e.Args) == 1 {
+			return id.Name
+		}
+	}
+	return ""
+}
+
+// results 包内函数的返回值类型
+func (p *pkg) results(call *ast.CallExpr) []ast.Expr {
+	fn := p.funcs[calleeName(call.Fun)]
+	if fn == nil || fn.Type.Results == nil {
+		return nil
+	}
+	var types []ast.Expr
+	for _, field := range fn.Type.Results.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			types = append(types, qualify(field.Type, newScope(nil, p.fileOf[fn])))
+		}
+	}
+	return types
+}
+
+// isHandlerFunc 第一个参数是 *gin.Context 的函数
+func isHandlerFunc(fn *ast.FuncDecl) bool {
+	params := fn.Type.Params.List
+	if len(params) == 0 {
+		return false
+	}
+	star, ok := params[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "Context"
+}
+
+// passesContext 调用的参数中有变量 c
+func passesContext(n *ast.CallExpr) bool {
+	for _, a := range n.Args {
+		if id, ok := a.(*ast.Ident); ok && id.Name == "c" {
+			return true
+		}
+	}
+	return false
+}
+
+// isContext 调用的接收者是 c
+func isContext(fun ast.Expr) bool {
+	sel, ok := fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	id, ok := sel.X.(*ast.Ident)
+	return ok && id.Name == "c"
+}
+
+// isOK 状态码是 200 或 http.StatusOK
+func isOK(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		return e.Value == "200"
+	case *ast.SelectorExpr:
+		return e.Sel.Name == "StatusOK"
+	}
+	return false
+}
+
+func calleeName(fun ast.Expr) string {
+	switch fun := fun.(type) {
+	case *ast.Ident:
+		return fun.Name
+	case *ast.SelectorExpr:
+		return fun.Sel.Name
+	}
+	return ""
+}
+
+func httpMethod(e ast.Expr) string {
+	if s, ok := stringLit(e); ok && routeMethods[strings.ToUpper(s)] {
+		return strings.ToUpper(s)
+	}
+	if sel, ok := e.(*ast.SelectorExpr); ok && strings.HasPrefix(sel.Sel.Name, "Method") {
+		if m := strings.ToUpper(strings.TrimPrefix(sel.Sel.Name, "Method")); routeMethods[m] {
+			return m
+		}
+	}
+	return ""
+}
+
+func stringLit(e ast.Expr) (string, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+func arg(n *ast.CallExpr, i int) ast.Expr {
+	if i < len(n.Args) {
+		return n.Args[i]
+	}
+	return nil
+}
+
+func deref(t ast.Expr) ast.Expr {
+	if star, ok := t.(*ast.StarExpr); ok {
+		return star.X
+	}
+	if st, ok := t.(*scopedType); ok {
+		return qualify(deref(st.Expr), st.scope)
+	}
+	return t
+}
+
+func joinPath(prefix, path string) string {
+	if path == "" {
+		return prefix
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return strings.TrimSuffix(prefix, "/") + path
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
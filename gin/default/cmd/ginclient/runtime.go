@@ -0,0 +1,162 @@
+package main
+
+// runtimeImports runtime 中用到的包
+var runtimeImports = []string{
+	"bytes", "context", "encoding/json", "fmt", "io", "io/ioutil", "net/http", "net/url", "reflect", "strings", "time",
+}
+
+// runtime 生成代码中和接口无关的部分：Client、错误类型和参数编码
+const runtime = `
+// Client 可以并发使用
+type Client struct {
+	baseURL string
+	http    *http.Client
+	// Header 每个请求都会带上的 Header，例如 Authorization
+	Header http.Header
+}
+
+// New 创建客户端，baseURL 例如 http://127.0.0.1:8080，hc 为 nil 时使用 http.DefaultClient
+func New(baseURL string, hc *http.Client) *Client {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), http: hc, Header: make(http.Header)}
+}
+
+// APIError 服务端返回了非 2xx 的状态码，Message 取自响应中的 error 字段（没有时取 message 字段）
+type APIError struct {
+	StatusCode int
+	Message    string
+	// Body 原始的响应内容
+	Body []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// decodeError 解析服务端的错误响应 {"error": "..."}
+func decodeError(status int, body []byte) error {
+	e := &APIError{StatusCode: status, Body: body}
+	var envelope struct {
+		Error   string ` + "`json:\"error\"`" + `
+		Message string ` + "`json:\"message\"`" + `
+	}
+	if json.Unmarshal(body, &envelope) == nil {
+		e.Message = envelope.Error
+		if e.Message == "" {
+			e.Message = envelope.Message
+		}
+	}
+	if e.Message == "" {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	return e
+}
+
+// do 发送请求，2xx 时把 JSON 响应解析到 out，out 为 *[]byte 时保存原始的 body
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, contentType string, body []byte, out interface{}) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return err
+	}
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return decodeError(resp.StatusCode, data)
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw = data
+		return nil
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// values 按 tag 把结构体编码成 url.Values，和 gin 的 form 绑定规则一致，零值不发送
+func values(v interface{}, tag string) url.Values {
+	vals := make(url.Values)
+	encode(reflect.ValueOf(v), tag, vals)
+	return vals
+}
+
+func encode(rv reflect.Value, tag string, vals url.Values) {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field, fv := rt.Field(i), rv.Field(i)
+		if field.Anonymous {
+			encode(fv, tag, vals)
+			continue
+		}
+		if field.PkgPath != "" || fv.IsZero() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			if tag != "form" {
+				continue
+			}
+			name = field.Name
+		}
+		if fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array {
+			for j := 0; j < fv.Len(); j++ {
+				vals.Add(name, format(fv.Index(j)))
+			}
+			continue
+		}
+		vals.Set(name, format(fv))
+	}
+}
+
+func format(v reflect.Value) string {
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// escapePath 转义 *path 参数，保留其中的 /
+func escapePath(p string) string {
+	segs := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.Join(segs, "/")
+}
+`
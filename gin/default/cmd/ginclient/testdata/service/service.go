@@ -0,0 +1,63 @@
+package service
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// order 订单
+type order struct {
+	ID        int64     `json:"id"`
+	Items     []item    `json:"items" binding:"required"`
+	CreatedAt time.Time `json:"created_at"`
+	internal  string
+}
+
+type item struct {
+	SKU string `json:"sku"`
+}
+
+type listQuery struct {
+	Page int `form:"page" binding:"min=1"`
+}
+
+// routes 注册订单服务的路由
+func routes(r *gin.Engine) {
+	api := r.Group("/api")
+	v1 := api.Group("/v1")
+	v1.POST("/orders", createOrder)
+	v1.GET("/orders", listOrders)
+	v1.Handle(http.MethodDelete, "/orders/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	r.GET("/files/*path", func(c *gin.Context) {
+		c.File(c.Param("path"))
+	})
+}
+
+// createOrder 创建订单
+func createOrder(c *gin.Context) {
+	var o order
+	if err := c.ShouldBindJSON(&o); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, o)
+}
+
+func listOrders(c *gin.Context) {
+	q, orders := bindList(c)
+	c.JSON(http.StatusOK, gin.H{
+		"page":   q.Page,
+		"total":  len(orders),
+		"orders": orders,
+		"sort":   c.DefaultQuery("sort", "id"),
+	})
+}
+
+func bindList(c *gin.Context) (q listQuery, orders []order) {
+	_ = c.ShouldBindQuery(&q)
+	return q, nil
+}
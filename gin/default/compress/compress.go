@@ -0,0 +1,294 @@
+package compress
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// 支持的编码
+const (
+	Brotli = "br"
+	Gzip   = "gzip"
+)
+
+// Config 压缩配置
+type Config struct {
+	// Encodings 服务端的偏好顺序，客户端 q 值相同时选择靠前的，默认 br、gzip
+	Encodings []string
+	// GzipLevel 默认 gzip.DefaultCompression
+	GzipLevel int
+	// BrotliQuality 0-11，默认 4，更高的级别压缩率更好但是很耗 CPU，不适合动态内容
+	BrotliQuality int
+	// MinLength 小于这个长度的响应不压缩，默认 1024 字节
+	MinLength int
+	// ExcludedContentTypes 不压缩的 Content-Type 前缀，默认是图片、音视频和压缩包等已经压缩过的格式
+	ExcludedContentTypes []string
+	// ExcludedPaths 不压缩的路径前缀
+	ExcludedPaths []string
+}
+
+var defaultExcluded = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-brotli",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/pdf",
+	"application/octet-stream", "application/x-protobuf", "application/grpc",
+}
+
+// New 返回压缩中间件
+// 响应先缓冲 MinLength 个字节再决定是否压缩；处理函数调用 Flush（c.Stream、SSE）时立即开始压缩并刷新到客户端
+func New(cfg Config) gin.HandlerFunc {
+	if len(cfg.Encodings) == 0 {
+		cfg.Encodings = []string{Brotli, Gzip}
+	}
+	if cfg.GzipLevel == 0 {
+		cfg.GzipLevel = gzip.DefaultCompression
+	}
+	if cfg.BrotliQuality == 0 {
+		cfg.BrotliQuality = 4
+	}
+	if cfg.MinLength <= 0 {
+		cfg.MinLength = 1024
+	}
+	if cfg.ExcludedContentTypes == nil {
+		cfg.ExcludedContentTypes = defaultExcluded
+	}
+	pools := map[string]*sync.Pool{
+		Gzip: {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(ioutil.Discard, cfg.GzipLevel)
+			return w
+		}},
+		Brotli: {New: func() interface{} {
+			return brotli.NewWriterLevel(ioutil.Discard, cfg.BrotliQuality)
+		}},
+	}
+
+	return func(c *gin.Context) {
+		for _, p := range cfg.ExcludedPaths {
+			if strings.HasPrefix(c.Request.URL.Path, p) {
+				c.Next()
+				return
+			}
+		}
+		// 即使这次不压缩，响应也会随 Accept-Encoding 变化，缓存需要区分
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		// WebSocket 握手和 Range 请求保持原样
+		if c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" || c.GetHeader("Range") != "" {
+			c.Next()
+			return
+		}
+		enc := negotiate(c.GetHeader("Accept-Encoding"), cfg.Encodings)
+		if enc == "" {
+			c.Next()
+			return
+		}
+
+		w := &writer{ResponseWriter: c.Writer, cfg: &cfg, encoding: enc, pool: pools[enc], status: http.StatusOK}
+		c.Writer = w
+		defer func() {
+			w.close()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// negotiate 根据 Accept-Encoding 选择编码，没有可用的编码时返回空字符串
+func negotiate(accept string, supported []string) string {
+	if accept == "" {
+		return ""
+	}
+	q := make(map[string]float64)
+	for _, item := range strings.Split(accept, ",") {
+		parts := strings.Split(item, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		v := 1.0
+		for _, p := range parts[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64); err == nil {
+					v = f
+				}
+			}
+		}
+		q[name] = v
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range supported {
+		v, ok := q[enc]
+		if !ok {
+			v, ok = q["*"]
+		}
+		if ok && v > bestQ {
+			best, bestQ = enc, v
+		}
+	}
+	return best
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// writer 状态：还没决定时缓冲数据；决定之后 enc 为 nil 表示原样输出，否则压缩输出
+type writer struct {
+	gin.ResponseWriter
+	cfg      *Config
+	encoding string
+	pool     *sync.Pool
+
+	status   int
+	wrote    bool
+	buf      []byte
+	decided  bool
+	enc      encoder
+	finished bool
+}
+
+func (w *writer) WriteHeader(code int) {
+	if code > 0 && !w.decided {
+		w.status = code
+		w.wrote = true
+	}
+}
+
+// WriteHeaderNow 推迟到决定是否压缩之后再写
+func (w *writer) WriteHeaderNow() {}
+
+func (w *writer) Status() int {
+	if w.decided {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *writer) Written() bool {
+	return w.decided || w.wrote || len(w.buf) > 0
+}
+
+func (w *writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	if !w.decided {
+		if len(w.buf)+len(b) < w.cfg.MinLength {
+			w.buf = append(w.buf, b...)
+			return len(b), nil
+		}
+		w.decide(true)
+		if err := w.writeBuffered(); err != nil {
+			return 0, err
+		}
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush 流式响应没法等到 MinLength，第一次 Flush 时就决定
+func (w *writer) Flush() {
+	if !w.decided {
+		w.decide(true)
+		if err := w.writeBuffered(); err != nil {
+			return
+		}
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Hijack 连接被接管之后不再压缩
+func (w *writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	return w.ResponseWriter.Hijack()
+}
+
+// decide 根据状态码和响应头决定是否压缩，然后写出响应头
+func (w *writer) decide(large bool) {
+	w.decided = true
+	h := w.Header()
+	if large && w.compressible(h) {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		// 内容变了，强 ETag 不再成立
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.enc = w.pool.Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *writer) compressible(h http.Header) bool {
+	if w.status < http.StatusOK || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	ct := strings.ToLower(h.Get("Content-Type"))
+	if ct == "" && len(w.buf) > 0 {
+		ct = http.DetectContentType(w.buf)
+	}
+	for _, ex := range w.cfg.ExcludedContentTypes {
+		if strings.HasPrefix(ct, ex) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *writer) writeBuffered() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	b := w.buf
+	w.buf = nil
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(b)
+	} else {
+		_, err = w.ResponseWriter.Write(b)
+	}
+	return err
+}
+
+// close 处理函数返回之后调用，小响应在这里原样输出
+func (w *writer) close() {
+	if w.finished {
+		return
+	}
+	w.finished = true
+	// 处理函数什么都没写，交给 gin 处理（例如 404），这里不能写出 200
+	if !w.decided && !w.wrote && len(w.buf) == 0 {
+		return
+	}
+	if !w.decided {
+		w.decide(false)
+		w.writeBuffered()
+		return
+	}
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc.Reset(ioutil.Discard)
+		w.pool.Put(w.enc)
+		w.enc = nil
+	}
+}
@@ -0,0 +1,150 @@
+package compress
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestNegotiate(t *testing.T) {
+	supported := []string{Brotli, Gzip}
+	cases := map[string]string{
+		"":                    "",
+		"gzip, deflate":       Gzip,
+		"gzip, deflate, br":   Brotli,
+		"br;q=0.5, gzip":      Gzip,
+		"*":                   Brotli,
+		"*, br;q=0":           Gzip,
+		"identity":            "",
+		"gzip;q=0, br;q=0":    "",
+		"GZIP;q=0.8, deflate": Gzip,
+	}
+	for accept, want := range cases {
+		if got := negotiate(accept, supported); got != want {
+			t.Errorf("negotiate(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func newRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(New(Config{MinLength: 100}))
+	r.GET("/large", func(c *gin.Context) {
+		c.String(http.StatusOK, strings.Repeat("hello ", 100))
+	})
+	r.GET("/small", func(c *gin.Context) {
+		c.String(http.StatusOK, "hello")
+	})
+	r.GET("/image", func(c *gin.Context) {
+		c.Data(http.StatusOK, "image/png", make([]byte, 1000))
+	})
+	return r
+}
+
+func TestCompress(t *testing.T) {
+	r := newRouter()
+	do := func(path, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", accept)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/large", "gzip")
+	if w.Header().Get("Content-Encoding") != Gzip || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("headers: %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(zr); string(b) != strings.Repeat("hello ", 100) {
+		t.Fatalf("gzip body = %q", b)
+	}
+
+	w = do("/large", "gzip, br")
+	if w.Header().Get("Content-Encoding") != Brotli {
+		t.Fatalf("headers: %v", w.Header())
+	}
+	if b, _ := ioutil.ReadAll(brotli.NewReader(w.Body)); string(b) != strings.Repeat("hello ", 100) {
+		t.Fatalf("brotli body = %q", b)
+	}
+
+	for _, path := range []string{"/small", "/image"} {
+		w = do(path, "gzip")
+		if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "" {
+			t.Fatalf("%s should not be compressed: %d %v", path, w.Code, w.Header())
+		}
+	}
+	if w = do("/small", "gzip"); w.Body.String() != "hello" {
+		t.Fatalf("small body = %q", w.Body.String())
+	}
+	if w = do("/missing", "gzip"); w.Code != http.StatusNotFound {
+		t.Fatalf("missing route status = %d", w.Code)
+	}
+}
+
+// SSE 的每个事件要在压缩之后立即到达客户端
+func TestStreaming(t *testing.T) {
+	r := gin.New()
+	r.Use(New(Config{}))
+	next := make(chan struct{})
+	r.GET("/events", func(c *gin.Context) {
+		for i := 0; i < 2; i++ {
+			c.SSEvent("message", "tick")
+			c.Writer.Flush()
+			<-next
+		}
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != Gzip {
+		t.Fatalf("headers: %v", resp.Header)
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(zr)
+	for i := 0; i < 2; i++ {
+		done := make(chan string, 1)
+		go func() {
+			line, _ := br.ReadString('\n')
+			br.ReadString('\n')
+			br.ReadString('\n')
+			done <- line
+		}()
+		select {
+		case line := <-done:
+			if line != "event:message\n" {
+				t.Fatalf("event %d = %q", i, line)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("event %d was not flushed", i)
+		}
+		next <- struct{}{}
+	}
+	io.Copy(ioutil.Discard, br)
+}
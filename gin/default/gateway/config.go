@@ -0,0 +1,161 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 负载均衡策略
+const (
+	// RoundRobin 轮询，默认
+	RoundRobin = "round_robin"
+	// LeastConn 选择正在处理的请求最少的上游
+	LeastConn = "least_conn"
+)
+
+// Config 网关配置
+type Config struct {
+	Routes []Route
+	// HealthCheck 主动健康检查，Interval 为 0 时不检查，所有上游一直视为可用
+	HealthCheck HealthCheck
+	// FlushInterval 转发响应时的刷新间隔，为负数时每次写入都立即刷新（SSE 之类的流式响应）
+	FlushInterval time.Duration
+}
+
+// Route 一条路由规则，Host 和 Prefix 都匹配时转发到 Upstreams
+// 多条规则都匹配时，指定了 Host 的优先于 Host 为空的，然后 Prefix 最长的优先
+type Route struct {
+	// Name 用于日志和状态接口，为空时使用 Host+Prefix
+	Name string
+	// Host 为空时匹配所有 Host，*.example.com 匹配所有子域名，比较时忽略端口
+	Host string
+	// Prefix 路径前缀，为空时等价于 /；/api 匹配 /api 和 /api/...，不匹配 /apix
+	Prefix string
+	// StripPrefix 为 true 时转发前去掉 Prefix，/api/users 转发为 /users
+	StripPrefix bool
+	// Upstreams 上游地址，例如 http://127.0.0.1:8080，可以带路径前缀
+	Upstreams []string
+	// Balancer RoundRobin 或 LeastConn
+	Balancer string
+	// HealthPath 健康检查请求的路径，为空时使用 HealthCheck.Path
+	HealthPath string
+	// Auth 为 true 时请求先经过 Mount 传入的认证中间件
+	Auth bool
+
+	// 转发前对请求 Header 的修改，先删除再设置
+	RemoveRequestHeaders []string
+	SetRequestHeaders    map[string]string
+	// 返回前对响应 Header 的修改，先删除再设置
+	RemoveResponseHeaders []string
+	SetResponseHeaders    map[string]string
+}
+
+// HealthCheck 定时请求每个上游的健康检查路径，返回 2xx/3xx 算成功
+type HealthCheck struct {
+	Interval time.Duration
+	// Timeout 单次检查的超时，默认 2 秒
+	Timeout time.Duration
+	// Path 默认 /
+	Path string
+	// UnhealthyThreshold 连续失败多少次后摘除，默认 2
+	UnhealthyThreshold int
+	// HealthyThreshold 摘除后连续成功多少次恢复，默认 2
+	HealthyThreshold int
+}
+
+func (h HealthCheck) withDefaults() HealthCheck {
+	if h.Timeout <= 0 {
+		h.Timeout = 2 * time.Second
+	}
+	if h.Path == "" {
+		h.Path = "/"
+	}
+	if h.UnhealthyThreshold <= 0 {
+		h.UnhealthyThreshold = 2
+	}
+	if h.HealthyThreshold <= 0 {
+		h.HealthyThreshold = 2
+	}
+	return h
+}
+
+func (r Route) name() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Host + r.prefix()
+}
+
+// prefix 规范化后的前缀，不以 / 结尾（/ 本身除外）
+func (r Route) prefix() string {
+	return "/" + strings.Trim(r.Prefix, "/")
+}
+
+func (r Route) validate() error {
+	if len(r.Upstreams) == 0 {
+		return errors.New("no upstreams")
+	}
+	for _, u := range r.Upstreams {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return err
+		}
+		if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+			return fmt.Errorf("invalid upstream %q", u)
+		}
+	}
+	switch r.Balancer {
+	case "", RoundRobin, LeastConn:
+	default:
+		return fmt.Errorf("unknown balancer %q", r.Balancer)
+	}
+	return nil
+}
+
+// matchHost 比较时忽略大小写和端口
+func (r Route) matchHost(host string) bool {
+	switch {
+	case r.Host == "":
+		return true
+	case strings.HasPrefix(r.Host, "*."):
+		return strings.HasSuffix(host, strings.ToLower(r.Host[1:]))
+	}
+	return strings.EqualFold(r.Host, host)
+}
+
+func (r Route) matchPath(path string) bool {
+	p := r.prefix()
+	if p == "/" || path == p {
+		return true
+	}
+	return strings.HasPrefix(path, p+"/")
+}
+
+// strip 去掉前缀后的路径，结果总是以 / 开头
+func (r Route) strip(path string) string {
+	p := r.prefix()
+	if p == "/" {
+		return path
+	}
+	path = strings.TrimPrefix(path, p)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// score 用于在多条匹配的规则中选择：精确 Host > 通配 Host > 任意 Host，然后前缀越长越优先
+func (r Route) score() int {
+	s := len(r.prefix())
+	switch {
+	case r.Host == "":
+	case strings.HasPrefix(r.Host, "*."):
+		s += 1 << 16
+	default:
+		s += 2 << 16
+	}
+	return s
+}
@@ -0,0 +1,244 @@
+// Package gateway 基于 httputil.ReverseProxy 的 API 网关
+//
+// 按 Host 和路径前缀把请求转发到上游集合，支持轮询和最少连接两种负载均衡、主动健康检查、
+// 增删请求和响应 Header，以及在网关统一做认证（例如 auth.JWT 的中间件），
+// 认证通过后用户名通过 UserHeader 传给上游，上游不需要再校验 token。
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserHeader 认证通过后转发给上游的用户名，客户端自己带的会被删除，上游可以信任这个 Header
+const UserHeader = "X-Auth-User"
+
+// UserKey 认证中间件在 gin.Context 中保存用户名的 key，和 auth.UserKey 一致
+const UserKey = "username"
+
+const routeKey = "gateway.route"
+
+type upstreamKey struct{}
+
+// route 编译后的路由规则
+type route struct {
+	Route
+	pool  *pool
+	proxy *httputil.ReverseProxy
+}
+
+// Gateway 可以并发使用
+type Gateway struct {
+	routes []*route
+	hc     HealthCheck
+}
+
+// New 校验配置并创建网关，健康检查需要调用 Start 启动
+func New(cfg Config) (*Gateway, error) {
+	g := &Gateway{hc: cfg.HealthCheck.withDefaults()}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	for i, r := range cfg.Routes {
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("gateway: route %d (%s): %w", i, r.name(), err)
+		}
+		rt := &route{Route: r, pool: newPool(r.name(), r.Upstreams, r.Balancer)}
+		rt.proxy = &httputil.ReverseProxy{
+			Director:       rt.director,
+			ModifyResponse: rt.modifyResponse,
+			ErrorHandler:   rt.errorHandler,
+			Transport:      transport,
+			FlushInterval:  cfg.FlushInterval,
+		}
+		g.routes = append(g.routes, rt)
+	}
+	// 按优先级排好序，匹配时第一条命中的就是结果
+	sort.SliceStable(g.routes, func(i, j int) bool {
+		return g.routes[i].score() > g.routes[j].score()
+	})
+	return g, nil
+}
+
+// Start 启动健康检查，ctx 取消时停止；HealthCheck.Interval 为 0 时什么都不做
+func (g *Gateway) Start(ctx context.Context) {
+	if g.hc.Interval <= 0 {
+		return
+	}
+	client := &http.Client{
+		// 3xx 也算健康，不跟随跳转
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	for _, rt := range g.routes {
+		path := rt.HealthPath
+		if path == "" {
+			path = g.hc.Path
+		}
+		go func(p *pool, path string) {
+			t := time.NewTicker(g.hc.Interval)
+			defer t.Stop()
+			for {
+				p.check(ctx, client, g.hc, path)
+				select {
+				case <-t.C:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(rt.pool, path)
+	}
+}
+
+// Mount 把网关注册为 r 的 NoRoute，r 上自己注册的路由（例如登录接口）优先
+// auth 为需要认证的路由使用的中间件，为 nil 时所有路由都不认证
+func (g *Gateway) Mount(r *gin.Engine, auth gin.HandlerFunc) {
+	r.NoRoute(g.Handlers(auth)...)
+}
+
+// Handlers 返回匹配路由、认证和转发的 handler 链，可以挂在任意路由上，例如 r.Any("/api/*path", ...)
+func (g *Gateway) Handlers(auth gin.HandlerFunc) []gin.HandlerFunc {
+	return []gin.HandlerFunc{g.match, protect(auth), g.forward}
+}
+
+// match 找到请求对应的路由，没有时返回 404
+func (g *Gateway) match(c *gin.Context) {
+	host := c.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	for _, rt := range g.routes {
+		if rt.matchHost(host) && rt.matchPath(c.Request.URL.Path) {
+			c.Set(routeKey, rt)
+			return
+		}
+	}
+	c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "no route for " + host + c.Request.URL.Path})
+}
+
+// protect 只对 Auth 为 true 的路由执行认证中间件
+func protect(auth gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if auth != nil && c.MustGet(routeKey).(*route).Auth {
+			auth(c)
+		}
+	}
+}
+
+func (g *Gateway) forward(c *gin.Context) {
+	rt := c.MustGet(routeKey).(*route)
+	up := rt.pool.pick()
+	if up == nil {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "no healthy upstream"})
+		return
+	}
+	atomic.AddInt64(&up.active, 1)
+	defer atomic.AddInt64(&up.active, -1)
+
+	req := c.Request.WithContext(context.WithValue(c.Request.Context(), upstreamKey{}, up))
+	req.Header.Del(UserHeader)
+	if user := c.GetString(UserKey); user != "" {
+		req.Header.Set(UserHeader, user)
+	}
+	rt.proxy.ServeHTTP(c.Writer, req)
+}
+
+// director 改写转发给上游的请求
+func (rt *route) director(req *http.Request) {
+	target := req.Context().Value(upstreamKey{}).(*upstream).url
+	path := req.URL.Path
+	if rt.StripPrefix {
+		path = rt.strip(path)
+	}
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.URL.Path = strings.TrimSuffix(target.Path, "/") + path
+	req.URL.RawPath = ""
+	if target.RawQuery != "" {
+		if req.URL.RawQuery == "" {
+			req.URL.RawQuery = target.RawQuery
+		} else {
+			req.URL.RawQuery = target.RawQuery + "&" + req.URL.RawQuery
+		}
+	}
+	// X-Forwarded-For 由 ReverseProxy 追加，Host 保持客户端请求的值
+	req.Header.Set("X-Forwarded-Host", req.Host)
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+	req.Header.Set("X-Forwarded-Proto", proto)
+	if _, ok := req.Header["User-Agent"]; !ok {
+		// 不让 net/http 加上默认的 User-Agent
+		req.Header.Set("User-Agent", "")
+	}
+	for _, h := range rt.RemoveRequestHeaders {
+		req.Header.Del(h)
+	}
+	for k, v := range rt.SetRequestHeaders {
+		req.Header.Set(k, v)
+	}
+}
+
+func (rt *route) modifyResponse(resp *http.Response) error {
+	for _, h := range rt.RemoveResponseHeaders {
+		resp.Header.Del(h)
+	}
+	for k, v := range rt.SetResponseHeaders {
+		resp.Header.Set(k, v)
+	}
+	return nil
+}
+
+// errorHandler 上游不可达或者超时时返回 502，客户端自己断开的不记录
+func (rt *route) errorHandler(w http.ResponseWriter, req *http.Request, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	target := req.Context().Value(upstreamKey{}).(*upstream).url
+	log.Printf("gateway: route %s upstream %s: %v", rt.name(), target, err)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusBadGateway)
+	fmt.Fprint(w, `{"error":"upstream unavailable"}`)
+}
+
+// UpstreamStatus 一个上游的状态
+type UpstreamStatus struct {
+	Route   string `json:"route"`
+	URL     string `json:"url"`
+	Healthy bool   `json:"healthy"`
+	Active  int64  `json:"active"`
+}
+
+// Status 所有上游当前的状态，可以挂在管理接口上
+func (g *Gateway) Status() []UpstreamStatus {
+	var s []UpstreamStatus
+	for _, rt := range g.routes {
+		for _, u := range rt.pool.upstreams {
+			s = append(s, UpstreamStatus{
+				Route:   rt.name(),
+				URL:     u.url.String(),
+				Healthy: u.healthy(),
+				Active:  atomic.LoadInt64(&u.active),
+			})
+		}
+	}
+	return s
+}
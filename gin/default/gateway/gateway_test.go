@@ -0,0 +1,259 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// backend 返回自己的名字和收到的请求
+func backend(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", name)
+		w.Header().Set("Server", "gin")
+		json.NewEncoder(w).Encode(map[string]string{
+			"backend": name,
+			"path":    r.URL.RequestURI(),
+			"user":    r.Header.Get(UserHeader),
+			"env":     r.Header.Get("X-Env"),
+			"cookie":  r.Header.Get("Cookie"),
+		})
+	}))
+}
+
+// serve 启动网关，ReverseProxy 需要 CloseNotifier，不能直接用 httptest.ResponseRecorder
+func serve(t *testing.T, g *Gateway, auth gin.HandlerFunc) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	g.Mount(r, auth)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newServer(t *testing.T, cfg Config, auth gin.HandlerFunc) *httptest.Server {
+	t.Helper()
+	g, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serve(t, g, auth)
+}
+
+type result struct {
+	code   int
+	header http.Header
+	raw    string
+	body   map[string]string
+}
+
+func get(t *testing.T, srv *httptest.Server, host, path string, header http.Header) result {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	req.Host = host
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, _ := ioutil.ReadAll(resp.Body)
+	res := result{code: resp.StatusCode, header: resp.Header, raw: string(raw)}
+	json.Unmarshal(raw, &res.body)
+	return res
+}
+
+func TestRouting(t *testing.T) {
+	a, b, c := backend("a"), backend("b"), backend("c")
+	defer a.Close()
+	defer b.Close()
+	defer c.Close()
+
+	srv := newServer(t, Config{Routes: []Route{
+		{Upstreams: []string{a.URL}},
+		{Prefix: "/api", StripPrefix: true, Upstreams: []string{b.URL + "/v1"}},
+		{Host: "*.example.com", Upstreams: []string{c.URL}},
+		{Host: "admin.example.com", Prefix: "/api/", Upstreams: []string{a.URL}},
+	}}, nil)
+
+	for _, tc := range []struct {
+		host, path      string
+		backend, target string
+	}{
+		{"localhost:8000", "/index?x=1", "a", "/index?x=1"},
+		{"localhost", "/api/users?id=1", "b", "/v1/users?id=1"},
+		{"localhost", "/api", "b", "/v1/"},
+		{"localhost", "/apix", "a", "/apix"},
+		{"shop.example.com", "/api/users", "c", "/api/users"},
+		{"Admin.Example.com:443", "/api/users", "a", "/api/users"},
+		{"admin.example.com", "/home", "c", "/home"},
+	} {
+		res := get(t, srv, tc.host, tc.path, nil)
+		if res.code != http.StatusOK || res.body["backend"] != tc.backend || res.body["path"] != tc.target {
+			t.Errorf("%s%s: code = %d, body = %s, want %s%s", tc.host, tc.path, res.code, res.raw, tc.backend, tc.target)
+		}
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	a := backend("a")
+	defer a.Close()
+	srv := newServer(t, Config{Routes: []Route{{
+		Upstreams:             []string{a.URL},
+		RemoveRequestHeaders:  []string{"Cookie"},
+		SetRequestHeaders:     map[string]string{"X-Env": "prod"},
+		RemoveResponseHeaders: []string{"Server"},
+		SetResponseHeaders:    map[string]string{"X-Gateway": "gin"},
+	}}}, nil)
+
+	res := get(t, srv, "localhost", "/", http.Header{"Cookie": {"session=1"}, "X-Env": {"dev"}})
+	if res.body["cookie"] != "" || res.body["env"] != "prod" {
+		t.Errorf("request headers not rewritten: %s", res.raw)
+	}
+	if res.header.Get("Server") != "" || res.header.Get("X-Gateway") != "gin" || res.header.Get("X-Backend") != "a" {
+		t.Errorf("response headers not rewritten: %v", res.header)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	a := backend("a")
+	defer a.Close()
+	auth := func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "Bearer ok" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		c.Set(UserKey, "jason")
+	}
+	srv := newServer(t, Config{Routes: []Route{
+		{Prefix: "/public", Upstreams: []string{a.URL}},
+		{Upstreams: []string{a.URL}, Auth: true},
+	}}, auth)
+
+	// 客户端伪造的用户名不会传给上游
+	spoofed := http.Header{UserHeader: {"admin"}}
+	if res := get(t, srv, "localhost", "/public/", spoofed); res.code != http.StatusOK || res.body["user"] != "" {
+		t.Errorf("public: code = %d, body = %s", res.code, res.raw)
+	}
+	if res := get(t, srv, "localhost", "/private", spoofed); res.code != http.StatusUnauthorized {
+		t.Errorf("without token: code = %d", res.code)
+	}
+	spoofed.Set("Authorization", "Bearer ok")
+	if res := get(t, srv, "localhost", "/private", spoofed); res.code != http.StatusOK || res.body["user"] != "jason" {
+		t.Errorf("with token: code = %d, body = %s", res.code, res.raw)
+	}
+}
+
+func TestBalancer(t *testing.T) {
+	p := newPool("test", []string{"http://a", "http://b", "http://c"}, RoundRobin)
+	count := map[string]int{}
+	for i := 0; i < 9; i++ {
+		count[p.pick().url.Host]++
+	}
+	if count["a"] != 3 || count["b"] != 3 || count["c"] != 3 {
+		t.Errorf("round robin: %v", count)
+	}
+
+	p = newPool("test", []string{"http://a", "http://b", "http://c"}, LeastConn)
+	p.upstreams[0].active, p.upstreams[1].active, p.upstreams[2].active = 3, 1, 2
+	for i := 0; i < 3; i++ {
+		if u := p.pick(); u.url.Host != "b" {
+			t.Errorf("least conn picked %s", u.url.Host)
+		}
+	}
+	p.upstreams[1].down = 1
+	if u := p.pick(); u.url.Host != "c" {
+		t.Errorf("least conn with b down picked %s", u.url.Host)
+	}
+	for _, u := range p.upstreams {
+		u.down = 1
+	}
+	if u := p.pick(); u != nil {
+		t.Errorf("all down picked %s", u.url.Host)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	var failing int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" && atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("flaky"))
+	}))
+	defer flaky.Close()
+	stable := backend("stable")
+	defer stable.Close()
+
+	g, err := New(Config{
+		Routes: []Route{{Upstreams: []string{flaky.URL, stable.URL}}},
+		HealthCheck: HealthCheck{
+			Interval:           10 * time.Millisecond,
+			Path:               "/healthz",
+			UnhealthyThreshold: 1,
+			HealthyThreshold:   1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g.Start(ctx)
+
+	wait := func(healthy bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for g.Status()[0].Healthy != healthy {
+			if time.Now().After(deadline) {
+				t.Fatalf("upstream healthy = %v, want %v", !healthy, healthy)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	srv := serve(t, g, nil)
+	atomic.StoreInt32(&failing, 1)
+	wait(false)
+	for i := 0; i < 4; i++ {
+		if res := get(t, srv, "localhost", "/", nil); res.body["backend"] != "stable" {
+			t.Fatalf("request %d went to unhealthy upstream: %d %s", i, res.code, res.raw)
+		}
+	}
+	atomic.StoreInt32(&failing, 0)
+	wait(true)
+}
+
+func TestUpstreamTarget(t *testing.T) {
+	p := newPool("test", []string{"http://h/base/", "http://h", "http://h/base?x=1"}, RoundRobin)
+	want := []string{"http://h/base/healthz", "http://h/healthz", "http://h/base/healthz?x=1"}
+	for i, u := range p.upstreams {
+		if got := u.target("/healthz"); got != want[i] {
+			t.Errorf("target = %q, want %q", got, want[i])
+		}
+	}
+}
+
+func TestUpstreamDown(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	srv := newServer(t, Config{Routes: []Route{{Upstreams: []string{dead.URL}}}}, nil)
+	if res := get(t, srv, "localhost", "/", nil); res.code != http.StatusBadGateway || res.body["error"] == "" {
+		t.Errorf("code = %d, body = %s", res.code, res.raw)
+	}
+
+	if _, err := New(Config{Routes: []Route{{Upstreams: []string{"127.0.0.1:8080"}}}}); err == nil {
+		t.Error("expected error for upstream without scheme")
+	}
+}
@@ -0,0 +1,119 @@
+package gateway
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// upstream 一个上游实例
+type upstream struct {
+	url *url.URL
+	// active 正在转发的请求数
+	active int64
+	// down 为 1 时被健康检查摘除
+	down int32
+
+	// 连续成功、失败的次数，只在健康检查的 goroutine 中修改
+	successes, failures int
+}
+
+func (u *upstream) healthy() bool {
+	return atomic.LoadInt32(&u.down) == 0
+}
+
+// target 上游上 path 对应的地址，和 director 一样拼接上游自带的路径前缀
+func (u *upstream) target(path string) string {
+	t := *u.url
+	t.Path = strings.TrimSuffix(t.Path, "/") + path
+	t.RawPath = ""
+	return t.String()
+}
+
+// pool 一条路由的上游集合
+type pool struct {
+	route     string
+	upstreams []*upstream
+	balancer  string
+	next      uint32
+}
+
+func newPool(route string, addrs []string, balancer string) *pool {
+	p := &pool{route: route, balancer: balancer}
+	for _, a := range addrs {
+		u, _ := url.Parse(a)
+		p.upstreams = append(p.upstreams, &upstream{url: u})
+	}
+	return p
+}
+
+// pick 按策略选一个可用的上游，全部不可用时返回 nil
+func (p *pool) pick() *upstream {
+	n := len(p.upstreams)
+	// 从轮询的位置开始找，least_conn 在连接数相同时也能分散到不同的上游
+	start := int(atomic.AddUint32(&p.next, 1)-1) % n
+	var best *upstream
+	for i := 0; i < n; i++ {
+		u := p.upstreams[(start+i)%n]
+		if !u.healthy() {
+			continue
+		}
+		if p.balancer != LeastConn {
+			return u
+		}
+		if best == nil || atomic.LoadInt64(&u.active) < atomic.LoadInt64(&best.active) {
+			best = u
+		}
+	}
+	return best
+}
+
+// check 检查所有上游一次
+func (p *pool) check(ctx context.Context, client *http.Client, hc HealthCheck, path string) {
+	var wg sync.WaitGroup
+	for _, u := range p.upstreams {
+		wg.Add(1)
+		go func(u *upstream) {
+			defer wg.Done()
+			p.report(u, probe(ctx, client, hc.Timeout, u.target(path)), hc)
+		}(u)
+	}
+	wg.Wait()
+}
+
+// report 记录一次检查的结果，达到阈值时改变状态
+func (p *pool) report(u *upstream, ok bool, hc HealthCheck) {
+	if ok {
+		u.successes, u.failures = u.successes+1, 0
+		if !u.healthy() && u.successes >= hc.HealthyThreshold {
+			atomic.StoreInt32(&u.down, 0)
+			log.Printf("gateway: route %s upstream %s is healthy", p.route, u.url)
+		}
+		return
+	}
+	u.successes, u.failures = 0, u.failures+1
+	if u.healthy() && u.failures >= hc.UnhealthyThreshold {
+		atomic.StoreInt32(&u.down, 1)
+		log.Printf("gateway: route %s upstream %s is unhealthy", p.route, u.url)
+	}
+}
+
+func probe(ctx context.Context, client *http.Client, timeout time.Duration, target string) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 400
+}
@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Logger 以 JSON 行的格式输出日志，每行固定包含 time、level、msg 字段
+// With 返回的子 Logger 共享同一个输出，并在每行带上额外的字段
+type Logger struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	fields []interface{}
+}
+
+// New 创建输出到 w 的 Logger
+func New(w io.Writer) *Logger {
+	return &Logger{mu: &sync.Mutex{}, out: w}
+}
+
+// Default 输出到标准输出，没有在上下文中找到 Logger 时使用
+var Default = New(os.Stdout)
+
+// With 返回带有额外字段的子 Logger，kv 为交替的键和值
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	fields = append(fields, l.fields...)
+	fields = append(fields, kv...)
+	return &Logger{mu: l.mu, out: l.out, fields: fields}
+}
+
+// Info 输出 info 级别日志
+func (l *Logger) Info(msg string, kv ...interface{}) { l.log("info", msg, kv) }
+
+// Warn 输出 warn 级别日志
+func (l *Logger) Warn(msg string, kv ...interface{}) { l.log("warn", msg, kv) }
+
+// Error 输出 error 级别日志
+func (l *Logger) Error(msg string, kv ...interface{}) { l.log("error", msg, kv) }
+
+func (l *Logger) log(level, msg string, kv []interface{}) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeValue(&buf, time.Now().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeValue(&buf, level)
+	buf.WriteString(`,"msg":`)
+	writeValue(&buf, msg)
+	writeFields(&buf, l.fields)
+	writeFields(&buf, kv)
+	buf.WriteString("}\n")
+
+	l.mu.Lock()
+	l.out.Write(buf.Bytes())
+	l.mu.Unlock()
+}
+
+// writeFields 按传入的顺序输出字段，保证日志里字段顺序稳定
+func writeFields(buf *bytes.Buffer, kv []interface{}) {
+	for i := 0; i < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		var val interface{} = "!MISSING"
+		if i+1 < len(kv) {
+			val = kv[i+1]
+		}
+		buf.WriteByte(',')
+		writeValue(buf, key)
+		buf.WriteByte(':')
+		writeValue(buf, val)
+	}
+}
+
+func writeValue(buf *bytes.Buffer, v interface{}) {
+	switch x := v.(type) {
+	case error:
+		v = x.Error()
+	case fmt.Stringer:
+		v = x.String()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprintf("%+v", v))
+	}
+	buf.Write(b)
+}
+
+type ctxKey struct{}
+
+// NewContext 把 Logger 保存到 ctx 中
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext 取出 ctx 中的 Logger，没有时返回 Default
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*Logger); ok {
+		return l
+	}
+	return Default
+}
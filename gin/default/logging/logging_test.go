@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	r := gin.New()
+	r.Use(Middleware(Config{Logger: New(&buf), SkipPaths: []string{"/healthz"}}))
+	r.GET("/user/:name", func(c *gin.Context) {
+		c.Set("username", c.Param("name"))
+		FromGin(c).Info("hello")
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/healthz", func(c *gin.Context) {})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/user/jason", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Errorf("expected:%v, got:%v", "abc-123", got)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got:%q", lines)
+	}
+	var handler, access map[string]interface{}
+	json.Unmarshal([]byte(lines[0]), &handler)
+	json.Unmarshal([]byte(lines[1]), &access)
+	if handler["request_id"] != "abc-123" || handler["msg"] != "hello" {
+		t.Errorf("handler log: %v", handler)
+	}
+	if access["route"] != "/user/:name" || access["status"] != float64(200) || access["user"] != "jason" || access["bytes"] != float64(2) {
+		t.Errorf("access log: %v", access)
+	}
+
+	// 非法的请求 ID 会被替换，跳过的路径不记录
+	buf.Reset()
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set(RequestIDHeader, "bad id\n")
+	r.ServeHTTP(w, req)
+	if id := w.Header().Get(RequestIDHeader); len(id) != 32 {
+		t.Errorf("expected generated id, got:%q", id)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("skipped path logged: %s", buf.String())
+	}
+}
@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	mrand "math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader 请求 ID 的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey gin.Context 中保存请求 ID 的 key
+const RequestIDKey = "request_id"
+
+const loggerKey = "logging.logger"
+
+// Config 请求日志中间件的配置
+type Config struct {
+	// Logger 默认 Default
+	Logger *Logger
+	// SkipPaths 不记录访问日志的路径，例如健康检查；以 * 结尾表示前缀匹配
+	// 被跳过的请求仍然会分配请求 ID
+	SkipPaths []string
+	// SampleRate 访问日志的采样比例，取值 (0, 1]，默认 1 全部记录
+	// 状态码 >= 500 的请求总是会被记录
+	SampleRate float64
+	// GenerateID 生成请求 ID，默认 16 字节随机数的十六进制
+	GenerateID func() string
+}
+
+// Middleware 分配或透传 X-Request-ID，把带有请求 ID 的 Logger 保存到上下文中，
+// 请求结束后以 JSON 格式记录 method、路由模板、状态码、耗时、响应大小和用户
+func Middleware(cfg Config) gin.HandlerFunc {
+	if cfg.Logger == nil {
+		cfg.Logger = Default
+	}
+	if cfg.SampleRate <= 0 || cfg.SampleRate > 1 {
+		cfg.SampleRate = 1
+	}
+	if cfg.GenerateID == nil {
+		cfg.GenerateID = newRequestID
+	}
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = cfg.GenerateID()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Set(RequestIDKey, id)
+
+		l := cfg.Logger.With("request_id", id)
+		c.Set(loggerKey, l)
+		// 同时放到 Request 的 context 中，handler 把 c.Request.Context() 传给下游时也能拿到
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), l))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if skip(cfg.SkipPaths, c.Request.URL.Path) {
+			return
+		}
+		if status < http.StatusInternalServerError && cfg.SampleRate < 1 && mrand.Float64() >= cfg.SampleRate {
+			return
+		}
+		kv := []interface{}{
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"path", c.Request.URL.Path,
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+		}
+		if username, ok := c.Get("username"); ok {
+			kv = append(kv, "user", username)
+		}
+		if len(c.Errors) > 0 {
+			kv = append(kv, "errors", c.Errors.String())
+		}
+		switch {
+		case status >= http.StatusInternalServerError:
+			l.Error("request", kv...)
+		case status >= http.StatusBadRequest:
+			l.Warn("request", kv...)
+		default:
+			l.Info("request", kv...)
+		}
+	}
+}
+
+// FromGin 取出当前请求的 Logger，没有使用 Middleware 时返回 Default
+func FromGin(c *gin.Context) *Logger {
+	if l, ok := c.Get(loggerKey); ok {
+		return l.(*Logger)
+	}
+	return FromContext(c.Request.Context())
+}
+
+func skip(paths []string, path string) bool {
+	for _, p := range paths {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// validRequestID 只透传长度合理、由可见 ASCII 字符组成的请求 ID，防止日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
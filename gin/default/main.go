@@ -1,42 +1,76 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-contrib/multitemplate"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/testdata/protoexample"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"golang.org/x/sync/errgroup"
 	"html/template"
 	"log"
+	"main/auth"
+	"main/cache"
+	"main/client"
+	"main/compress"
+	"main/gateway"
+	"main/logging"
+	"main/metrics"
+	"main/negotiate"
+	"main/openapi"
+	"main/ratelimit"
+	"main/realtime"
+	"main/security"
+	"main/storage"
+	"main/tracing"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 )
 
+// 根据 apiDocs 中注册的路由生成客户端，接口改动后重新执行 go generate
+//go:generate go run ./cmd/ginclient -func apiDocs -o client/client_gen.go .
+
 // restful 示例
 func restFul() {
 	r := gin.Default()
-	r.GET("/book", func(c *gin.Context) {
+	// GET 的响应缓存 30 秒，写操作之后按路径前缀让缓存失效
+	// 多实例部署时换成 cache.NewRedisStore(rdb, "")
+	bookCache := cache.New(cache.Config{Store: cache.NewMemoryStore(1000), TTL: 30 * time.Second})
+	invalidate := func(c *gin.Context) {
+		c.Next()
+		if _, err := bookCache.Invalidate(c.Request.Context(), "/book"); err != nil {
+			c.Error(err)
+		}
+	}
+	r.GET("/book", bookCache.Middleware(), func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "GET",
 		})
 	})
 
-	r.POST("/book", func(c *gin.Context) {
+	r.POST("/book", invalidate, func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "POST",
 		})
 	})
 
-	r.PUT("/book", func(c *gin.Context) {
+	r.PUT("/book", invalidate, func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "PUT",
 		})
 	})
 
-	r.DELETE("/book", func(c *gin.Context) {
+	r.DELETE("/book", invalidate, func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "DELETE",
 		})
@@ -47,8 +81,12 @@ func restFul() {
 func htmlRender() {
 	r := gin.Default()
 	r.LoadHTMLGlob("templates/**/*")
+	// 根据 Accept-Encoding 使用 br 或 gzip 压缩，小于 1KB 的响应不压缩
+	r.Use(compress.New(compress.Config{}))
 	// r.loadHTMLFiles("templates/posts/index.html", "templates/users/index.html")
-	r.GET("/posts/index", func(c *gin.Context) {
+	// 模板渲染的结果缓存 1 分钟，客户端带 If-None-Match 时直接返回 304
+	pageCache := cache.New(cache.Config{Store: cache.NewMemoryStore(100), TTL: time.Minute})
+	r.GET("/posts/index", pageCache.Middleware(), func(c *gin.Context) {
 		c.HTML(http.StatusOK, "posts/index.html", gin.H{
 			"title": "posts/index",
 		})
@@ -178,6 +216,15 @@ func xml() {
 		// 将输出被 protoexample.Test protobuf 序列化了的数据
 		c.ProtoBuf(http.StatusOK, data)
 	})
+
+	// 同一份数据不再需要分别注册多个接口，根据 Accept 头或者 ?format= 参数选择输出格式
+	// curl -H 'Accept: application/x-yaml' localhost:8080/message
+	// curl localhost:8080/message?format=xml
+	// 都不匹配时返回 406 Not Acceptable
+	negotiate.Register(negotiate.CSV) // 可以注册额外的格式，例如 CSV、msgpack
+	r.GET("/message", func(c *gin.Context) {
+		negotiate.Render(c, http.StatusOK, gin.H{"message": "ok", "status": http.StatusOK})
+	})
 	r.Run(":8080")
 }
 
@@ -299,6 +346,76 @@ func pathPara() {
 	r.Run(":8080")
 }
 
+// 接口文档
+// 根据路由注册和参数绑定结构体生成 OpenAPI 3 文档，访问 /openapi.json 或 /docs 查看
+func apiDocs() {
+	r := gin.Default()
+	doc := openapi.New("gin demo", "1.0.0")
+	// 按照文档校验请求参数，可选
+	r.Use(doc.Validator())
+	doc.Register(r)
+
+	doc.Handle(r, http.MethodPost, "/loginJSON", openapi.Operation{
+		Summary: "登录",
+		Tags:    []string{"user"},
+		Request: Login{},
+	}, func(c *gin.Context) {
+		var login Login
+		if err := c.ShouldBind(&login); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"user": login.User})
+	})
+	// 路由组中注册，文档里使用完整路径 /user/search/{username}/{address}
+	userGroup := r.Group("/user")
+	doc.Handle(userGroup, http.MethodGet, "/search/:username/:address", openapi.Operation{
+		Summary: "搜索用户",
+		Tags:    []string{"user"},
+	}, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "ok",
+			"username": c.Param("username"),
+			"address":  c.Param("address"),
+		})
+	})
+	// 直接注册的路由也会出现在文档里，只是没有参数说明
+	r.GET("/book", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "GET"})
+	})
+	r.Run(":8080")
+}
+
+// 使用生成的客户端调用接口文档中的接口
+// 不再需要手动拼 URL、设置 Content-Type 和解析 JSON，服务端返回的 {"error": "..."} 会变成 *client.APIError
+func sdkClient() {
+	go apiDocs()
+	time.Sleep(time.Second)
+
+	cli := client.New("http://127.0.0.1:8080", &http.Client{Timeout: 5 * time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := cli.PostLoginJSON(ctx, &client.Login{User: "jason", Password: "123"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("login: %+v\n", user)
+
+	result, err := cli.GetUserSearchUsernameAddress(ctx, "小王子", "沙河")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("search: %+v\n", result)
+
+	// 缺少必填的 password，文档校验中间件返回 400
+	_, err = cli.PostLoginJSON(ctx, &client.Login{User: "jason"})
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		fmt.Printf("status: %d, error: %s\n", apiErr.StatusCode, apiErr.Message)
+	}
+}
+
 // 文件上传
 func fileUp () {
 	router := gin.Default()
@@ -354,6 +471,41 @@ func multiFileUp() {
 	router.Run(":8080")
 }
 
+// 可替换存储后端的文件上传
+// 上传内容以流的方式直接写入存储，不会像 c.FormFile 那样先缓存在内存中
+func storageUp() {
+	signer := &storage.Signer{BaseURL: "http://127.0.0.1:8080/download", Secret: []byte("夏天夏天悄悄过去")}
+	// 本地磁盘
+	local, err := storage.NewLocal("./uploads", signer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var store storage.Storage = local
+	// S3 兼容存储，例如本地启动的 MinIO
+	//store, err = storage.NewS3(storage.S3Config{
+	//	Endpoint:  "http://127.0.0.1:9000",
+	//	Bucket:    "uploads",
+	//	AccessKey: "minioadmin",
+	//	SecretKey: "minioadmin",
+	//})
+	// 内存，一般用于测试
+	//store = storage.NewMemory(signer)
+
+	router := gin.Default()
+	router.POST("/upload", storage.UploadHandler(store, "file", nil))
+	router.GET("/download/*key", storage.DownloadHandler(store, signer))
+	// 生成一个10分钟后失效的下载地址
+	router.GET("/link/*key", func(c *gin.Context) {
+		link, err := store.SignedURL(c, strings.TrimPrefix(c.Param("key"), "/"), 10*time.Minute)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"url": link})
+	})
+	router.Run(":8080")
+}
+
 // 重定向
 func redirect() {
 	r := gin.Default()
@@ -395,22 +547,29 @@ func redirect() {
 
 
 // 中间件
-// Gin中的中间件必须是一个gin.HandlerFunc类型。例如我们像下面的代码一样定义一个统计请求耗时的中间件。
-// StatCost 是一个统计耗时请求耗时的中间件
-func StatCost() gin.HandlerFunc {
+// Gin中的中间件必须是一个gin.HandlerFunc类型。
+// RequestLog 是一个记录请求日志的中间件，替代了原来只打印耗时的 StatCost：
+// 为每个请求分配或透传 X-Request-ID，请求结束后以 JSON 格式记录路由模板、状态码、耗时、响应大小和用户
+func RequestLog() gin.HandlerFunc {
+	// 中间件内部：
+	// c.Set 在请求上下文中设置值，后续的处理函数能够取到该值，这里保存的是带有请求 ID 的 logger
+	// c.Next() 调用该请求的剩余处理程序，c.Abort() 不调用该请求的剩余处理程序
+
+	//gin中间件中使用goroutine
+	//当在中间件或handler中启动新的goroutine时，不能使用原始的上下文（c *gin.Context），必须使用其只读副本（c.Copy()）。
+	return logging.Middleware(logging.Config{
+		// 健康检查之类的请求太多，不记录访问日志
+		SkipPaths: []string{"/healthz", "/readyz"},
+		// 只记录一半的请求，5xx 的请求总是会记录
+		//SampleRate: 0.5,
+	})
+}
+
+// Mark 打印经过了哪个中间件，用来演示为单个路由和路由组注册中间件，日志带有 RequestLog 分配的 request_id
+func Mark(name string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		start := time.Now()
-		c.Set("name", "jason")// 可以通过c.Set在请求上下文中设置值，后续的处理函数能够取到该值
-		// 调用该请求的剩余处理程序
+		logging.FromGin(c).Info("enter middleware", "name", name)
 		c.Next()
-		// 不调用该请求的剩余处理程序
-		// c.Abort()
-		// 计算耗时
-		cost := time.Since(start)
-		log.Println(cost)
-
-		//gin中间件中使用goroutine
-		//当在中间件或handler中启动新的goroutine时，不能使用原始的上下文（c *gin.Context），必须使用其只读副本（c.Copy()）。
 	}
 }
 // 添加中间件
@@ -424,20 +583,19 @@ func middleware() {
 	//如果不想使用上面两个默认的中间件，可以使用gin.New()新建一个没有任何默认中间件的路由。
 	r := gin.New()
 	// 注册一个全局中间件
-	r.Use(StatCost())
+	r.Use(RequestLog())
 
 	r.GET("/test", func(c *gin.Context) {
-		name := c.MustGet("name").(string) // 从上下文取值
-		log.Println(name)
+		// 从上下文取出 logger，打印的日志和访问日志带有同一个 request_id
+		logging.FromGin(c).Info("hello", "name", "jason")
 		c.JSON(http.StatusOK, gin.H{
 			"message": "hello world!",
 		})
 	})
 
 	// 为单个路由注册
-	r.GET("/test2", StatCost(), func(c *gin.Context){
-		name := c.MustGet("name").(string) // 从上下文取值
-		log.Println(name)
+	r.GET("/test2", Mark("test2"), func(c *gin.Context){
+		logging.FromGin(c).Info("hello", "name", "jason")
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Hello world!",
 		})
@@ -445,7 +603,7 @@ func middleware() {
 
 	// 路由组注册
 	// 写法1
-	shopGroup := r.Group("/shop", StatCost())
+	shopGroup := r.Group("/shop", Mark("shop"))
 	{
 		shopGroup.GET("/index", func(c *gin.Context) {
 
@@ -453,7 +611,7 @@ func middleware() {
 	}
 	// 写法2
 	shopGroup2 := r.Group("/shop2")
-	shopGroup2.Use(StatCost())
+	shopGroup2.Use(Mark("shop2"))
 	{
 		shopGroup2.GET("/index", func(c *gin.Context) {
 
@@ -463,14 +621,133 @@ func middleware() {
 }
 
 
+// 限流
+func rateLimit() {
+	r := gin.Default()
+	// 单实例可以直接用内存存储，多实例部署时换成 Redis 共享配额
+	//rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	//store := ratelimit.NewRedisStore(rdb, "")
+	store := ratelimit.NewMemoryStore()
+	// 全局按 IP 限流：令牌桶，每秒 10 个请求，允许 20 个的突发
+	r.Use(ratelimit.New(ratelimit.Config{
+		Store:     store,
+		Algorithm: ratelimit.TokenBucket,
+		Limit:     ratelimit.Limit{Rate: 10, Period: time.Second, Burst: 20},
+		Prefix:    "ip:",
+	}))
+	// 上传接口按用户（JWTAuthMiddleware 写入的 username）限流：滑动窗口，每分钟 5 次
+	r.POST("/upload", ratelimit.New(ratelimit.Config{
+		Store:     store,
+		Algorithm: ratelimit.SlidingWindow,
+		Limit:     ratelimit.PerMinute(5),
+		KeyFunc:   ratelimit.ByUser,
+		Prefix:    "upload:",
+	}), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "ok"})
+	})
+	r.Run(":8080")
+}
+
+// 链路追踪
+// 取代手动在 context 中传递 TraceCode 的方式（见 context/WithValue.go），由中间件自动创建 span 并通过 traceparent 头向下游传递
+func tracingDemo() {
+	// 这里把 span 打印到标准输出，生产环境换成 OTLP、Jaeger 等 exporter
+	exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+	if err != nil {
+		log.Fatal(err)
+	}
+	tp := tracing.Init("gin-demo", exporter)
+	defer tp.Shutdown(context.Background())
+
+	// go-redis 的命令会成为当前请求的子 span
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	rdb.AddHook(tracing.RedisHook{})
+	// sqlx 需要换成包装后的 DB：db := tracing.WrapDB(sqlx.MustConnect("mysql", dsn))
+	// 调用下游 HTTP 服务时使用带 Transport 的 client，会自动注入 traceparent 头
+	client := tracing.NewClient(nil)
+
+	r := gin.Default()
+	r.Use(tracing.Middleware("gin-demo"))
+	r.GET("/user/search/:username/:address", func(c *gin.Context) {
+		// 必须使用 c.Request.Context()，其中带有当前请求的 span
+		ctx := c.Request.Context()
+		rdb.Get(ctx, c.Param("username"))
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1:8001/", nil)
+		if resp, err := client.Do(req); err == nil {
+			resp.Body.Close()
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "ok",
+			"trace_id": tracing.TraceID(ctx),
+		})
+	})
+	r.Run(":8080")
+}
+
+// 跨域、安全响应头和受信任代理
+func securityDemo() {
+	r := gin.Default()
+	// 只信任负载均衡所在网段转发的 X-Forwarded-For，其他来源直接使用连接地址，c.ClientIP() 不会被伪造
+	if err := security.TrustProxies(r, []string{"10.0.0.0/8", "127.0.0.1/32"}); err != nil {
+		log.Fatal(err)
+	}
+	headers := security.DefaultHeaders()
+	r.Use(security.Middlewares(security.Config{
+		CORS: &security.CORSConfig{
+			AllowOrigins:     []string{"https://www.example.com", "https://*.example.com"},
+			ExposeHeaders:    []string{"X-Request-ID"},
+			AllowCredentials: true,
+			// 浏览器 12 小时内不再重复发送预检请求
+			MaxAge: 12 * time.Hour,
+		},
+		Headers: &headers,
+	})...)
+	r.GET("/ip", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"client_ip": c.ClientIP(), "https": security.IsHTTPS(c)})
+	})
+	r.Run(":8080")
+}
+
+// 实时推送：SSE 和 WebSocket
+func realtimeDemo() {
+	r := gin.Default()
+	// SSE：浏览器 new EventSource("/events")，断线重连时自动带上 Last-Event-ID 补发错过的事件
+	broker := realtime.NewBroker(realtime.BrokerConfig{History: 100, Retry: 3 * time.Second})
+	r.GET("/events", broker.Handler())
+	r.POST("/events", func(c *gin.Context) {
+		id := broker.Publish("message", c.PostForm("data"))
+		c.JSON(http.StatusOK, gin.H{"id": id})
+	})
+
+	// WebSocket：new WebSocket("ws://localhost:8080/ws?token=...")，发送 {"type":"join","room":"lobby"} 加入房间
+	// token 由 jwt 模块的 /auth 签发，两边使用同一个密钥
+	// 浏览器不能设置 Authorization 头，TokenFromQuery 把 ?token= 转成 Bearer 头
+	j := auth.New([]byte("夏天夏天悄悄过去"))
+	hub := realtime.NewHub(realtime.HubConfig{UserKey: auth.UserKey})
+	defer hub.Close()
+	r.GET("/ws", realtime.TokenFromQuery("token"), j.Middleware(), hub.Handler())
+	r.POST("/rooms/:room", func(c *gin.Context) {
+		data, _ := json.Marshal(c.PostForm("data"))
+		if err := hub.Broadcast(c.Param("room"), realtime.Message{Type: "message", Room: c.Param("room"), Data: data}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "ok", "rooms": hub.Rooms()})
+	})
+	r.Run(":8080")
+}
+
+// errShutdown 收到退出信号，不算错误
+var errShutdown = errors.New("shutdown")
 
-// 在多个端口运行多个服务
-var (
-	g errgroup.Group
-)
 func router01() http.Handler {
 	e := gin.New()
-	e.Use(gin.Recovery())
+	// 每个服务单独一份指标，通过 /metrics 暴露给 Prometheus 抓取
+	// 两个端口是同一个服务，指标名相同，用 server 标签区分，方便聚合
+	m := metrics.New("gin", prometheus.Labels{"server": "server01"})
+	// promhttp 自己会压缩 /metrics，这里跳过
+	e.Use(gin.Recovery(), m.Middleware(), compress.New(compress.Config{ExcludedPaths: []string{"/metrics"}}))
+	e.GET("/metrics", m.Handler())
 	e.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 				"code":  http.StatusOK,
@@ -481,7 +758,9 @@ func router01() http.Handler {
 }
 func router02() http.Handler {
 	e := gin.New()
-	e.Use(gin.Recovery())
+	m := metrics.New("gin", prometheus.Labels{"server": "server02"})
+	e.Use(gin.Recovery(), m.Middleware(), compress.New(compress.Config{ExcludedPaths: []string{"/metrics"}}))
+	e.GET("/metrics", m.Handler())
 	e.GET("/", func(c *gin.Context) {
 		c.JSON(
 			http.StatusOK,
@@ -494,6 +773,9 @@ func router02() http.Handler {
 
 	return e
 }
+// 在多个端口运行多个服务
+// 收到 SIGINT/SIGTERM 或者其中一个服务出错退出时，关闭所有服务
+// 需要管理更多组件（TCP 服务、后台任务）和每个组件的关闭超时时，见 graceful/lifecycle
 func multiServer() {
 	server01 := &http.Server{
 		Addr:         ":8080",
@@ -509,15 +791,108 @@ func multiServer() {
 		WriteTimeout: 10 * time.Second,
 	}
 	// 借助errgroup.Group或者自行开启两个goroutine分别启动两个服务
+	// 任意一个函数返回错误时 ctx 会被取消
+	g, ctx := errgroup.WithContext(context.Background())
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
 	g.Go(func() error {
-		return server01.ListenAndServe()
+		select {
+		case sig := <-quit:
+			log.Println("received", sig, "shutting down servers ...")
+			// 返回错误让 errgroup 取消 ctx，触发下面的 Shutdown
+			return errShutdown
+		case <-ctx.Done():
+			return nil
+		}
 	})
-	g.Go(func() error {
-		return server02.ListenAndServe()
+	for _, srv := range []*http.Server{server01, server02} {
+		srv := srv
+		g.Go(func() error {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				return err
+			}
+			return nil
+		})
+		g.Go(func() error {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return srv.Shutdown(shutdownCtx)
+		})
+	}
+	if err := g.Wait(); err != nil && err != errShutdown {
+		log.Fatal(err)
+	}
+	log.Println("servers exiting")
+}
+
+// API 网关：先启动 multiServer，网关监听 :8000，按 Host 和路径前缀转发到 :8080、:8081
+// curl localhost:8000/api/           轮询转发到两个服务的 /
+// curl -X POST localhost:8000/login -d username=jason -d password=123 拿到 token
+// curl -H 'Host: admin.localhost' -H 'Authorization: Bearer <token>' localhost:8000/metrics
+func gatewayDemo() {
+	// 和 jwt 模块使用同一个密钥时，那边签发的 token 在这里也能通过
+	j := auth.New([]byte("夏天夏天悄悄过去"))
+	g, err := gateway.New(gateway.Config{
+		Routes: []gateway.Route{
+			{
+				Name:        "api",
+				Prefix:      "/api",
+				StripPrefix: true,
+				Upstreams:   []string{"http://127.0.0.1:8080", "http://127.0.0.1:8081"},
+				Balancer:    gateway.LeastConn,
+				// 不把 Cookie 转给上游，隐藏上游的 Server 头
+				RemoveRequestHeaders:  []string{"Cookie"},
+				SetResponseHeaders:    map[string]string{"X-Gateway": "gin"},
+				RemoveResponseHeaders: []string{"Server"},
+			},
+			{
+				// 管理后台需要登录，上游通过 X-Auth-User 拿到用户名
+				Name:      "admin",
+				Host:      "admin.localhost",
+				Upstreams: []string{"http://127.0.0.1:8081"},
+				Auth:      true,
+			},
+		},
+		HealthCheck: gateway.HealthCheck{Interval: 5 * time.Second, Path: "/"},
 	})
-	if err := g.Wait(); err != nil {
+	if err != nil {
 		log.Fatal(err)
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g.Start(ctx)
+
+	r := gin.Default()
+	// 网关自己的路由优先于转发
+	r.POST("/login", func(c *gin.Context) {
+		var user struct {
+			Username string `form:"username" binding:"required"`
+			Password string `form:"password" binding:"required"`
+		}
+		if err := c.ShouldBind(&user); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// 和 jwt 模块中的 authHandler 一样，示例中写死用户名和密码
+		if user.Username != "jason" || user.Password != "123" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
+			return
+		}
+		token, err := j.GenToken(user.Username)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"token": token})
+	})
+	// 状态中包含上游地址，需要登录
+	r.GET("/gateway/status", j.Middleware(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, g.Status())
+	})
+	g.Mount(r, j.Middleware())
+	r.Run(":8000")
 }
 
 func main() {
@@ -562,17 +937,41 @@ func main() {
 	// 参数绑定
 	//paraBind()
 
+	// 接口文档
+	//apiDocs()
+
+	// 生成的客户端
+	//sdkClient()
+
 	// 文件上传
 	//fileUp()
 
 	// 多文件上传
 	//multiFileUp()
 
+	// 可替换存储后端的文件上传
+	//storageUp()
+
 	// 重定向
 	//redirect()
 
 	// 中间件
 	//middleware()
 
+	// 限流
+	//rateLimit()
+
+	// 链路追踪
+	//tracingDemo()
+
+	// 跨域和安全响应头
+	//securityDemo()
+
+	// 实时推送
+	//realtimeDemo()
+
+	// API 网关
+	//gatewayDemo()
+
 	multiServer()
 }
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute 没有匹配到路由的请求（404）统一用这个标签，避免原始路径导致标签数量失控
+const unmatchedRoute = "unmatched"
+
+// Metrics 一个 gin 服务的 HTTP 指标，每个实例使用独立的 Registry
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inflight *prometheus.GaugeVec
+}
+
+// New 创建指标并注册 Go 运行时和进程指标，namespace 为指标名前缀，可以为空
+// 同一个服务的多个实例要使用相同的 namespace，需要区分时用 constLabels（例如 {"server": "server01"}）
+// 或者 Prometheus 抓取配置中的 job/instance，constLabels 会加到这个 Registry 的所有指标上
+func New(namespace string, constLabels prometheus.Labels) *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Total number of HTTP requests by route template, method and status.",
+		}, []string{"route", "method", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route template, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		inflight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests currently being served by route template and method.",
+		}, []string{"route", "method"}),
+	}
+	prometheus.WrapRegistererWith(constLabels, m.Registry).MustRegister(
+		m.requests,
+		m.latency,
+		m.inflight,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Middleware 记录请求数、耗时和正在处理的请求数，标签使用路由模板（如 /user/search/:username/:address）而不是原始路径
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+		inflight := m.inflight.WithLabelValues(route, method)
+		inflight.Inc()
+		start := time.Now()
+		defer func() {
+			// 放在 defer 中，handler panic 后也能正确减少 in-flight
+			inflight.Dec()
+			status := c.Writer.Status()
+			// 外层的 Recovery 在这之后才写 500，此时状态码还是默认的 200，按 500 记录后继续 panic 交给它处理
+			r := recover()
+			if r != nil && !c.Writer.Written() {
+				status = http.StatusInternalServerError
+			}
+			code := strconv.Itoa(status)
+			m.requests.WithLabelValues(route, method, code).Inc()
+			m.latency.WithLabelValues(route, method, code).Observe(time.Since(start).Seconds())
+			if r != nil {
+				panic(r)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// Handler 以 Prometheus 文本格式输出指标，一般注册为 r.GET("/metrics", m.Handler())
+func (m *Metrics) Handler() gin.HandlerFunc {
+	h := promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})
+	return gin.WrapH(h)
+}
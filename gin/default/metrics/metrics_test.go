@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New("test", prometheus.Labels{"server": "a"})
+	r := gin.New()
+	r.Use(gin.CustomRecoveryWithWriter(ioutil.Discard, func(c *gin.Context, err interface{}) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}), m.Middleware())
+	r.GET("/metrics", m.Handler())
+	r.GET("/user/search/:username/:address", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	for _, path := range []string{"/user/search/jason/beijing", "/user/search/q1mi/shanghai", "/nope", "/panic"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`test_http_requests_total{method="GET",route="/user/search/:username/:address",server="a",status="200"} 2`,
+		`test_http_requests_total{method="GET",route="unmatched",server="a",status="404"} 1`,
+		`test_http_requests_total{method="GET",route="/panic",server="a",status="500"} 1`,
+		`test_http_requests_in_flight{method="GET",route="/metrics",server="a"} 1`,
+		`test_http_request_duration_seconds_bucket{method="GET",route="/user/search/:username/:address",server="a",status="200",le="+Inf"} 2`,
+		`go_goroutines{server="a"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package negotiate
+
+import (
+	"encoding/csv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+	"github.com/golang/protobuf/proto"
+)
+
+// Format 一种响应格式
+type Format struct {
+	// Name 用于 ?format= 参数，例如 json
+	Name string
+	// MIMETypes 匹配 Accept 头的类型，第一个作为响应的 Content-Type
+	MIMETypes []string
+	// Supports 判断 obj 能否以这种格式输出，为 nil 表示都可以
+	Supports func(obj interface{}) bool
+	Render   func(c *gin.Context, status int, obj interface{})
+}
+
+func (f Format) supports(obj interface{}) bool {
+	return f.Supports == nil || f.Supports(obj)
+}
+
+// 内置的格式
+var (
+	JSON = Format{
+		Name:      "json",
+		MIMETypes: []string{gin.MIMEJSON},
+		Render:    func(c *gin.Context, status int, obj interface{}) { c.JSON(status, obj) },
+	}
+	XML = Format{
+		Name:      "xml",
+		MIMETypes: []string{gin.MIMEXML, gin.MIMEXML2},
+		Render:    func(c *gin.Context, status int, obj interface{}) { c.XML(status, obj) },
+	}
+	YAML = Format{
+		Name:      "yaml",
+		MIMETypes: []string{binding.MIMEYAML, "application/yaml", "text/yaml"},
+		Render:    func(c *gin.Context, status int, obj interface{}) { c.YAML(status, obj) },
+	}
+	// ProtoBuf 只能输出实现了 proto.Message 的数据
+	ProtoBuf = Format{
+		Name:      "protobuf",
+		MIMETypes: []string{binding.MIMEPROTOBUF, "application/protobuf"},
+		Supports: func(obj interface{}) bool {
+			_, ok := obj.(proto.Message)
+			return ok
+		},
+		Render: func(c *gin.Context, status int, obj interface{}) { c.ProtoBuf(status, obj) },
+	}
+	// MsgPack 默认没有注册，需要时调用 Register(negotiate.MsgPack)
+	MsgPack = Format{
+		Name:      "msgpack",
+		MIMETypes: []string{binding.MIMEMSGPACK2, binding.MIMEMSGPACK},
+		Render: func(c *gin.Context, status int, obj interface{}) {
+			c.Render(status, render.MsgPack{Data: obj})
+		},
+	}
+	// CSV 默认没有注册，只能输出 [][]string 或实现了 CSVMarshaler 的数据
+	CSV = Format{
+		Name:      "csv",
+		MIMETypes: []string{"text/csv"},
+		Supports: func(obj interface{}) bool {
+			switch obj.(type) {
+			case [][]string, CSVMarshaler:
+				return true
+			}
+			return false
+		},
+		Render: renderCSV,
+	}
+)
+
+// CSVMarshaler 可以转换为 CSV 行的数据
+type CSVMarshaler interface {
+	MarshalCSV() [][]string
+}
+
+func renderCSV(c *gin.Context, status int, obj interface{}) {
+	rows, ok := obj.([][]string)
+	if m, isMarshaler := obj.(CSVMarshaler); !ok && isMarshaler {
+		rows = m.MarshalCSV()
+	}
+	c.Status(status)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	w := csv.NewWriter(c.Writer)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		c.Error(err)
+	}
+}
+
+// Negotiator 根据 ?format= 参数或 Accept 头选择响应格式
+type Negotiator struct {
+	mu      sync.RWMutex
+	formats []Format
+}
+
+// New 创建 Negotiator，formats 按优先级排列，Accept 为空或 */* 时使用第一个能输出的格式
+func New(formats ...Format) *Negotiator {
+	n := &Negotiator{}
+	for _, f := range formats {
+		n.Register(f)
+	}
+	return n
+}
+
+// Register 注册格式，同名的格式会被替换
+func (n *Negotiator) Register(f Format) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i := range n.formats {
+		if n.formats[i].Name == f.Name {
+			n.formats[i] = f
+			return
+		}
+	}
+	n.formats = append(n.formats, f)
+}
+
+// Render 选择格式并输出 obj，没有可用的格式时返回 406 Not Acceptable
+func (n *Negotiator) Render(c *gin.Context, status int, obj interface{}) {
+	// 用 Add 保留压缩等中间件已经设置的 Vary
+	c.Writer.Header().Add("Vary", "Accept")
+	f, ok := n.choose(c.Query("format"), c.GetHeader("Accept"), obj)
+	if !ok {
+		c.String(http.StatusNotAcceptable, "not acceptable, supported formats: %s", strings.Join(n.names(obj), ", "))
+		c.Abort()
+		return
+	}
+	f.Render(c, status, obj)
+}
+
+func (n *Negotiator) names(obj interface{}) []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	var names []string
+	for _, f := range n.formats {
+		if f.supports(obj) {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+func (n *Negotiator) choose(format, accept string, obj interface{}) (Format, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	// ?format= 优先于 Accept 头
+	if format != "" {
+		for _, f := range n.formats {
+			if strings.EqualFold(f.Name, format) && f.supports(obj) {
+				return f, true
+			}
+		}
+		return Format{}, false
+	}
+	if strings.TrimSpace(accept) == "" {
+		accept = "*/*"
+	}
+	for _, r := range parseAccept(accept) {
+		if r.q <= 0 {
+			continue
+		}
+		for _, f := range n.formats {
+			if !f.supports(obj) {
+				continue
+			}
+			for _, mt := range f.MIMETypes {
+				if r.matches(mt) && !excluded(accept, mt) {
+					return f, true
+				}
+			}
+		}
+	}
+	return Format{}, false
+}
+
+// acceptRange Accept 头中的一项，例如 application/json;q=0.9
+type acceptRange struct {
+	typ, sub string
+	q        float64
+}
+
+func (r acceptRange) matches(mime string) bool {
+	parts := strings.SplitN(mime, "/", 2)
+	return (r.typ == "*" || r.typ == parts[0]) && (r.sub == "*" || r.sub == parts[1])
+}
+
+func (r acceptRange) specificity() int {
+	switch {
+	case r.typ == "*":
+		return 0
+	case r.sub == "*":
+		return 1
+	}
+	return 2
+}
+
+// parseAccept 解析 Accept 头，按 q 值从大到小、同 q 值时更具体的类型优先排序
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, item := range strings.Split(accept, ",") {
+		params := strings.Split(item, ";")
+		mime := strings.ToLower(strings.TrimSpace(params[0]))
+		parts := strings.SplitN(mime, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+		r := acceptRange{typ: parts[0], sub: parts[1], q: 1}
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64); err == nil {
+					r.q = q
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+// excluded 处理 Accept: */*, text/csv;q=0 这种显式排除某个类型的写法
+func excluded(accept, mime string) bool {
+	for _, r := range parseAccept(accept) {
+		if r.q <= 0 && r.specificity() == 2 && r.matches(mime) {
+			return true
+		}
+	}
+	return false
+}
+
+// Default 默认的 Negotiator，支持 JSON、XML、YAML、ProtoBuf
+var Default = New(JSON, XML, YAML, ProtoBuf)
+
+// Render 使用 Default 输出
+func Render(c *gin.Context, status int, obj interface{}) {
+	Default.Render(c, status, obj)
+}
+
+// Register 向 Default 注册格式
+func Register(f Format) {
+	Default.Register(f)
+}
+
@@ -0,0 +1,78 @@
+package negotiate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"main/compress"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/testdata/protoexample"
+)
+
+func TestRender(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	n := New(JSON, XML, YAML, ProtoBuf)
+	n.Register(CSV)
+	label := "test"
+	r := gin.New()
+	r.GET("/msg", func(c *gin.Context) {
+		n.Render(c, http.StatusOK, gin.H{"message": "ok"})
+	})
+	r.GET("/proto", func(c *gin.Context) {
+		n.Render(c, http.StatusOK, &protoexample.Test{Label: &label})
+	})
+	r.GET("/rows", func(c *gin.Context) {
+		n.Render(c, http.StatusOK, [][]string{{"name", "age"}, {"小王子", "18"}})
+	})
+
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		code   int
+		ctype  string
+	}{
+		{"no accept", "/msg", "", 200, "application/json; charset=utf-8"},
+		{"browser", "/msg", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", 200, "application/xml; charset=utf-8"},
+		{"q value", "/msg", "application/json;q=0.5, application/x-yaml", 200, "application/x-yaml; charset=utf-8"},
+		{"format override", "/msg?format=yaml", "application/json", 200, "application/x-yaml; charset=utf-8"},
+		{"not acceptable", "/msg", "text/html", 406, "text/plain; charset=utf-8"},
+		{"unknown format", "/msg?format=toml", "", 406, "text/plain; charset=utf-8"},
+		{"protobuf", "/proto", "application/x-protobuf", 200, "application/x-protobuf"},
+		{"protobuf unsupported", "/msg", "application/x-protobuf", 406, "text/plain; charset=utf-8"},
+		{"csv", "/rows", "text/csv", 200, "text/csv; charset=utf-8"},
+		{"excluded", "/rows", "*/*, application/json;q=0", 200, "application/xml; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.code || w.Header().Get("Content-Type") != tt.ctype {
+				t.Errorf("expected:%v %v, got:%v %v", tt.code, tt.ctype, w.Code, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestRenderKeepsVary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(compress.New(compress.Config{}))
+	r.GET("/msg", func(c *gin.Context) {
+		Render(c, http.StatusOK, gin.H{"message": "ok"})
+	})
+	req := httptest.NewRequest(http.MethodGet, "/msg", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if vary := w.Header().Values("Vary"); !reflect.DeepEqual(vary, []string{"Accept-Encoding", "Accept"}) {
+		t.Errorf("Vary = %v", vary)
+	}
+}
@@ -0,0 +1,82 @@
+package openapi
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// docsTemplate 内置的文档页面，不依赖外部的 Swagger UI 资源，内网环境也能打开
+var docsTemplate = template.Must(template.New("docs").Funcs(template.FuncMap{
+	"upper": strings.ToUpper,
+	"json": func(v interface{}) string {
+		b, _ := json.MarshalIndent(v, "", "  ")
+		return string(b)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>{{.Spec.Info.Title}}</title>
+<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 960px; color: #333; }
+details { border: 1px solid #ddd; border-radius: 4px; margin: .5em 0; padding: .5em 1em; }
+summary { cursor: pointer; }
+.method { display: inline-block; width: 5em; font-weight: bold; }
+.get { color: #61affe; } .post { color: #49cc90; } .put { color: #fca130; } .delete { color: #f93e3e; }
+pre { background: #f6f8fa; padding: .5em; overflow: auto; }
+table { border-collapse: collapse; } td, th { border: 1px solid #ddd; padding: .2em .6em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Spec.Info.Title}} <small>{{.Spec.Info.Version}}</small></h1>
+{{with .Spec.Info.Description}}<p>{{.}}</p>{{end}}
+<p><a href="openapi.json">openapi.json</a></p>
+{{range .Ops}}
+<details>
+<summary><span class="method {{.Method}}">{{upper .Method}}</span> <code>{{.Path}}</code> {{.Op.Summary}}</summary>
+{{with .Op.Description}}<p>{{.}}</p>{{end}}
+{{if .Op.Parameters}}
+<h4>Parameters</h4>
+<table>
+<tr><th>name</th><th>in</th><th>type</th><th>required</th></tr>
+{{range .Op.Parameters}}<tr><td>{{.Name}}</td><td>{{.In}}</td><td>{{.Schema.Type}}</td><td>{{.Required}}</td></tr>{{end}}
+</table>
+{{end}}
+{{with .Op.RequestBody}}
+<h4>Request body</h4>
+{{range $type, $media := .Content}}<p><code>{{$type}}</code></p><pre>{{json $media.Schema}}</pre>{{end}}
+{{end}}
+<h4>Responses</h4>
+<pre>{{json .Op.Responses}}</pre>
+</details>
+{{end}}
+{{with .Spec.Components.Schemas}}
+<h2>Schemas</h2>
+{{range $name, $schema := .}}<h4>{{$name}}</h4><pre>{{json $schema}}</pre>{{end}}
+{{end}}
+</body>
+</html>`))
+
+type docsOp struct {
+	Method string
+	Path   string
+	Op     *OperationObject
+}
+
+func renderDocs(c *gin.Context, spec *Spec) {
+	var ops []docsOp
+	for _, p := range sortedKeys(spec.Paths) {
+		for _, m := range sortedKeys(spec.Paths[p]) {
+			ops = append(ops, docsOp{Method: m, Path: p, Op: spec.Paths[p][m]})
+		}
+	}
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := docsTemplate.Execute(c.Writer, gin.H{"Spec": spec, "Ops": ops}); err != nil {
+		c.Error(err)
+	}
+}
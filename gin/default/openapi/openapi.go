@@ -0,0 +1,301 @@
+package openapi
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Spec OpenAPI 3 文档
+type Spec struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       Info                                   `json:"info"`
+	Paths      map[string]map[string]*OperationObject `json:"paths"`
+	Components Components                             `json:"components"`
+}
+
+// Info 文档基本信息
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Components 可复用的 Schema
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// OperationObject 一个路由（method + path）的描述
+type OperationObject struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter path 或 query 参数
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody 请求体
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// MediaType 某种 Content-Type 下的 Schema
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Response 响应
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Operation 注册路由时补充的接口信息
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+	// Request 参数绑定用的结构体，例如 Login{}，根据 uri、form、json、binding tag 生成参数和请求体
+	Request interface{}
+	// Response 200 响应的结构，可以为空
+	Response interface{}
+}
+
+// Router *gin.Engine 和 *gin.RouterGroup 都满足这个接口
+type Router interface {
+	BasePath() string
+	Handle(httpMethod, relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes
+}
+
+// Doc 收集路由和参数绑定结构体，生成 OpenAPI 文档
+// 所有注册到 gin 的路由都会出现在文档里，通过 Handle 或 Describe 补充了 Operation 的路由会带上参数和请求体
+type Doc struct {
+	Title       string
+	Version     string
+	Description string
+	// MaxBodyBytes 校验中间件读取请求体的大小限制，超过时返回 413，为 0 时使用 10MB
+	MaxBodyBytes int64
+
+	mu  sync.RWMutex
+	ops map[string]Operation
+	// 校验中间件使用的缓存，注册新的 Operation 后清空
+	compiled map[string]*compiled
+}
+
+// New 创建文档
+func New(title, version string) *Doc {
+	return &Doc{Title: title, Version: version, ops: make(map[string]Operation)}
+}
+
+func opKey(method, fullPath string) string {
+	return strings.ToUpper(method) + " " + fullPath
+}
+
+// Describe 为已经注册（或将要注册）的路由补充信息，fullPath 为完整的路由模板，例如 /user/search/:username/:address
+func (d *Doc) Describe(method, fullPath string, op Operation) {
+	d.mu.Lock()
+	d.ops[opKey(method, fullPath)] = op
+	d.compiled = nil
+	d.mu.Unlock()
+}
+
+// Handle 注册路由的同时补充信息，r 可以是 *gin.Engine 或路由组
+func (d *Doc) Handle(r Router, method, relativePath string, op Operation, handlers ...gin.HandlerFunc) gin.IRoutes {
+	d.Describe(method, joinPaths(r.BasePath(), relativePath), op)
+	return r.Handle(method, relativePath, handlers...)
+}
+
+// joinPaths 与 gin 内部拼接路由组路径的方式一致，保留末尾的 /
+func joinPaths(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	p := path.Join(base, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p
+}
+
+func (d *Doc) operation(method, fullPath string) (Operation, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	op, ok := d.ops[opKey(method, fullPath)]
+	return op, ok
+}
+
+// Spec 根据 routes（一般是 r.Routes()）生成文档
+func (d *Doc) Spec(routes gin.RoutesInfo) *Spec {
+	spec := &Spec{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: d.Title, Version: d.Version, Description: d.Description},
+		Paths:   make(map[string]map[string]*OperationObject),
+	}
+	reg := newSchemaRegistry()
+	for _, route := range routes {
+		op, _ := d.operation(route.Method, route.Path)
+		p := openAPIPath(route.Path)
+		if spec.Paths[p] == nil {
+			spec.Paths[p] = make(map[string]*OperationObject)
+		}
+		spec.Paths[p][strings.ToLower(route.Method)] = buildOperation(reg, route.Method, route.Path, op)
+	}
+	if len(reg.schemas) > 0 {
+		spec.Components.Schemas = reg.schemas
+	}
+	return spec
+}
+
+var paramPattern = regexp.MustCompile(`[:*]([^/]+)`)
+
+// openAPIPath 把 gin 的 /user/:name/*action 转成 /user/{name}/{action}
+func openAPIPath(p string) string {
+	return paramPattern.ReplaceAllString(p, "{$1}")
+}
+
+// operationID 例如 GET /user/search/:username 生成 getUserSearchUsername
+func operationID(method, p string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	upper := true
+	for _, r := range p {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func buildOperation(reg *schemaRegistry, method, fullPath string, op Operation) *OperationObject {
+	o := &OperationObject{
+		OperationID: operationID(method, fullPath),
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Responses:   map[string]*Response{"200": {Description: "OK"}},
+	}
+
+	var reqType reflect.Type
+	if op.Request != nil {
+		reqType = reflect.TypeOf(op.Request)
+	}
+	// path 参数总是必填，类型优先使用结构体中 uri tag 对应的字段
+	uriFields := make(map[string]field)
+	for _, f := range reg.fields(reqType, "uri") {
+		uriFields[f.name] = f
+	}
+	for _, m := range paramPattern.FindAllStringSubmatch(fullPath, -1) {
+		schema := &Schema{Type: "string"}
+		if f, ok := uriFields[m[1]]; ok {
+			schema = f.schema
+		}
+		o.Parameters = append(o.Parameters, &Parameter{Name: m[1], In: "path", Required: true, Schema: schema})
+	}
+
+	if reqType != nil {
+		switch method {
+		case http.MethodGet, http.MethodDelete, http.MethodHead:
+			// ShouldBind 对这些方法使用 query 参数绑定
+			for _, f := range reg.fields(reqType, "form") {
+				o.Parameters = append(o.Parameters, &Parameter{Name: f.name, In: "query", Required: f.required, Schema: f.schema})
+			}
+		default:
+			body := &RequestBody{Content: make(map[string]MediaType)}
+			if hasTag(reqType, "json") {
+				body.Content[gin.MIMEJSON] = MediaType{Schema: reg.schemaOf(reqType)}
+			}
+			if hasTag(reqType, "form") {
+				form := reg.structSchema(derefType(reqType), "form")
+				body.Content[gin.MIMEPOSTForm] = MediaType{Schema: form}
+				body.Content[gin.MIMEMultipartPOSTForm] = MediaType{Schema: form}
+				body.Required = body.Required || len(form.Required) > 0
+			}
+			for _, f := range reg.fields(reqType, "json") {
+				body.Required = body.Required || f.required
+			}
+			// 只有 uri tag 的结构体没有请求体
+			if len(body.Content) > 0 {
+				o.RequestBody = body
+			}
+		}
+		o.Responses["400"] = &Response{Description: "Bad Request"}
+	}
+	if op.Response != nil {
+		o.Responses["200"].Content = map[string]MediaType{
+			gin.MIMEJSON: {Schema: reg.schemaOf(reflect.TypeOf(op.Response))},
+		}
+	}
+	return o
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// hasTag 结构体中是否有字段写了 tag
+func hasTag(t reflect.Type, tag string) bool {
+	t = derefType(t)
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if _, ok := sf.Tag.Lookup(tag); ok {
+			return true
+		}
+		if sf.Anonymous && hasTag(sf.Type, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Register 在 r 上注册 GET /openapi.json 和 GET /docs
+// 文档在每次请求时根据 r.Routes() 生成，之后注册的路由也会出现在文档里
+func (d *Doc) Register(r *gin.Engine) {
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, d.Spec(r.Routes()))
+	})
+	r.GET("/docs", func(c *gin.Context) {
+		renderDocs(c, d.Spec(r.Routes()))
+	})
+}
+
+// sortedKeys 返回 map 排好序的 key，文档页面按路径和方法排序展示
+func sortedKeys(m interface{}) []string {
+	v := reflect.ValueOf(m)
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
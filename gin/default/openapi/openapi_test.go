@@ -0,0 +1,141 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type login struct {
+	User     string `form:"user" json:"user" binding:"required"`
+	Password string `form:"password" json:"password" binding:"required,min=3"`
+}
+
+type search struct {
+	Page int `form:"page" binding:"min=1"`
+}
+
+func newRouter() (*gin.Engine, *Doc) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	doc := New("demo", "1.0")
+	r.Use(doc.Validator())
+	doc.Register(r)
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	doc.Handle(r, http.MethodPost, "/loginJSON", Operation{Summary: "登录", Request: login{}}, ok)
+	doc.Handle(r.Group("/user"), http.MethodGet, "/search/:username/:address", Operation{Request: search{}}, ok)
+	r.GET("/plain", ok)
+	return r, doc
+}
+
+func TestSpec(t *testing.T) {
+	r, doc := newRouter()
+	spec := doc.Spec(r.Routes())
+
+	if _, ok := spec.Paths["/plain"]["get"]; !ok {
+		t.Error("undescribed route missing from spec")
+	}
+	op := spec.Paths["/user/search/{username}/{address}"]["get"]
+	if op == nil || len(op.Parameters) != 3 || op.Parameters[0].In != "path" || op.Parameters[2].Name != "page" {
+		t.Fatalf("unexpected parameters: %s", toJSON(op))
+	}
+	login := spec.Paths["/loginJSON"]["post"]
+	if login.RequestBody.Content[gin.MIMEJSON].Schema.Ref != "#/components/schemas/login" {
+		t.Errorf("unexpected body: %s", toJSON(login.RequestBody))
+	}
+	schema := spec.Components.Schemas["login"]
+	if len(schema.Required) != 2 || *schema.Properties["password"].MinLength != 3 {
+		t.Errorf("unexpected schema: %s", toJSON(schema))
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "/loginJSON") {
+		t.Errorf("docs page: %d", w.Code)
+	}
+}
+
+func TestValidator(t *testing.T) {
+	r, _ := newRouter()
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"valid json", http.MethodPost, "/loginJSON", gin.MIMEJSON, `{"user":"jason","password":"123"}`, http.StatusOK},
+		{"missing field", http.MethodPost, "/loginJSON", gin.MIMEJSON, `{"user":"jason"}`, http.StatusBadRequest},
+		{"wrong type", http.MethodPost, "/loginJSON", gin.MIMEJSON, `{"user":1,"password":"123"}`, http.StatusBadRequest},
+		{"too short", http.MethodPost, "/loginJSON", gin.MIMEJSON, `{"user":"jason","password":"1"}`, http.StatusBadRequest},
+		{"valid form", http.MethodPost, "/loginJSON", gin.MIMEPOSTForm, `user=jason&password=123`, http.StatusOK},
+		{"missing form field", http.MethodPost, "/loginJSON", gin.MIMEPOSTForm, `user=jason`, http.StatusBadRequest},
+		{"valid query", http.MethodGet, "/user/search/jason/beijing?page=2", "", "", http.StatusOK},
+		{"bad query", http.MethodGet, "/user/search/jason/beijing?page=x", "", "", http.StatusBadRequest},
+		{"query out of range", http.MethodGet, "/user/search/jason/beijing?page=0", "", "", http.StatusBadRequest},
+		{"undescribed", http.MethodGet, "/plain?anything=1", "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("expected:%v, got:%v %s", tt.want, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestValidatorBodyLimit(t *testing.T) {
+	r, doc := newRouter()
+	doc.MaxBodyBytes = 64
+	body := `{"user":"jason","password":"` + strings.Repeat("x", 100) + `"}`
+	for _, contentType := range []string{gin.MIMEJSON, gin.MIMEPOSTForm} {
+		if contentType == gin.MIMEPOSTForm {
+			body = "user=jason&password=" + strings.Repeat("x", 100)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/loginJSON", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: expected 413, got %v %s", contentType, w.Code, w.Body)
+		}
+	}
+}
+
+// Location 和 time.Location 同名
+type Location struct {
+	City string `json:"city"`
+}
+
+func TestSchemaNameCollision(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	doc := New("demo", "1.0")
+	doc.Handle(r, http.MethodGet, "/where", Operation{Response: struct {
+		Local Location       `json:"local"`
+		Std   *time.Location `json:"std"`
+	}{}}, func(c *gin.Context) {})
+
+	schemas := doc.Spec(r.Routes()).Components.Schemas
+	local, std := schemas["Location"], schemas["time.Location"]
+	if len(schemas) != 2 || local == nil || std == nil || local.Properties["city"] == nil {
+		t.Fatalf("schemas = %s", toJSON(schemas))
+	}
+}
+
+func toJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
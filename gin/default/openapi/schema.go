@@ -0,0 +1,213 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema OpenAPI 3 的 Schema Object，只包含用到的字段
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+}
+
+// field 结构体中的一个可绑定字段
+type field struct {
+	name     string // 对应 tag 中的名称
+	required bool
+	schema   *Schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaRegistry 把命名的结构体放到 components.schemas 中，通过 $ref 引用
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	// names 类型对应的组件名，不同包中的同名类型使用不同的组件名
+	names map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// lookup 返回 $ref 引用的 Schema
+func (reg *schemaRegistry) lookup(ref string) *Schema {
+	return reg.schemas[strings.TrimPrefix(ref, "#/components/schemas/")]
+}
+
+// name 命名结构体的组件名，先注册的使用类型名，之后其他包中的同名类型加上包路径，例如 example.com.shop.User
+func (reg *schemaRegistry) name(t reflect.Type) (string, bool) {
+	if name, ok := reg.names[t]; ok {
+		return name, true
+	}
+	name := t.Name()
+	if _, taken := reg.schemas[name]; taken {
+		name = strings.NewReplacer("/", ".", "~", ".").Replace(t.PkgPath()) + "." + name
+	}
+	reg.names[t] = name
+	return name, false
+}
+
+// schemaOf 生成 t 的 Schema，命名结构体注册到 reg 中并返回引用
+func (reg *schemaRegistry) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: reg.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: reg.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		if t.Name() == "" {
+			return reg.structSchema(t, "json")
+		}
+		name, ok := reg.name(t)
+		if !ok {
+			// 先占位，防止递归引用自身时死循环
+			s := &Schema{}
+			reg.schemas[name] = s
+			*s = *reg.structSchema(t, "json")
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	// interface{} 等无法确定类型
+	return &Schema{}
+}
+
+// structSchema 根据 tag 生成结构体的 Schema，tag 为 json 或 form
+func (reg *schemaRegistry) structSchema(t reflect.Type, tag string) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, f := range reg.fields(t, tag) {
+		s.Properties[f.name] = f.schema
+		if f.required {
+			s.Required = append(s.Required, f.name)
+		}
+	}
+	return s
+}
+
+// fields 取出结构体中带有 tag 的字段，json 没有写 tag 时和 encoding/json 一样使用字段名
+// 匿名内嵌的结构体会被展开
+func (reg *schemaRegistry) fields(t reflect.Type, tag string) []field {
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		name := strings.Split(sf.Tag.Get(tag), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && name == "" {
+			fields = append(fields, reg.fields(sf.Type, tag)...)
+			continue
+		}
+		if name == "" {
+			if tag != "json" {
+				continue
+			}
+			name = sf.Name
+		}
+		schema := reg.schemaOf(sf.Type)
+		required := applyBinding(schema, sf.Tag.Get("binding"))
+		fields = append(fields, field{name: name, required: required, schema: schema})
+	}
+	return fields
+}
+
+// applyBinding 把 binding tag 中常用的规则转换为 Schema 约束，返回是否必填
+// 支持 required、min、max、len（字符串长度或数值范围）、oneof、email、url
+func applyBinding(s *Schema, binding string) (required bool) {
+	if binding == "" || s.Ref != "" {
+		return binding != "" && strings.Contains(","+binding+",", ",required,")
+	}
+	for _, rule := range strings.Split(binding, ",") {
+		kv := strings.SplitN(rule, "=", 2)
+		arg := ""
+		if len(kv) == 2 {
+			arg = kv[1]
+		}
+		switch kv[0] {
+		case "required":
+			required = true
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			if s.Type == "string" {
+				l := int(n)
+				if kv[0] != "max" {
+					s.MinLength = &l
+				}
+				if kv[0] != "min" {
+					s.MaxLength = &l
+				}
+			} else if s.Type == "integer" || s.Type == "number" {
+				if kv[0] != "max" {
+					s.Minimum = &n
+				}
+				if kv[0] != "min" {
+					s.Maximum = &n
+				}
+			}
+		case "oneof":
+			for _, v := range strings.Fields(arg) {
+				if s.Type == "integer" || s.Type == "number" {
+					if n, err := strconv.ParseFloat(v, 64); err == nil {
+						s.Enum = append(s.Enum, n)
+					}
+					continue
+				}
+				s.Enum = append(s.Enum, v)
+			}
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		}
+	}
+	return required
+}
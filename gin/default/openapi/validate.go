@@ -0,0 +1,308 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compiled 校验一个路由需要的信息，由 buildOperation 的结果缓存而来
+type compiled struct {
+	op  *OperationObject
+	reg *schemaRegistry
+}
+
+func (d *Doc) compile(method, fullPath string) *compiled {
+	key := opKey(method, fullPath)
+	d.mu.RLock()
+	cp, ok := d.compiled[key]
+	op, described := d.ops[key]
+	d.mu.RUnlock()
+	if ok {
+		return cp
+	}
+	if described {
+		reg := newSchemaRegistry()
+		cp = &compiled{op: buildOperation(reg, method, fullPath, op), reg: reg}
+	}
+	d.mu.Lock()
+	if d.compiled == nil {
+		d.compiled = make(map[string]*compiled)
+	}
+	d.compiled[key] = cp
+	d.mu.Unlock()
+	return cp
+}
+
+// Validator 按照文档校验请求：path、query 参数以及 JSON 和表单请求体
+// 没有通过 Handle/Describe 描述的路由不做校验；校验失败返回 400 {"error": "..."}
+func (d *Doc) Validator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cp := d.compile(c.Request.Method, c.FullPath())
+		if cp == nil {
+			c.Next()
+			return
+		}
+		max := d.MaxBodyBytes
+		if max <= 0 {
+			max = defaultMaxBodyBytes
+		}
+		if err := cp.validate(c, max); err != nil {
+			status := http.StatusBadRequest
+			if err == errBodyTooLarge {
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.Next()
+	}
+}
+
+// defaultMaxBodyBytes Doc.MaxBodyBytes 为 0 时请求体的大小限制
+const defaultMaxBodyBytes = 10 << 20
+
+var errBodyTooLarge = errors.New("request body too large")
+
+func (cp *compiled) validate(c *gin.Context, maxBody int64) error {
+	query := c.Request.URL.Query()
+	for _, p := range cp.op.Parameters {
+		var value string
+		var present bool
+		switch p.In {
+		case "path":
+			value = c.Param(p.Name)
+			present = value != ""
+		case "query":
+			_, present = query[p.Name]
+			value = query.Get(p.Name)
+		}
+		if !present {
+			if p.Required {
+				return fmt.Errorf("%s parameter %q is required", p.In, p.Name)
+			}
+			continue
+		}
+		if err := cp.checkString(p.Schema, value, p.Name); err != nil {
+			return err
+		}
+	}
+
+	body := cp.op.RequestBody
+	if body == nil {
+		return nil
+	}
+	// 校验需要读取整个请求体，限制大小，超过时返回 413
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBody)
+	contentType := c.ContentType()
+	if mt, ok := body.Content[contentType]; ok && contentType == gin.MIMEJSON {
+		return cp.validateJSON(c, mt.Schema, body.Required)
+	}
+	if mt, ok := body.Content[contentType]; ok && (contentType == gin.MIMEPOSTForm || contentType == gin.MIMEMultipartPOSTForm) {
+		return cp.validateForm(c, mt.Schema)
+	}
+	if c.Request.ContentLength == 0 && !body.Required {
+		return nil
+	}
+	return fmt.Errorf("unsupported content type %q", contentType)
+}
+
+// validateJSON 读取请求体校验后再放回去，后续的 ShouldBind 还能读取
+func (cp *compiled) validateJSON(c *gin.Context, schema *Schema, required bool) error {
+	data, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		if tooLarge(err) {
+			return errBodyTooLarge
+		}
+		return err
+	}
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if len(bytes.TrimSpace(data)) == 0 {
+		if required {
+			return fmt.Errorf("request body is required")
+		}
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("invalid JSON body: %v", err)
+	}
+	return cp.checkValue(schema, v, "body")
+}
+
+func (cp *compiled) validateForm(c *gin.Context, schema *Schema) error {
+	// 解析结果保存在 Request 中，后续绑定不需要再读请求体
+	// ParseMultipartForm 也会解析 urlencoded 表单，但是会丢掉 ParseForm 的错误，所以先单独调用
+	err := c.Request.ParseForm()
+	if err == nil {
+		err = c.Request.ParseMultipartForm(32 << 20)
+	}
+	if err != nil && err != http.ErrNotMultipart {
+		if tooLarge(err) {
+			return errBodyTooLarge
+		}
+		return fmt.Errorf("invalid form body: %v", err)
+	}
+	for _, name := range schema.Required {
+		if _, ok := c.Request.PostForm[name]; ok {
+			continue
+		}
+		if c.Request.MultipartForm != nil {
+			if _, ok := c.Request.MultipartForm.File[name]; ok {
+				continue
+			}
+		}
+		return fmt.Errorf("form field %q is required", name)
+	}
+	for name, s := range schema.Properties {
+		if vs, ok := c.Request.PostForm[name]; ok && len(vs) > 0 {
+			if err := cp.checkString(s, vs[0], name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tooLarge http.MaxBytesReader 超过限制时的错误，没有导出的错误类型，只能比较内容
+func tooLarge(err error) bool {
+	return strings.Contains(err.Error(), "request body too large")
+}
+
+func (cp *compiled) resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = cp.reg.lookup(s.Ref)
+	}
+	if s == nil {
+		return &Schema{}
+	}
+	return s
+}
+
+// checkString 校验 path、query、表单中的字符串值能否转换为 schema 的类型
+func (cp *compiled) checkString(s *Schema, value, name string) error {
+	s = cp.resolve(s)
+	switch s.Type {
+	case "integer":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("%s must be an integer", name)
+		}
+		return cp.checkValue(s, json.Number(value), name)
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%s must be a number", name)
+		}
+		return cp.checkValue(s, json.Number(value), name)
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a boolean", name)
+		}
+		return cp.checkValue(s, b, name)
+	case "string":
+		return cp.checkValue(s, value, name)
+	}
+	return nil
+}
+
+// checkValue 校验 JSON 解码后的值
+func (cp *compiled) checkValue(s *Schema, v interface{}, name string) error {
+	s = cp.resolve(s)
+	if v == nil {
+		return nil
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", name)
+		}
+		for _, r := range s.Required {
+			if _, ok := obj[r]; !ok {
+				return fmt.Errorf("%s.%s is required", name, r)
+			}
+		}
+		for k, val := range obj {
+			if ps, ok := s.Properties[k]; ok {
+				if err := cp.checkValue(ps, val, name+"."+k); err != nil {
+					return err
+				}
+			} else if s.AdditionalProperties != nil {
+				if err := cp.checkValue(s.AdditionalProperties, val, name+"."+k); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", name)
+		}
+		for i, item := range arr {
+			if err := cp.checkValue(s.Items, item, fmt.Sprintf("%s[%d]", name, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", name)
+		}
+		n := utf8.RuneCountInString(str)
+		if s.MinLength != nil && n < *s.MinLength {
+			return fmt.Errorf("%s must be at least %d characters", name, *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			return fmt.Errorf("%s must be at most %d characters", name, *s.MaxLength)
+		}
+		return checkEnum(s, str, name)
+	case "integer", "number":
+		num, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s must be a %s", name, s.Type)
+		}
+		f, err := num.Float64()
+		if err != nil {
+			return fmt.Errorf("%s must be a %s", name, s.Type)
+		}
+		if s.Type == "integer" {
+			if _, err := num.Int64(); err != nil {
+				return fmt.Errorf("%s must be an integer", name)
+			}
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s must be >= %v", name, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s must be <= %v", name, *s.Maximum)
+		}
+		return checkEnum(s, f, name)
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", name)
+		}
+	}
+	return nil
+}
+
+func checkEnum(s *Schema, v interface{}, name string) error {
+	if len(s.Enum) == 0 {
+		return nil
+	}
+	for _, e := range s.Enum {
+		if e == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be one of %v", name, s.Enum)
+}
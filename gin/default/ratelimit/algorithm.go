@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// 两种算法的计算都放在这里，内存和 Redis 两种 Store 共用，保证行为一致
+
+// refill 令牌桶补充令牌，last 为零值表示新桶
+func refill(tokens float64, last, now time.Time, l Limit) float64 {
+	burst := float64(l.burst())
+	if last.IsZero() {
+		return burst
+	}
+	elapsed := now.Sub(last)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(burst, tokens+float64(elapsed)*float64(l.Rate)/float64(l.Period))
+}
+
+// bucketResult tokens 为本次消耗之后剩余的令牌数
+func bucketResult(tokens float64, allowed bool, l Limit) Result {
+	perToken := float64(l.Period) / float64(l.Rate)
+	res := Result{
+		Allowed:   allowed,
+		Limit:     l.burst(),
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     time.Duration((float64(l.burst()) - tokens) * perToken),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) * perToken)
+	}
+	return res
+}
+
+// windowStart 当前窗口的起始时间和已经过去的时长
+func windowStart(now time.Time, l Limit) (time.Time, time.Duration) {
+	start := now.Truncate(l.Period)
+	return start, now.Sub(start)
+}
+
+// windowWeight 上一个窗口计数的权重，随着当前窗口的推进线性衰减到 0
+func windowWeight(elapsed time.Duration, l Limit) float64 {
+	return 1 - float64(elapsed)/float64(l.Period)
+}
+
+// windowResult prev、curr 为上一个和当前窗口的计数（curr 已包含本次请求）
+func windowResult(prev, curr int, elapsed time.Duration, allowed bool, l Limit) Result {
+	count := float64(prev)*windowWeight(elapsed, l) + float64(curr)
+	res := Result{
+		Allowed:   allowed,
+		Limit:     l.Rate,
+		Remaining: int(math.Max(0, math.Floor(float64(l.Rate)-count))),
+		Reset:     l.Period - elapsed,
+	}
+	if curr > 0 {
+		// 当前窗口的计数要等到下一个窗口结束才完全不影响结果
+		res.Reset += l.Period
+	}
+	if allowed {
+		return res
+	}
+	// 加权计数降到 Rate-1 以下才能再放行一个请求
+	need := float64(l.Rate - 1)
+	if float64(curr) <= need && prev > 0 {
+		// 当前窗口内，随着上一个窗口权重衰减就可以放行
+		res.RetryAfter = time.Duration(float64(l.Period)*(1-(need-float64(curr))/float64(prev))) - elapsed
+	} else {
+		// 要等到下一个窗口，当前窗口的计数成为 prev 后再衰减
+		var wait float64
+		if curr > 0 {
+			wait = math.Max(0, float64(l.Period)*(1-need/float64(curr)))
+		}
+		res.RetryAfter = l.Period - elapsed + time.Duration(wait)
+	}
+	return res
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	// 令牌桶
+	tokens float64
+	last   time.Time
+	// 滑动窗口
+	start      time.Time
+	prev, curr int
+
+	expires time.Time
+}
+
+// MemoryStore 进程内的限流状态，多实例部署时每个实例单独计数
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore 创建内存 Store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*entry), now: time.Now}
+}
+
+// Allow 实现 Store
+func (m *MemoryStore) Allow(ctx context.Context, key string, alg Algorithm, l Limit) (Result, error) {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+
+	e, ok := m.entries[key]
+	if !ok {
+		e = &entry{}
+		m.entries[key] = e
+	}
+	// 两个周期内没有访问的 key 状态已经完全恢复，可以清理
+	e.expires = now.Add(2 * l.Period)
+
+	if alg == SlidingWindow {
+		start, elapsed := windowStart(now, l)
+		if !start.Equal(e.start) {
+			if start.Sub(e.start) == l.Period {
+				e.prev = e.curr
+			} else {
+				e.prev = 0
+			}
+			e.curr = 0
+			e.start = start
+		}
+		allowed := float64(e.prev)*windowWeight(elapsed, l)+float64(e.curr)+1 <= float64(l.Rate)
+		if allowed {
+			e.curr++
+		}
+		return windowResult(e.prev, e.curr, elapsed, allowed, l), nil
+	}
+
+	e.tokens = refill(e.tokens, e.last, now, l)
+	e.last = now
+	allowed := e.tokens >= 1
+	if allowed {
+		e.tokens--
+	}
+	return bucketResult(e.tokens, allowed, l), nil
+}
+
+// sweep 每分钟清理一次过期的 key，避免 map 无限增长
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Before(m.nextSweep) {
+		return
+	}
+	m.nextSweep = now.Add(time.Minute)
+	for k, e := range m.entries {
+		if now.After(e.expires) {
+			delete(m.entries, k)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Algorithm 限流算法
+type Algorithm int
+
+const (
+	// TokenBucket 令牌桶：按固定速率补充令牌，允许 Burst 大小的突发
+	TokenBucket Algorithm = iota
+	// SlidingWindow 滑动窗口：用上一个窗口的计数按时间加权，近似最近 Period 内的请求数
+	SlidingWindow
+)
+
+// Limit 每 Period 最多 Rate 个请求
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	// Burst 令牌桶的容量，默认等于 Rate，滑动窗口忽略该字段
+	Burst int
+}
+
+// PerSecond 每秒 n 个请求
+func PerSecond(n int) Limit { return Limit{Rate: n, Period: time.Second} }
+
+// PerMinute 每分钟 n 个请求
+func PerMinute(n int) Limit { return Limit{Rate: n, Period: time.Minute} }
+
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+// Result 一次限流判断的结果
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset 配额完全恢复还需要的时间
+	Reset time.Duration
+	// RetryAfter 被拒绝时，距离下一次可以成功还需要的时间
+	RetryAfter time.Duration
+}
+
+// Store 保存限流状态，每次调用消耗 key 的一次配额
+type Store interface {
+	Allow(ctx context.Context, key string, alg Algorithm, limit Limit) (Result, error)
+}
+
+// KeyFunc 从请求中取出限流的维度，返回空字符串表示该请求不限流
+type KeyFunc func(c *gin.Context) string
+
+// ByIP 按客户端 IP 限流
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser 按 JWTAuthMiddleware 写入上下文的 username 限流，未登录时退化为按 IP
+func ByUser(c *gin.Context) string {
+	if username, ok := c.Get("username"); ok {
+		if s, ok := username.(string); ok && s != "" {
+			return "user:" + s
+		}
+	}
+	return ByIP(c)
+}
+
+// ByRoute 按路由模板限流，例如 "POST /upload"，同一路由的所有客户端共享配额
+func ByRoute(c *gin.Context) string {
+	path := c.FullPath()
+	if path == "" {
+		path = c.Request.URL.Path
+	}
+	return "route:" + c.Request.Method + " " + path
+}
+
+// Config 中间件配置
+type Config struct {
+	Store     Store
+	Algorithm Algorithm
+	Limit     Limit
+	// KeyFunc 默认 ByIP
+	KeyFunc KeyFunc
+	// Prefix 会加在 key 前面，多个限流中间件共用一个 Store 时用来区分
+	Prefix string
+	// FailClosed 为 true 时 Store 出错直接拒绝请求，默认放行
+	FailClosed bool
+}
+
+// New 创建限流中间件，响应中会带上 RateLimit-Limit、RateLimit-Remaining、RateLimit-Reset 头
+// 超出限制时返回 429 并设置 Retry-After
+func New(cfg Config) gin.HandlerFunc {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore()
+	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = ByIP
+	}
+	if cfg.Limit.Rate <= 0 || cfg.Limit.Period <= 0 {
+		panic("ratelimit: Limit.Rate and Limit.Period must be positive")
+	}
+	return func(c *gin.Context) {
+		key := cfg.KeyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		res, err := cfg.Store.Allow(c.Request.Context(), cfg.Prefix+key, cfg.Algorithm, cfg.Limit)
+		if err != nil {
+			log.Printf("ratelimit: store failed, key:%s err:%v\n", key, err)
+			if cfg.FailClosed {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+					"message": "rate limiter unavailable",
+				})
+				return
+			}
+			c.Next()
+			return
+		}
+		h := c.Writer.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+		if !res.Allowed {
+			h.Set("Retry-After", strconv.Itoa(seconds(res.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"message": "too many requests",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// seconds 向上取整到秒，头部的值不能比实际需要等待的时间短
+func seconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2022, 3, 24, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		alg  Algorithm
+		// 第 4 个请求被拒绝后需要等待的时间
+		retryAfter time.Duration
+	}{
+		{"token bucket", TokenBucket, 20 * time.Second},
+		{"sliding window", SlidingWindow, 80 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStore()
+			s.now = func() time.Time { return now }
+			l := PerMinute(3)
+			for i := 0; i < 3; i++ {
+				res, _ := s.Allow(context.Background(), "k", tt.alg, l)
+				if !res.Allowed || res.Remaining != 2-i {
+					t.Fatalf("request %d: %+v", i, res)
+				}
+			}
+			res, _ := s.Allow(context.Background(), "k", tt.alg, l)
+			if res.Allowed || res.RetryAfter != tt.retryAfter {
+				t.Fatalf("expected:%v, got:%+v", tt.retryAfter, res)
+			}
+			// 其他 key 不受影响
+			if res, _ := s.Allow(context.Background(), "other", tt.alg, l); !res.Allowed {
+				t.Errorf("other key limited: %+v", res)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("username", c.GetHeader("X-User"))
+	})
+	r.GET("/upload", New(Config{Limit: PerMinute(1), KeyFunc: ByUser}), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	do := func(user string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/upload", nil)
+		req.Header.Set("X-User", user)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := do("jason"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("first request: %d %v", w.Code, w.Header())
+	}
+	w := do("jason")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("second request: %d %v", w.Code, w.Header())
+	}
+	if w := do("q1mi"); w.Code != http.StatusOK {
+		t.Errorf("other user: %d", w.Code)
+	}
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 令牌桶脚本，返回 {是否放行, 剩余令牌数}
+// 令牌数是小数，Lua 返回数字会被截断成整数，所以转成字符串返回
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil then
+	tokens = burst
+else
+	tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], ttl)
+return {allowed, tostring(tokens)}
+`)
+
+// 滑动窗口脚本，KEYS[1] 为当前窗口，KEYS[2] 为上一个窗口，返回 {是否放行, prev, curr}
+var slidingWindowScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local weight = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+local prev = tonumber(redis.call('GET', KEYS[2]) or '0')
+local curr = tonumber(redis.call('GET', KEYS[1]) or '0')
+local allowed = 0
+if prev * weight + curr + 1 <= rate then
+	curr = redis.call('INCR', KEYS[1])
+	redis.call('PEXPIRE', KEYS[1], ttl)
+	allowed = 1
+end
+return {allowed, prev, curr}
+`)
+
+// RedisStore 限流状态保存在 Redis 中，多个实例共享配额
+// 判断逻辑在 Lua 脚本中原子执行，时间以调用方的时钟为准
+type RedisStore struct {
+	rdb    redis.Scripter
+	prefix string
+	now    func() time.Time
+}
+
+// NewRedisStore 创建 Redis Store，rdb 可以是 *redis.Client 或 *redis.ClusterClient
+// key 使用 {} hash tag，集群模式下同一个 key 的多个窗口落在同一个槽
+func NewRedisStore(rdb redis.Scripter, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = "ratelimit:"
+	}
+	return &RedisStore{rdb: rdb, prefix: prefix, now: time.Now}
+}
+
+// Allow 实现 Store
+func (s *RedisStore) Allow(ctx context.Context, key string, alg Algorithm, l Limit) (Result, error) {
+	now := s.now()
+	ttl := (2 * l.Period).Milliseconds()
+	key = s.prefix + "{" + key + "}"
+
+	if alg == SlidingWindow {
+		start, elapsed := windowStart(now, l)
+		idx := start.UnixNano() / int64(l.Period)
+		keys := []string{key + ":" + strconv.FormatInt(idx, 10), key + ":" + strconv.FormatInt(idx-1, 10)}
+		vals, err := slidingWindowScript.Run(ctx, s.rdb, keys, l.Rate, windowWeight(elapsed, l), ttl).Int64Slice()
+		if err != nil {
+			return Result{}, err
+		}
+		return windowResult(int(vals[1]), int(vals[2]), elapsed, vals[0] == 1, l), nil
+	}
+
+	rate := float64(l.Rate) / (float64(l.Period) / float64(time.Millisecond))
+	vals, err := tokenBucketScript.Run(ctx, s.rdb, []string{key}, rate, l.burst(), now.UnixNano()/int64(time.Millisecond), ttl).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	allowed, _ := vals[0].(int64)
+	str, _ := vals[1].(string)
+	tokens, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return bucketResult(tokens, allowed == 1, l), nil
+}
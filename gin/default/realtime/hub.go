@@ -0,0 +1,348 @@
+package realtime
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// Message 客户端和服务端之间的消息
+// 客户端发送 {"type":"join","room":"x"}、{"type":"leave","room":"x"}、{"type":"message","room":"x","data":...}
+type Message struct {
+	Type string          `json:"type"`
+	Room string          `json:"room,omitempty"`
+	From string          `json:"from,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// HubConfig WebSocket 配置
+type HubConfig struct {
+	// WriteWait 单次写的超时，默认 10 秒
+	WriteWait time.Duration
+	// PongWait 多久没收到 pong 认为连接已断开，默认 60 秒；ping 的间隔为它的 9/10
+	PongWait time.Duration
+	// MaxMessageSize 客户端消息的最大长度，默认 64KB
+	MaxMessageSize int64
+	// SendBuffer 每个连接的发送缓冲，写满说明客户端太慢，直接断开，默认 256
+	SendBuffer int
+	// CheckOrigin 为 nil 时只允许同源
+	CheckOrigin func(r *http.Request) bool
+	// UserKey 认证中间件通过 c.Set 保存用户名使用的 key，默认 "username"，和 auth.JWT 的 Middleware 一致
+	UserKey string
+	// AllowAnonymous 允许没有经过认证中间件（例如 auth.JWT 的 Middleware）的连接
+	AllowAnonymous bool
+	// OnMessage 处理 join、leave 以外的消息，为 nil 时 message 类型的消息广播给所在房间
+	OnMessage func(c *Client, msg Message)
+}
+
+// Hub 管理所有 WebSocket 连接和房间
+type Hub struct {
+	cfg      HubConfig
+	upgrader websocket.Upgrader
+
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+	rooms   map[string]map[*Client]struct{}
+}
+
+// NewHub 创建 Hub
+func NewHub(cfg HubConfig) *Hub {
+	if cfg.WriteWait <= 0 {
+		cfg.WriteWait = 10 * time.Second
+	}
+	if cfg.PongWait <= 0 {
+		cfg.PongWait = 60 * time.Second
+	}
+	if cfg.MaxMessageSize <= 0 {
+		cfg.MaxMessageSize = 64 << 10
+	}
+	if cfg.SendBuffer <= 0 {
+		cfg.SendBuffer = 256
+	}
+	if cfg.UserKey == "" {
+		cfg.UserKey = "username"
+	}
+	return &Hub{
+		cfg:      cfg,
+		upgrader: websocket.Upgrader{CheckOrigin: cfg.CheckOrigin},
+		clients:  make(map[*Client]struct{}),
+		rooms:    make(map[string]map[*Client]struct{}),
+	}
+}
+
+// Handler 返回升级 WebSocket 的处理函数，放在认证中间件之后，用户名取自 c.Get(HubConfig.UserKey)
+// 浏览器的 WebSocket 不能设置 Authorization 头，可以在前面加上 TokenFromQuery
+func (h *Hub) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.GetString(h.cfg.UserKey)
+		if user == "" && !h.cfg.AllowAnonymous {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// Upgrade 已经写了错误响应
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		cl := &Client{
+			User:  user,
+			hub:   h,
+			conn:  conn,
+			send:  make(chan []byte, h.cfg.SendBuffer),
+			rooms: make(map[string]struct{}),
+		}
+		h.mu.Lock()
+		h.clients[cl] = struct{}{}
+		h.mu.Unlock()
+
+		go cl.writePump()
+		cl.readPump()
+	}
+}
+
+// TokenFromQuery 把 ?token= 转成 Authorization: Bearer 头，这样可以直接复用 auth.JWT 的 Middleware
+func TokenFromQuery(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if t := c.Query(param); t != "" && c.GetHeader("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+t)
+		}
+		c.Next()
+	}
+}
+
+// Join 把连接加入房间，房间名不能为空，空的房间名在 Broadcast 中表示所有连接
+func (h *Hub) Join(c *Client, room string) {
+	if strings.TrimSpace(room) == "" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+	members, ok := h.rooms[room]
+	if !ok {
+		members = make(map[*Client]struct{})
+		h.rooms[room] = members
+	}
+	members[c] = struct{}{}
+	c.rooms[room] = struct{}{}
+}
+
+// Leave 把连接移出房间
+func (h *Hub) Leave(c *Client, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leave(c, room)
+}
+
+func (h *Hub) leave(c *Client, room string) {
+	delete(c.rooms, room)
+	if members, ok := h.rooms[room]; ok {
+		delete(members, c)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+// Broadcast 向房间内的所有连接发送消息，room 为空时发给所有连接
+func (h *Hub) Broadcast(room string, msg Message) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	members := h.clients
+	if room != "" {
+		members = h.rooms[room]
+	}
+	for c := range members {
+		select {
+		case c.send <- b:
+		default:
+			// 发送缓冲满了，断开慢客户端，不能让它拖慢整个房间
+			h.remove(c)
+		}
+	}
+	return nil
+}
+
+// SendTo 向某个用户的所有连接发送消息
+func (h *Hub) SendTo(user string, msg Message) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		if c.User != user {
+			continue
+		}
+		select {
+		case c.send <- b:
+		default:
+			h.remove(c)
+		}
+	}
+	return nil
+}
+
+// Rooms 返回房间及其连接数
+func (h *Hub) Rooms() map[string]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	rooms := make(map[string]int, len(h.rooms))
+	for name, members := range h.rooms {
+		rooms[name] = len(members)
+	}
+	return rooms
+}
+
+// Clients 当前的连接数
+func (h *Hub) Clients() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// Close 断开所有连接，用于服务退出
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		h.remove(c)
+	}
+}
+
+// remove 调用方持有锁；关闭 send 之后 writePump 会发送 close 帧并关闭连接
+func (h *Hub) remove(c *Client) {
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+	for room := range c.rooms {
+		h.leave(c, room)
+	}
+	delete(h.clients, c)
+	close(c.send)
+}
+
+// Client 一个 WebSocket 连接
+type Client struct {
+	// User 连接对应的用户名，匿名连接为空
+	User string
+
+	hub   *Hub
+	conn  *websocket.Conn
+	send  chan []byte
+	rooms map[string]struct{}
+}
+
+// Send 向这个连接发送消息，缓冲满时返回 false
+func (c *Client) Send(msg Message) bool {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return false
+	}
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+	if _, ok := c.hub.clients[c]; !ok {
+		return false
+	}
+	select {
+	case c.send <- b:
+		return true
+	default:
+		return false
+	}
+}
+
+// readPump 在处理函数的 goroutine 中运行，连接断开时返回
+func (c *Client) readPump() {
+	h := c.hub
+	defer func() {
+		h.mu.Lock()
+		h.remove(c)
+		h.mu.Unlock()
+		c.conn.Close()
+	}()
+	c.conn.SetReadLimit(h.cfg.MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(h.cfg.PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(h.cfg.PongWait))
+	})
+	for {
+		var msg Message
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				c.Send(Message{Type: "error", Data: json.RawMessage(`"invalid message"`)})
+				continue
+			}
+			return
+		}
+		msg.From = c.User
+		switch strings.ToLower(msg.Type) {
+		case "join":
+			if strings.TrimSpace(msg.Room) == "" {
+				c.Send(Message{Type: "error", Data: json.RawMessage(`"room is required"`)})
+				continue
+			}
+			h.Join(c, msg.Room)
+		case "leave":
+			h.Leave(c, msg.Room)
+		default:
+			if h.cfg.OnMessage != nil {
+				h.cfg.OnMessage(c, msg)
+				continue
+			}
+			// 客户端只能发给自己所在的房间，不能借空的房间名广播给所有连接
+			if msg.Type == "message" && msg.Room != "" && c.inRoom(msg.Room) {
+				h.Broadcast(msg.Room, msg)
+			}
+		}
+	}
+}
+
+func (c *Client) inRoom(room string) bool {
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+	_, ok := c.rooms[room]
+	return ok
+}
+
+// writePump 所有写操作都在这个 goroutine 中，gorilla/websocket 不支持并发写
+func (c *Client) writePump() {
+	h := c.hub
+	ticker := time.NewTicker(h.cfg.PongWait * 9 / 10)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case b, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(h.cfg.WriteWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, b); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(h.cfg.WriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
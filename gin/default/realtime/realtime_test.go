@@ -0,0 +1,240 @@
+package realtime
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"main/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestBrokerReplay(t *testing.T) {
+	b := NewBroker(BrokerConfig{History: 2})
+	b.Publish("news", "one")
+	b.Publish("news", "two")
+	b.Publish("news", gin.H{"n": 3})
+
+	r := gin.New()
+	r.GET("/events", b.Handler())
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	br := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		var lines []string
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line == "\n" {
+				if len(lines) == 0 {
+					continue
+				}
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+	if e := readEvent(); e != "id:2\nevent:news\ndata:two\n" {
+		t.Fatalf("replayed event = %q", e)
+	}
+	if e := readEvent(); e != "id:3\nevent:news\ndata:{\"n\":3}\n" {
+		t.Fatalf("replayed event = %q", e)
+	}
+	for b.Subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	b.Publish("news", "four")
+	if e := readEvent(); e != "id:4\nevent:news\ndata:four\n" {
+		t.Fatalf("live event = %q", e)
+	}
+}
+
+var jwtAuth = auth.New([]byte("secret"))
+
+// dial 和浏览器一样把 token 放在 ?token= 中
+func dial(t *testing.T, url, user string) *websocket.Conn {
+	t.Helper()
+	token, err := jwtAuth.GenToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial(url+"?token="+token, nil)
+	if err != nil {
+		code := 0
+		if resp != nil {
+			code = resp.StatusCode
+		}
+		t.Fatalf("dial as %q: %v (status %d)", user, err, code)
+	}
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestHub(t *testing.T) {
+	hub := NewHub(HubConfig{PongWait: 300 * time.Millisecond})
+	defer hub.Close()
+	r := gin.New()
+	r.GET("/ws", TokenFromQuery("token"), jwtAuth.Middleware(), hub.Handler())
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	// 没有认证
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("anonymous dial should be rejected, got %v", err)
+	}
+	forged, _ := auth.New([]byte("other")).GenToken("alice")
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"?token="+forged, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("dial with forged token should be rejected, got %v", err)
+	}
+
+	alice := dial(t, url, "alice")
+	defer alice.Close()
+	bob := dial(t, url, "bob")
+	defer bob.Close()
+	alice.WriteJSON(Message{Type: "join", Room: "lobby"})
+	bob.WriteJSON(Message{Type: "join", Room: "lobby"})
+	for hub.Rooms()["lobby"] != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	alice.WriteJSON(Message{Type: "message", Room: "lobby", Data: json.RawMessage(`"hi"`)})
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		msg := readMessage(t, conn)
+		if msg.From != "alice" || msg.Room != "lobby" || string(msg.Data) != `"hi"` {
+			t.Fatalf("unexpected message %+v", msg)
+		}
+	}
+
+	// 客户端在读的时候会自动回复 pong，经过了几轮 ping/pong 之后连接依然存活
+	received := make(chan Message, 2)
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		go func(conn *websocket.Conn) {
+			var msg Message
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			conn.ReadJSON(&msg)
+			received <- msg
+		}(conn)
+	}
+	time.Sleep(700 * time.Millisecond)
+	hub.Broadcast("lobby", Message{Type: "notice", Data: json.RawMessage(`1`)})
+	for i := 0; i < 2; i++ {
+		if msg := <-received; msg.Type != "notice" {
+			t.Fatalf("unexpected message %+v", msg)
+		}
+	}
+
+	bob.WriteJSON(Message{Type: "leave", Room: "lobby"})
+	for hub.Rooms()["lobby"] != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	alice.Close()
+	for hub.Clients() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := hub.Rooms()["lobby"]; ok {
+		t.Fatal("empty room should be removed")
+	}
+}
+
+func TestHubEmptyRoom(t *testing.T) {
+	hub := NewHub(HubConfig{})
+	defer hub.Close()
+	r := gin.New()
+	r.GET("/ws", TokenFromQuery("token"), jwtAuth.Middleware(), hub.Handler())
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	alice := dial(t, url, "alice")
+	defer alice.Close()
+	bob := dial(t, url, "bob")
+	defer bob.Close()
+	bob.WriteJSON(Message{Type: "join", Room: "lobby"})
+	for hub.Rooms()["lobby"] != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 空的房间名不能加入，也不能用来广播给所有连接
+	alice.WriteJSON(Message{Type: "join", Room: ""})
+	if msg := readMessage(t, alice); msg.Type != "error" {
+		t.Fatalf("join empty room: %+v", msg)
+	}
+	alice.WriteJSON(Message{Type: "join", Room: "  "})
+	if msg := readMessage(t, alice); msg.Type != "error" {
+		t.Fatalf("join blank room: %+v", msg)
+	}
+	alice.WriteJSON(Message{Type: "message", Room: "", Data: json.RawMessage(`"spam"`)})
+	bob.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	var msg Message
+	if err := bob.ReadJSON(&msg); err == nil {
+		t.Fatalf("bob received %+v", msg)
+	}
+	if _, ok := hub.Rooms()[""]; ok {
+		t.Fatal("empty room created")
+	}
+}
+
+func TestHubUserKey(t *testing.T) {
+	hub := NewHub(HubConfig{UserKey: "uid"})
+	defer hub.Close()
+	r := gin.New()
+	r.GET("/ws", func(c *gin.Context) {
+		if uid := c.Query("uid"); uid != "" {
+			c.Set("uid", uid)
+		}
+	}, hub.Handler())
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("dial without user: %v", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?uid=carol", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for hub.Clients() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := hub.SendTo("carol", Message{Type: "message", Data: json.RawMessage(`"hi"`)}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readMessage(t, conn); string(msg.Data) != `"hi"` {
+		t.Fatalf("got %+v", msg)
+	}
+}
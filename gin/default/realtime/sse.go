@@ -0,0 +1,156 @@
+package realtime
+
+import (
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// Event 一个 SSE 事件，Data 为字符串时原样输出，其他类型输出为 JSON
+type Event struct {
+	ID    uint64
+	Event string
+	Data  interface{}
+}
+
+// BrokerConfig SSE 配置
+type BrokerConfig struct {
+	// History 保留最近的事件数，客户端重连时根据 Last-Event-ID 补发，默认 100
+	History int
+	// Heartbeat 发送注释行保持连接的间隔，防止代理关闭空闲连接，默认 15 秒
+	Heartbeat time.Duration
+	// Retry 告诉浏览器断开之后多久重连，为 0 时使用浏览器的默认值
+	Retry time.Duration
+	// Buffer 每个连接的发送缓冲，写满说明客户端太慢，会断开它让它重连补发，默认 16
+	Buffer int
+}
+
+// Broker 向所有订阅的浏览器推送事件
+type Broker struct {
+	cfg     BrokerConfig
+	mu      sync.Mutex
+	nextID  uint64
+	history []Event
+	subs    map[chan Event]struct{}
+}
+
+// NewBroker 创建 Broker
+func NewBroker(cfg BrokerConfig) *Broker {
+	if cfg.History <= 0 {
+		cfg.History = 100
+	}
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = 15 * time.Second
+	}
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = 16
+	}
+	return &Broker{cfg: cfg, subs: make(map[chan Event]struct{})}
+}
+
+// Publish 发布事件，返回分配的 ID
+func (b *Broker) Publish(event string, data interface{}) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	e := Event{ID: b.nextID, Event: event, Data: data}
+	b.history = append(b.history, e)
+	if len(b.history) > b.cfg.History {
+		b.history = b.history[len(b.history)-b.cfg.History:]
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			// 慢客户端：关闭 channel 断开连接，浏览器重连时带上 Last-Event-ID 补发
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return e.ID
+}
+
+// subscribe 注册订阅并返回 lastID 之后还在历史中的事件，两步在同一把锁内完成，不会漏掉事件
+func (b *Broker) subscribe(lastID uint64) (chan Event, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan Event, b.cfg.Buffer)
+	b.subs[ch] = struct{}{}
+	var missed []Event
+	for _, e := range b.history {
+		if e.ID > lastID {
+			missed = append(missed, e)
+		}
+	}
+	return ch, missed
+}
+
+func (b *Broker) unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// Subscribers 当前的连接数
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Handler 返回 SSE 处理函数，浏览器端使用 new EventSource(url)
+// 重连时浏览器会自动带上 Last-Event-ID 头，也可以通过 ?last_event_id= 指定
+func (b *Broker) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		last := c.GetHeader("Last-Event-ID")
+		if last == "" {
+			last = c.Query("last_event_id")
+		}
+		lastID, _ := strconv.ParseUint(last, 10, 64)
+
+		ch, missed := b.subscribe(lastID)
+		defer b.unsubscribe(ch)
+
+		h := c.Writer.Header()
+		h.Set("Content-Type", sse.ContentType)
+		h.Set("Cache-Control", "no-cache")
+		h.Set("Connection", "keep-alive")
+		// 关闭 nginx 的响应缓冲
+		h.Set("X-Accel-Buffering", "no")
+		if b.cfg.Retry > 0 {
+			io.WriteString(c.Writer, "retry:"+strconv.FormatInt(int64(b.cfg.Retry/time.Millisecond), 10)+"\n\n")
+		}
+		for _, e := range missed {
+			writeEvent(c, e)
+		}
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(b.cfg.Heartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case e, ok := <-ch:
+				if !ok {
+					return
+				}
+				writeEvent(c, e)
+			case <-heartbeat.C:
+				io.WriteString(c.Writer, ": ping\n\n")
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeEvent(c *gin.Context, e Event) {
+	c.Render(-1, sse.Event{Id: strconv.FormatUint(e.ID, 10), Event: e.Event, Data: e.Data})
+}
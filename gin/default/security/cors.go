@@ -0,0 +1,129 @@
+package security
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig 跨域配置
+type CORSConfig struct {
+	// AllowOrigins 允许的来源，支持 "*" 以及 "https://*.example.com" 这样的子域名通配
+	AllowOrigins []string
+	// AllowOriginFunc 自定义判断，返回 true 表示允许，和 AllowOrigins 是或的关系
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods 默认 GET、POST、PUT、PATCH、DELETE、HEAD
+	AllowMethods []string
+	// AllowHeaders 默认 Origin、Content-Type、Accept、Authorization、X-Request-ID
+	AllowHeaders []string
+	// ExposeHeaders 浏览器中 JS 可以读取的响应头
+	ExposeHeaders []string
+	// AllowCredentials 允许携带 cookie，此时不能使用 "*" 作为响应的 Access-Control-Allow-Origin
+	AllowCredentials bool
+	// MaxAge 预检请求的缓存时间
+	MaxAge time.Duration
+}
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead}
+	defaultCORSHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-ID"}
+)
+
+// CORS 跨域中间件，预检请求（OPTIONS + Access-Control-Request-Method）直接返回 204 并中止
+// 不允许的来源不会带上任何 CORS 头，由浏览器拦截
+func CORS(cfg CORSConfig) gin.HandlerFunc {
+	if len(cfg.AllowMethods) == 0 {
+		cfg.AllowMethods = defaultCORSMethods
+	}
+	if len(cfg.AllowHeaders) == 0 {
+		cfg.AllowHeaders = defaultCORSHeaders
+	}
+	allowAll := false
+	for _, o := range cfg.AllowOrigins {
+		if o == "*" {
+			allowAll = true
+		}
+	}
+	methods := strings.ToUpper(strings.Join(cfg.AllowMethods, ", "))
+	headers := strings.Join(cfg.AllowHeaders, ", ")
+	expose := strings.Join(cfg.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge / time.Second))
+
+	allowed := func(origin string) bool {
+		if allowAll {
+			return true
+		}
+		for _, o := range cfg.AllowOrigins {
+			if matchOrigin(o, origin) {
+				return true
+			}
+		}
+		return cfg.AllowOriginFunc != nil && cfg.AllowOriginFunc(origin)
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		h := c.Writer.Header()
+		// 响应内容随 Origin 变化，告诉缓存要区分
+		h.Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		}
+		if !allowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if allowAll && !cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if expose != "" {
+				h.Set("Access-Control-Expose-Headers", expose)
+			}
+			c.Next()
+			return
+		}
+
+		h.Set("Access-Control-Allow-Methods", methods)
+		h.Set("Access-Control-Allow-Headers", headers)
+		if cfg.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// matchOrigin pattern 为完整的 origin 或者带有一个 * 的子域名通配
+func matchOrigin(pattern, origin string) bool {
+	if !strings.Contains(pattern, "*") {
+		return strings.EqualFold(pattern, origin)
+	}
+	parts := strings.SplitN(pattern, "*", 2)
+	prefix, suffix := strings.ToLower(parts[0]), strings.ToLower(parts[1])
+	origin = strings.ToLower(origin)
+	if len(origin) <= len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+	// * 只能匹配子域名部分，不能跨越 scheme 或端口
+	middle := origin[len(prefix) : len(origin)-len(suffix)]
+	return !strings.ContainsAny(middle, "/:")
+}
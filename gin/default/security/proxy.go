@@ -0,0 +1,40 @@
+package security
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// TrustProxies 配置受信任的代理
+// gin 默认信任所有来源的 X-Forwarded-For，任何客户端都可以伪造 c.ClientIP()
+// 调用之后只有来自 cidrs（例如负载均衡所在网段 10.0.0.0/8）的请求才会读取 headers 中的客户端 IP
+// headers 为空时使用 X-Forwarded-For、X-Real-IP；cidrs 为空表示不信任任何代理，直接使用连接的地址
+func TrustProxies(r *gin.Engine, cidrs []string, headers ...string) error {
+	if len(headers) == 0 {
+		headers = []string{"X-Forwarded-For", "X-Real-IP"}
+	}
+	r.ForwardedByClientIP = len(cidrs) > 0
+	r.RemoteIPHeaders = headers
+	if len(cidrs) == 0 {
+		return r.SetTrustedProxies(nil)
+	}
+	return r.SetTrustedProxies(cidrs)
+}
+
+// Config 中间件组合的配置
+type Config struct {
+	CORS    *CORSConfig
+	Headers *HeadersConfig
+}
+
+// Middlewares 按顺序返回配置了的中间件，使用方式：r.Use(security.Middlewares(cfg)...)
+// CORS 放在前面，预检请求不需要经过其他中间件
+func Middlewares(cfg Config) []gin.HandlerFunc {
+	var hs []gin.HandlerFunc
+	if cfg.CORS != nil {
+		hs = append(hs, CORS(*cfg.CORS))
+	}
+	if cfg.Headers != nil {
+		hs = append(hs, Headers(*cfg.Headers))
+	}
+	return hs
+}
@@ -0,0 +1,127 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestMatchOrigin(t *testing.T) {
+	cases := []struct {
+		pattern, origin string
+		want            bool
+	}{
+		{"https://example.com", "https://example.com", true},
+		{"https://example.com", "http://example.com", false},
+		{"https://*.example.com", "https://api.example.com", true},
+		{"https://*.example.com", "https://a.b.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "https://evil.com/.example.com", false},
+		{"https://*.example.com", "https://evilexample.com", false},
+	}
+	for _, tc := range cases {
+		if got := matchOrigin(tc.pattern, tc.origin); got != tc.want {
+			t.Errorf("matchOrigin(%q, %q) = %v, want %v", tc.pattern, tc.origin, got, tc.want)
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	r := gin.New()
+	r.Use(CORS(CORSConfig{
+		AllowOrigins:     []string{"https://*.example.com"},
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"X-Request-ID"},
+		MaxAge:           time.Hour,
+	}))
+	r.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+
+	// 预检请求
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodOptions, "/ping", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("preflight status = %d", w.Code)
+	}
+	h := w.Header()
+	if h.Get("Access-Control-Allow-Origin") != "https://app.example.com" || h.Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatalf("unexpected preflight headers: %v", h)
+	}
+	if h.Get("Access-Control-Max-Age") != "3600" || h.Get("Access-Control-Allow-Methods") == "" {
+		t.Fatalf("unexpected preflight headers: %v", h)
+	}
+
+	// 普通请求
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	r.ServeHTTP(w, req)
+	if w.Body.String() != "pong" || w.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+		t.Fatalf("unexpected response: %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Vary") != "Origin" {
+		t.Fatalf("Vary = %q", w.Header().Get("Vary"))
+	}
+
+	// 不允许的来源
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodOptions, "/ping", nil)
+	req.Header.Set("Origin", "https://evil.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("disallowed origin: %d %v", w.Code, w.Header())
+	}
+}
+
+func TestHeadersAndTrustedProxies(t *testing.T) {
+	r := gin.New()
+	if err := TrustProxies(r, []string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	r.Use(Headers(DefaultHeaders()))
+	r.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+	do := func(remote string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 来自负载均衡的请求：采信转发头
+	w := do("10.1.2.3:5000")
+	if w.Body.String() != "203.0.113.7" {
+		t.Fatalf("client ip via proxy = %q", w.Body.String())
+	}
+	if w.Header().Get("Strict-Transport-Security") != "max-age=31536000; includeSubDomains" {
+		t.Fatalf("HSTS = %q", w.Header().Get("Strict-Transport-Security"))
+	}
+	if w.Header().Get("X-Frame-Options") != "DENY" || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("unexpected headers: %v", w.Header())
+	}
+
+	// 直接访问的客户端伪造转发头：忽略
+	w = do("198.51.100.1:5000")
+	if w.Body.String() != "198.51.100.1" {
+		t.Fatalf("spoofed client ip = %q", w.Body.String())
+	}
+	if w.Header().Get("Strict-Transport-Security") != "" {
+		t.Fatal("HSTS must not be set for untrusted X-Forwarded-Proto")
+	}
+	if w.Header().Get("Content-Security-Policy") == "" {
+		t.Fatal("missing Content-Security-Policy")
+	}
+}
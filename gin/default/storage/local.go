@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Local 把对象保存在本地磁盘 Dir 目录下
+type Local struct {
+	Dir    string
+	Signer *Signer
+}
+
+// NewLocal 创建本地磁盘存储，signer 用来生成下载地址，可以为 nil
+func NewLocal(dir string, signer *Signer) (*Local, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Local{Dir: dir, Signer: signer}, nil
+}
+
+func (l *Local) path(key string) (string, string, error) {
+	k, err := cleanKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return k, filepath.Join(l.Dir, filepath.FromSlash(k)), nil
+}
+
+// Put 先写入同目录下的临时文件，写完后再 rename，避免读到写了一半的文件
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, contentType string) (*Object, error) {
+	k, p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, &ctxReader{ctx: ctx, r: r})
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return nil, err
+	}
+	return &Object{Key: k, Size: n, ContentType: detectType(k, contentType), ModTime: time.Now()}, nil
+}
+
+// Open 打开对象，调用方负责关闭返回的 io.ReadCloser
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	k, p, err := l.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if fi.IsDir() {
+		f.Close()
+		return nil, nil, ErrNotFound
+	}
+	return f, &Object{Key: k, Size: fi.Size(), ContentType: detectType(k, ""), ModTime: fi.ModTime()}, nil
+}
+
+// Delete 删除对象，对象不存在时返回 ErrNotFound
+func (l *Local) Delete(ctx context.Context, key string) error {
+	_, p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// SignedURL 生成由 DownloadHandler 校验的下载地址
+func (l *Local) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return l.Signer.URL(key, expiry)
+}
+
+// ctxReader 在每次 Read 前检查 ctx，客户端断开后可以尽快停止写入
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// detectType 没有指定 Content-Type 时根据扩展名推断
+func detectType(key, contentType string) string {
+	if contentType != "" {
+		return contentType
+	}
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+type memObject struct {
+	data []byte
+	obj  Object
+}
+
+// Memory 把对象保存在内存中，主要用于测试
+type Memory struct {
+	Signer *Signer
+
+	mu      sync.RWMutex
+	objects map[string]memObject
+}
+
+// NewMemory 创建内存存储
+func NewMemory(signer *Signer) *Memory {
+	return &Memory{Signer: signer, objects: make(map[string]memObject)}
+}
+
+// Put 保存对象
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader, contentType string) (*Object, error) {
+	k, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(&ctxReader{ctx: ctx, r: r})
+	if err != nil {
+		return nil, err
+	}
+	obj := Object{Key: k, Size: int64(len(data)), ContentType: detectType(k, contentType), ModTime: time.Now()}
+	m.mu.Lock()
+	m.objects[k] = memObject{data: data, obj: obj}
+	m.mu.Unlock()
+	return &obj, nil
+}
+
+// Open 打开对象
+func (m *Memory) Open(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	k, err := cleanKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	m.mu.RLock()
+	o, ok := m.objects[k]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	obj := o.obj
+	return ioutil.NopCloser(bytes.NewReader(o.data)), &obj, nil
+}
+
+// Delete 删除对象
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	k, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[k]; !ok {
+		return ErrNotFound
+	}
+	delete(m.objects, k)
+	return nil
+}
+
+// SignedURL 生成由 DownloadHandler 校验的下载地址
+func (m *Memory) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return m.Signer.URL(key, expiry)
+}
@@ -0,0 +1,368 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	amzDateFormat   = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	// minPartSize S3 要求除最后一块外每个分块不小于 5 MiB
+	minPartSize = 5 << 20
+)
+
+// S3Config S3 兼容存储（AWS S3、MinIO 等）的配置，使用 path-style 地址：Endpoint/Bucket/key
+type S3Config struct {
+	Endpoint  string // 例如 http://127.0.0.1:9000
+	Region    string // 默认 us-east-1
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PartSize 分块上传时每块的大小，默认也是最小值 5 MiB
+	// 上传时最多只会在内存中缓存一块
+	PartSize int64
+	Client   *http.Client
+}
+
+// S3 S3 兼容的存储后端，只依赖标准库，自己实现了 SigV4 签名
+type S3 struct {
+	cfg  S3Config
+	base *url.URL
+	now  func() time.Time
+}
+
+// NewS3 创建 S3 兼容存储
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("storage: s3 bucket is required")
+	}
+	u, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("storage: invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.PartSize < minPartSize {
+		cfg.PartSize = minPartSize
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &S3{cfg: cfg, base: u, now: time.Now}, nil
+}
+
+func (s *S3) objectURL(key string, query url.Values) *url.URL {
+	u := *s.base
+	u.Path = u.Path + "/" + s.cfg.Bucket + "/" + key
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+func (s *S3) do(ctx context.Context, method string, u *url.URL, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	signRequest(req, s.cfg.AccessKey, s.cfg.SecretKey, s.cfg.Region, s.now().UTC())
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("storage: s3 %s %s: %s: %s", method, u.Path, resp.Status, bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
+
+// Put 内容小于一块时直接 PutObject，否则走分块上传，内存中最多保留一块数据
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, contentType string) (*Object, error) {
+	k, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	contentType = detectType(k, contentType)
+	buf := make([]byte, s.cfg.PartSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		header := http.Header{"Content-Type": {contentType}}
+		resp, err := s.do(ctx, http.MethodPut, s.objectURL(k, nil), bytes.NewReader(buf[:n]), int64(n), header)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		return &Object{Key: k, Size: int64(n), ContentType: contentType, ModTime: s.now()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	size, err := s.multipartUpload(ctx, k, contentType, r, buf)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Key: k, Size: size, ContentType: contentType, ModTime: s.now()}, nil
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type completePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+	Parts   []completePart `xml:"Part"`
+}
+
+// multipartUpload buf 中已经装满了第一块数据
+func (s *S3) multipartUpload(ctx context.Context, key, contentType string, r io.Reader, buf []byte) (size int64, err error) {
+	resp, err := s.do(ctx, http.MethodPost, s.objectURL(key, url.Values{"uploads": {""}}), nil, 0,
+		http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		return 0, err
+	}
+	var init initiateMultipartUploadResult
+	err = xml.NewDecoder(resp.Body).Decode(&init)
+	resp.Body.Close()
+	if err != nil {
+		return 0, fmt.Errorf("storage: decode initiate multipart upload: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			// 失败时放弃本次上传，避免在服务端留下未完成的分块；这里用新的 ctx，原 ctx 可能已经取消
+			abortCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if resp, aerr := s.do(abortCtx, http.MethodDelete, s.objectURL(key, url.Values{"uploadId": {init.UploadID}}), nil, 0, nil); aerr == nil {
+				resp.Body.Close()
+			}
+		}
+	}()
+
+	var parts []completePart
+	n := len(buf)
+	for partNumber := 1; n > 0; partNumber++ {
+		q := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {init.UploadID}}
+		resp, err := s.do(ctx, http.MethodPut, s.objectURL(key, q), bytes.NewReader(buf[:n]), int64(n), nil)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		parts = append(parts, completePart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+		size += int64(n)
+
+		n, err = io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+	}
+
+	body, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+	if err != nil {
+		return 0, err
+	}
+	resp, err = s.do(ctx, http.MethodPost, s.objectURL(key, url.Values{"uploadId": {init.UploadID}}),
+		bytes.NewReader(body), int64(len(body)), http.Header{"Content-Type": {"application/xml"}})
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return size, nil
+}
+
+// Open 获取对象，调用方负责关闭返回的 io.ReadCloser
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	k, err := cleanKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(k, nil), nil, 0, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj := &Object{Key: k, Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		obj.ModTime = t
+	}
+	return resp.Body, obj, nil
+}
+
+// Delete 删除对象。S3 删除不存在的对象也会返回成功
+func (s *S3) Delete(ctx context.Context, key string) error {
+	k, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, s.objectURL(k, nil), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// SignedURL 生成 S3 预签名的 GET 地址，客户端直接从 S3 下载，最长 7 天
+func (s *S3) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	k, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if expiry <= 0 || expiry > 7*24*time.Hour {
+		return "", fmt.Errorf("storage: invalid s3 url expiry %s", expiry)
+	}
+	u := s.objectURL(k, nil)
+	presignURL(u, s.cfg.AccessKey, s.cfg.SecretKey, s.cfg.Region, s.now().UTC(), expiry)
+	return u.String(), nil
+}
+
+// ---------- SigV4 ----------
+
+// signRequest 按 AWS SigV4 在请求头中签名，负载不参与签名（UNSIGNED-PAYLOAD）
+func signRequest(req *http.Request, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	scope := credentialScope(now, region)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+	sig := signature(secretKey, region, now, scope, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, sig))
+}
+
+// presignURL 按 AWS SigV4 生成查询参数签名，只签 host 头
+func presignURL(u *url.URL, accessKey, secretKey, region string, now time.Time, expiry time.Duration) {
+	scope := credentialScope(now, region)
+	q := u.Query()
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", accessKey+"/"+scope)
+	q.Set("X-Amz-Date", now.Format(amzDateFormat))
+	q.Set("X-Amz-Expires", strconv.Itoa(int(expiry/time.Second)))
+	q.Set("X-Amz-SignedHeaders", "host")
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		uriEncode(u.Path, false),
+		canonicalQuery(q),
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	q.Set("X-Amz-Signature", signature(secretKey, region, now, scope, canonicalRequest))
+	u.RawQuery = canonicalQuery(q)
+}
+
+func credentialScope(now time.Time, region string) string {
+	return now.Format("20060102") + "/" + region + "/s3/aws4_request"
+}
+
+func signature(secretKey, region string, now time.Time, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format(amzDateFormat) + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	key := hmacSHA256([]byte("AWS4"+secretKey), now.Format("20060102"))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func canonicalQuery(q url.Values) string {
+	if len(q) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		vs := append([]string(nil), q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode 按 AWS 的规则编码，只保留 A-Z a-z 0-9 - _ . ~ 不编码
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
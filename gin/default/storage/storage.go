@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("storage: object not found")
+
+// ErrInvalidKey 对象 key 不合法（为空或者试图跳出存储目录）
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// Object 描述一个已保存的对象
+type Object struct {
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	ModTime     time.Time `json:"mod_time"`
+}
+
+// Storage 上传文件的存储后端
+// Put 必须以流的方式消费 r，不能假设能拿到完整内容的长度
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (*Object, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	Delete(ctx context.Context, key string) error
+	// SignedURL 返回一个在 expiry 之后失效的下载地址
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// cleanKey 规范化 key，去掉开头的 / 并拒绝 .. 之类跳出目录的写法
+func cleanKey(key string) (string, error) {
+	if key == "" || strings.Contains(key, "\\") || strings.ContainsRune(key, 0) {
+		return "", ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." {
+			return "", ErrInvalidKey
+		}
+	}
+	k := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if k == "" || k == "." {
+		return "", ErrInvalidKey
+	}
+	return k, nil
+}
+
+// Signer 给本地、内存存储生成带过期时间的下载地址
+// 地址形如 BaseURL/key?expires=1650000000&signature=xxx，由 DownloadHandler 校验
+type Signer struct {
+	BaseURL string
+	Secret  []byte
+	// Now 方便测试时替换，默认 time.Now
+	Now func() time.Time
+}
+
+func (s *Signer) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *Signer) sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	fmt.Fprintf(mac, "%s\n%d", key, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// URL 生成 key 对应的签名地址
+func (s *Signer) URL(key string, expiry time.Duration) (string, error) {
+	if s == nil {
+		return "", errors.New("storage: signer not configured")
+	}
+	k, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	expires := s.now().Add(expiry).Unix()
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", s.sign(k, expires))
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + (&url.URL{Path: k}).EscapedPath() + "?" + q.Encode(), nil
+}
+
+// Verify 校验签名以及是否过期，没有配置 Signer 时总是返回 false
+func (s *Signer) Verify(key, expires, signature string) bool {
+	if s == nil {
+		return false
+	}
+	k, err := cleanKey(key)
+	if err != nil {
+		return false
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || s.now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.sign(k, exp)))
+}
+
+// UploadHandler 以流的方式把 multipart 表单中名为 field 的文件写入 s
+// 与 c.FormFile/c.SaveUploadedFile 不同，文件内容不会先落到内存或临时文件
+// keyFunc 根据上传的文件名生成对象 key，为 nil 时直接使用文件名
+func UploadHandler(s Storage, field string, keyFunc func(filename string) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mr, err := c.Request.MultipartReader()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		var objects []*Object
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": err.Error(),
+				})
+				return
+			}
+			if part.FormName() != field || part.FileName() == "" {
+				part.Close()
+				continue
+			}
+			key := path.Base(part.FileName())
+			if keyFunc != nil {
+				key = keyFunc(key)
+			}
+			obj, err := s.Put(c.Request.Context(), key, part, part.Header.Get("Content-Type"))
+			part.Close()
+			if err != nil {
+				status := http.StatusInternalServerError
+				if errors.Is(err, ErrInvalidKey) {
+					status = http.StatusBadRequest
+				}
+				c.JSON(status, gin.H{
+					"message": err.Error(),
+				})
+				return
+			}
+			objects = append(objects, obj)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": fmt.Sprintf("%d files uploaded!", len(objects)),
+			"files":   objects,
+		})
+	}
+}
+
+// DownloadHandler 校验 Signer 生成的地址并返回文件内容
+// 路由需要形如 r.GET("/download/*key", ...)
+func DownloadHandler(s Storage, signer *Signer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimPrefix(c.Param("key"), "/")
+		if signer == nil {
+			// 没有配置 Signer 时不提供下载
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": ErrNotFound.Error(),
+			})
+			return
+		}
+		if !signer.Verify(key, c.Query("expires"), c.Query("signature")) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "invalid or expired signature",
+			})
+			return
+		}
+		rc, obj, err := s.Open(c.Request.Context(), key)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		defer rc.Close()
+		c.DataFromReader(http.StatusOK, obj.Size, obj.ContentType, rc, nil)
+	}
+}
@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedisHook 为 go-redis 的每条命令（或每个 pipeline）创建子 span
+// 使用方式：rdb.AddHook(tracing.RedisHook{})，命令需要带上请求的 ctx 调用
+type RedisHook struct{}
+
+var _ redis.Hook = RedisHook{}
+
+// BeforeProcess 实现 redis.Hook
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, _ = tracer().Start(ctx, "redis "+cmd.FullName(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemRedis,
+			semconv.DBOperationKey.String(cmd.FullName()),
+			semconv.DBStatementKey.String(cmdString(cmd)),
+		),
+	)
+	return ctx, nil
+}
+
+// AfterProcess 实现 redis.Hook
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	endSpan(trace.SpanFromContext(ctx), redisErr(cmd.Err()))
+	return nil
+}
+
+// BeforeProcessPipeline 实现 redis.Hook
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	names := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		names = append(names, cmd.FullName())
+	}
+	ctx, _ = tracer().Start(ctx, "redis pipeline",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemRedis,
+			semconv.DBOperationKey.String("pipeline"),
+			semconv.DBStatementKey.String(strings.Join(names, " ")),
+		),
+	)
+	return ctx, nil
+}
+
+// AfterProcessPipeline 实现 redis.Hook
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if err = redisErr(cmd.Err()); err != nil {
+			break
+		}
+	}
+	endSpan(trace.SpanFromContext(ctx), err)
+	return nil
+}
+
+// cmdString 只记录命令和 key，不记录写入的值，避免把敏感数据写进链路
+func cmdString(cmd redis.Cmder) string {
+	args := cmd.Args()
+	if len(args) > 2 {
+		args = args[:2]
+	}
+	parts := make([]string, 0, len(args))
+	for _, a := range args {
+		if s, ok := a.(string); ok {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// redis.Nil 表示 key 不存在，不算错误
+func redisErr(err error) error {
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,161 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DB 包装 *sqlx.DB，带 Context 的方法会创建子 span，记录 SQL 语句
+// 没有包装的方法仍然可以通过内嵌的 *sqlx.DB 调用，只是不会产生 span
+type DB struct {
+	*sqlx.DB
+	// System 对应 db.system 属性，默认 mysql
+	System string
+}
+
+// WrapDB 包装一个已经连接好的 *sqlx.DB
+func WrapDB(db *sqlx.DB) *DB {
+	return &DB{DB: db, System: "mysql"}
+}
+
+func (db *DB) start(ctx context.Context, query string) (context.Context, trace.Span) {
+	return startSQL(ctx, db.System, query)
+}
+
+func startSQL(ctx context.Context, system, query string) (context.Context, trace.Span) {
+	op := query
+	if i := strings.IndexAny(strings.TrimSpace(query), " \n\t"); i > 0 {
+		op = strings.TrimSpace(query)[:i]
+	}
+	op = strings.ToUpper(op)
+	return tracer().Start(ctx, "sql "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemKey.String(system),
+			semconv.DBOperationKey.String(op),
+			semconv.DBStatementKey.String(query),
+		),
+	)
+}
+
+// GetContext 查询单条数据
+func (db *DB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	ctx, span := db.start(ctx, query)
+	defer func() { endSpan(span, ignoreNoRows(err)) }()
+	return db.DB.GetContext(ctx, dest, query, args...)
+}
+
+// SelectContext 查询多条数据
+func (db *DB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	ctx, span := db.start(ctx, query)
+	defer func() { endSpan(span, err) }()
+	return db.DB.SelectContext(ctx, dest, query, args...)
+}
+
+// ExecContext 执行插入、更新、删除
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
+	ctx, span := db.start(ctx, query)
+	defer func() { endSpan(span, err) }()
+	return db.DB.ExecContext(ctx, query, args...)
+}
+
+// NamedExecContext 使用命名参数执行
+func (db *DB) NamedExecContext(ctx context.Context, query string, arg interface{}) (res sql.Result, err error) {
+	ctx, span := db.start(ctx, query)
+	defer func() { endSpan(span, err) }()
+	return db.DB.NamedExecContext(ctx, query, arg)
+}
+
+// QueryxContext 查询，span 在返回 *sqlx.Rows 时就结束，不包括遍历结果的时间
+func (db *DB) QueryxContext(ctx context.Context, query string, args ...interface{}) (rows *sqlx.Rows, err error) {
+	ctx, span := db.start(ctx, query)
+	defer func() { endSpan(span, err) }()
+	return db.DB.QueryxContext(ctx, query, args...)
+}
+
+// BeginTxx 开启事务，返回的 Tx 同样会为语句、Commit 和 Rollback 创建 span
+func (db *DB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	spanCtx, span := db.start(ctx, "BEGIN")
+	tx, err := db.DB.BeginTxx(spanCtx, opts)
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, System: db.System, ctx: ctx}, nil
+}
+
+// Tx 包装 *sqlx.Tx，和 DB 一样为带 Context 的方法创建子 span
+type Tx struct {
+	*sqlx.Tx
+	System string
+	// ctx BeginTxx 的 ctx，Commit 和 Rollback 没有 ctx 参数，用它作为 span 的父节点
+	ctx context.Context
+}
+
+// GetContext 查询单条数据
+func (tx *Tx) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	ctx, span := startSQL(ctx, tx.System, query)
+	defer func() { endSpan(span, ignoreNoRows(err)) }()
+	return tx.Tx.GetContext(ctx, dest, query, args...)
+}
+
+// SelectContext 查询多条数据
+func (tx *Tx) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	ctx, span := startSQL(ctx, tx.System, query)
+	defer func() { endSpan(span, err) }()
+	return tx.Tx.SelectContext(ctx, dest, query, args...)
+}
+
+// ExecContext 执行插入、更新、删除
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
+	ctx, span := startSQL(ctx, tx.System, query)
+	defer func() { endSpan(span, err) }()
+	return tx.Tx.ExecContext(ctx, query, args...)
+}
+
+// NamedExecContext 使用命名参数执行
+func (tx *Tx) NamedExecContext(ctx context.Context, query string, arg interface{}) (res sql.Result, err error) {
+	ctx, span := startSQL(ctx, tx.System, query)
+	defer func() { endSpan(span, err) }()
+	return tx.Tx.NamedExecContext(ctx, query, arg)
+}
+
+// QueryxContext 查询，span 在返回 *sqlx.Rows 时就结束，不包括遍历结果的时间
+func (tx *Tx) QueryxContext(ctx context.Context, query string, args ...interface{}) (rows *sqlx.Rows, err error) {
+	ctx, span := startSQL(ctx, tx.System, query)
+	defer func() { endSpan(span, err) }()
+	return tx.Tx.QueryxContext(ctx, query, args...)
+}
+
+// Commit 提交事务
+func (tx *Tx) Commit() (err error) {
+	_, span := startSQL(tx.ctx, tx.System, "COMMIT")
+	defer func() { endSpan(span, err) }()
+	return tx.Tx.Commit()
+}
+
+// Rollback 回滚事务，Commit 之后 defer 的 Rollback 返回 sql.ErrTxDone，不标记为错误
+func (tx *Tx) Rollback() (err error) {
+	_, span := startSQL(tx.ctx, tx.System, "ROLLBACK")
+	defer func() {
+		if err == sql.ErrTxDone {
+			endSpan(span, nil)
+			return
+		}
+		endSpan(span, err)
+	}()
+	return tx.Tx.Rollback()
+}
+
+// sql.ErrNoRows 是正常的业务结果，不标记为错误
+func ignoreNoRows(err error) error {
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,157 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+)
+
+// fakeDriver 最简单的 database/sql 驱动，查询返回一行 name，语句中带 missing 时返回空结果
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct{ query string }
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &fakeRows{empty: strings.Contains(s.query, "missing")}, nil
+}
+
+type fakeRows struct {
+	empty, done bool
+}
+
+func (*fakeRows) Columns() []string { return []string{"name"} }
+func (*fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.empty || r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = "jason"
+	return nil
+}
+
+func init() {
+	sql.Register("tracing-fake", fakeDriver{})
+}
+
+func TestDBSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := Init("test", exporter)
+	defer tp.Shutdown(context.Background())
+
+	db := WrapDB(sqlx.MustOpen("tracing-fake", ""))
+	defer db.Close()
+
+	ctx, root := tracer().Start(context.Background(), "handler")
+	var name string
+	if err := db.GetContext(ctx, &name, "SELECT name FROM user WHERE id = ?", 1); err != nil || name != "jason" {
+		t.Fatalf("GetContext = %q, %v", name, err)
+	}
+	if err := db.GetContext(ctx, &name, "select name from missing"); err != sql.ErrNoRows {
+		t.Fatalf("GetContext missing = %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "UPDATE user SET name = ? WHERE id = ?", "tom", 1); err != nil {
+		t.Fatal(err)
+	}
+	root.End()
+	tp.ForceFlush(context.Background())
+
+	spans := exporter.GetSpans()
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(spans))
+	}
+	want := []string{"sql SELECT", "sql SELECT", "sql UPDATE"}
+	for i, s := range spans[:3] {
+		if s.Name != want[i] {
+			t.Errorf("span %d name = %q, want %q", i, s.Name, want[i])
+		}
+		if s.Parent.SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("span %q is not a child of the handler span", s.Name)
+		}
+		// 没有结果不算错误
+		if s.Status.Code == codes.Error {
+			t.Errorf("span %q status = %v", s.Name, s.Status)
+		}
+	}
+	attrs := make(map[string]string)
+	for _, kv := range spans[0].Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs[string(semconv.DBStatementKey)] != "SELECT name FROM user WHERE id = ?" || attrs[string(semconv.DBSystemKey)] != "mysql" {
+		t.Errorf("unexpected attributes: %v", attrs)
+	}
+}
+
+func TestTxSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := Init("test", exporter)
+	defer tp.Shutdown(context.Background())
+
+	db := WrapDB(sqlx.MustOpen("tracing-fake", ""))
+	defer db.Close()
+
+	ctx, root := tracer().Start(context.Background(), "handler")
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	if err := tx.SelectContext(ctx, &names, "SELECT name FROM user"); err != nil || len(names) != 1 {
+		t.Fatalf("SelectContext = %v, %v", names, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user WHERE id = ?", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// 常见的 defer tx.Rollback()，事务已经提交
+	if err := tx.Rollback(); err != sql.ErrTxDone {
+		t.Fatalf("Rollback after Commit = %v", err)
+	}
+	root.End()
+	tp.ForceFlush(context.Background())
+
+	spans := exporter.GetSpans()
+	want := []string{"sql BEGIN", "sql SELECT", "sql DELETE", "sql COMMIT", "sql ROLLBACK", "handler"}
+	if len(spans) != len(want) {
+		t.Fatalf("expected %d spans, got %d", len(want), len(spans))
+	}
+	for i, s := range spans[:len(want)-1] {
+		if s.Name != want[i] {
+			t.Errorf("span %d name = %q, want %q", i, s.Name, want[i])
+		}
+		if s.Parent.SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("span %q is not a child of the handler span", s.Name)
+		}
+		if s.Status.Code == codes.Error {
+			t.Errorf("span %q status = %v", s.Name, s.Status)
+		}
+	}
+}
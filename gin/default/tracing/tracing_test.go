@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPropagation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exporter := tracetest.NewInMemoryExporter()
+	tp := Init("test", exporter)
+	defer tp.Shutdown(context.Background())
+
+	// 下游服务
+	downstream := gin.New()
+	downstream.Use(Middleware("downstream"))
+	downstream.GET("/", func(c *gin.Context) {
+		// 模拟一次 redis 调用
+		cmd := redis.NewStringCmd(c.Request.Context(), "get", "name")
+		ctx, _ := RedisHook{}.BeforeProcess(c.Request.Context(), cmd)
+		RedisHook{}.AfterProcess(ctx, cmd)
+		c.String(http.StatusOK, "quick response")
+	})
+	srv := httptest.NewServer(downstream)
+	defer srv.Close()
+
+	// 上游服务在 handler 中调用下游
+	client := NewClient(nil)
+	upstream := gin.New()
+	upstream.Use(Middleware("upstream"))
+	upstream.GET("/user/:name", func(c *gin.Context) {
+		req, _ := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, srv.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			c.AbortWithError(http.StatusBadGateway, err)
+			return
+		}
+		resp.Body.Close()
+		c.String(http.StatusOK, "ok")
+	})
+	upstream.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/jason", nil))
+	tp.ForceFlush(context.Background())
+
+	spans := exporter.GetSpans()
+	byName := make(map[string]tracetest.SpanStub)
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+	root, call, server, get := byName["GET /user/:name"], byName["HTTP GET"], byName["GET /"], byName["redis get"]
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got:%v", len(spans))
+	}
+	traceID := root.SpanContext.TraceID()
+	for _, s := range spans {
+		if s.SpanContext.TraceID() != traceID {
+			t.Errorf("span %q not in trace %s", s.Name, traceID)
+		}
+	}
+	if call.Parent.SpanID() != root.SpanContext.SpanID() ||
+		server.Parent.SpanID() != call.SpanContext.SpanID() ||
+		get.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("unexpected span tree: %+v", spans)
+	}
+	if server.SpanKind != trace.SpanKindServer || !server.Parent.IsRemote() {
+		t.Errorf("server span: kind=%v remote=%v", server.SpanKind, server.Parent.IsRemote())
+	}
+}
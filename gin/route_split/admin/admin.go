@@ -0,0 +1,146 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 构建信息，编译时通过 -ldflags 注入：
+// go build -ldflags "-X main/admin.Version=v1.2.0 -X main/admin.Commit=$(git rev-parse HEAD) -X main/admin.BuildTime=$(date -u +%FT%TZ)"
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+var startTime = time.Now()
+
+// Config 管理接口配置
+type Config struct {
+	// Engine 要查看路由的业务 engine
+	Engine *gin.Engine
+	// Token 使用 Authorization: Bearer {Token} 认证
+	Token string
+	// Accounts 使用 HTTP Basic 认证
+	Accounts gin.Accounts
+	// Auth 自定义认证中间件，设置了之后忽略 Token 和 Accounts
+	Auth gin.HandlerFunc
+	// Settings 通过 /config 查看的配置，输出前会隐藏密码等敏感信息
+	Settings interface{}
+	// Metrics 通过 /metrics 输出的 Prometheus 指标，为 nil 时不注册
+	Metrics gin.HandlerFunc
+}
+
+// ErrNoAuth 管理接口必须配置认证
+var ErrNoAuth = errors.New("admin: Token, Accounts or Auth is required")
+
+// New 创建管理接口的 engine，应该监听在单独的内网端口上，不要和业务接口放在一起
+//
+//	GET /routes          路由表及中间件
+//	GET /buildinfo       版本、提交、Go 版本、依赖
+//	GET /config          脱敏后的配置
+//	GET /metrics         Prometheus 指标，配置了 Metrics 时注册
+//	GET /debug/pprof/*   pprof
+func New(cfg Config) (*gin.Engine, error) {
+	auth, err := authMiddleware(cfg)
+	if err != nil {
+		return nil, err
+	}
+	e := gin.New()
+	e.Use(gin.Recovery(), auth)
+	Register(&e.RouterGroup, cfg)
+	return e, nil
+}
+
+// Register 在 g 上注册管理接口，调用方负责认证
+func Register(g *gin.RouterGroup, cfg Config) {
+	g.GET("/routes", func(c *gin.Context) {
+		if cfg.Engine == nil {
+			c.JSON(http.StatusOK, []Route{})
+			return
+		}
+		c.JSON(http.StatusOK, Routes(cfg.Engine))
+	})
+	g.GET("/buildinfo", func(c *gin.Context) {
+		c.JSON(http.StatusOK, buildInfo())
+	})
+	g.GET("/config", func(c *gin.Context) {
+		settings, err := Redact(cfg.Settings)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, settings)
+	})
+	if cfg.Metrics != nil {
+		g.GET("/metrics", cfg.Metrics)
+	}
+	// pprof.Index 根据 /debug/pprof/ 之后的部分选择 profile，所以这里用通配路由自己分发，挂在任何前缀下都能用
+	g.GET("/debug/pprof/*name", pprofHandler)
+	g.POST("/debug/pprof/*name", pprofHandler)
+}
+
+func pprofHandler(c *gin.Context) {
+	switch name := strings.TrimPrefix(c.Param("name"), "/"); name {
+	case "":
+		pprof.Index(c.Writer, c.Request)
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+func buildInfo() gin.H {
+	info := gin.H{
+		"version":    Version,
+		"commit":     Commit,
+		"build_time": BuildTime,
+		"go_version": runtime.Version(),
+		"os_arch":    runtime.GOOS + "/" + runtime.GOARCH,
+		"started_at": startTime.Format(time.RFC3339),
+		"uptime":     time.Since(startTime).Round(time.Second).String(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info["module"] = bi.Main.Path
+		deps := make(map[string]string, len(bi.Deps))
+		for _, d := range bi.Deps {
+			deps[d.Path] = d.Version
+		}
+		info["deps"] = deps
+	}
+	return info
+}
+
+func authMiddleware(cfg Config) (gin.HandlerFunc, error) {
+	switch {
+	case cfg.Auth != nil:
+		return cfg.Auth, nil
+	case cfg.Token != "":
+		want := []byte("Bearer " + cfg.Token)
+		return func(c *gin.Context) {
+			if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), want) != 1 {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+				return
+			}
+			c.Next()
+		}, nil
+	case len(cfg.Accounts) > 0:
+		return gin.BasicAuthForRealm(cfg.Accounts, "admin"), nil
+	}
+	return nil, ErrNoAuth
+}
@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redacted = "******"
+
+// secretKeys 字段名包含这些词时整个值被隐藏
+var secretKeys = []string{"password", "passwd", "secret", "token", "key", "dsn", "credential", "private"}
+
+// dsnPassword user:pass@tcp(host) 这样的 MySQL DSN
+var dsnPassword = regexp.MustCompile(`^([^:/@]+):([^@/]*)@`)
+
+// Redact 把配置转成 JSON 对象并隐藏其中的敏感信息，返回值可以直接输出为 JSON
+// 字段名（按 JSON 的 key）包含 password、secret、token、key 等词的值会被替换，
+// 其他字符串中 URL 或 DSN 的密码部分也会被替换
+func Redact(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return redactValue("", out), nil
+}
+
+func redactValue(key string, v interface{}) interface{} {
+	if isSecretKey(key) {
+		if v == nil || v == "" {
+			return v
+		}
+		return redacted
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = redactValue(k, child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactValue(key, child)
+		}
+		return v
+	case string:
+		return redactString(v)
+	}
+	return v
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range secretKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactString(s string) string {
+	if u, err := url.Parse(s); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+			return strings.Replace(u.String(), "xxxxx", redacted, 1)
+		}
+	}
+	return dsnPassword.ReplaceAllString(s, "${1}:"+redacted+"@")
+}
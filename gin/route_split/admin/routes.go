@@ -0,0 +1,104 @@
+package admin
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Route 一条路由及其完整的处理链
+type Route struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+	// Middleware 处理函数之前的中间件，包括全局的和路由组的，按执行顺序排列
+	Middleware []string `json:"middleware"`
+}
+
+// Routes 列出 engine 上注册的所有路由，按路径和方法排序
+// gin 的 Engine.Routes() 只有最后一个处理函数，完整的处理链要从路由树中读取；
+// 路由树是 gin 的内部结构，读取失败时退回到 Engine.Routes()，Middleware 为空
+func Routes(e *gin.Engine) []Route {
+	routes, ok := routesFromTrees(e)
+	if !ok {
+		routes = routes[:0]
+		for _, r := range e.Routes() {
+			routes = append(routes, Route{Method: r.Method, Path: r.Path, Handler: r.Handler})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// PrintRoutes 以表格形式输出路由
+func PrintRoutes(w io.Writer, e *gin.Engine) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tHANDLER\tMIDDLEWARE")
+	for _, r := range Routes(e) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Method, r.Path, r.Handler, strings.Join(r.Middleware, " -> "))
+	}
+	return tw.Flush()
+}
+
+// routesFromTrees 通过反射读取 engine.trees，结构对应 gin v1.7 的 tree.go
+func routesFromTrees(e *gin.Engine) ([]Route, bool) {
+	trees := reflect.ValueOf(e).Elem().FieldByName("trees")
+	if !trees.IsValid() || trees.Kind() != reflect.Slice {
+		return nil, false
+	}
+	var routes []Route
+	for i := 0; i < trees.Len(); i++ {
+		tree := trees.Index(i)
+		method, root := tree.FieldByName("method"), tree.FieldByName("root")
+		if method.Kind() != reflect.String || root.Kind() != reflect.Ptr {
+			return nil, false
+		}
+		if !walk(method.String(), root, &routes) {
+			return nil, false
+		}
+	}
+	return routes, true
+}
+
+func walk(method string, n reflect.Value, routes *[]Route) bool {
+	if n.IsNil() {
+		return true
+	}
+	n = n.Elem()
+	handlers, fullPath, children := n.FieldByName("handlers"), n.FieldByName("fullPath"), n.FieldByName("children")
+	if handlers.Kind() != reflect.Slice || fullPath.Kind() != reflect.String || children.Kind() != reflect.Slice {
+		return false
+	}
+	if l := handlers.Len(); l > 0 {
+		r := Route{Method: method, Path: fullPath.String(), Handler: funcName(handlers.Index(l - 1))}
+		for i := 0; i < l-1; i++ {
+			r.Middleware = append(r.Middleware, funcName(handlers.Index(i)))
+		}
+		*routes = append(*routes, r)
+	}
+	for i := 0; i < children.Len(); i++ {
+		if !walk(method, children.Index(i), routes) {
+			return false
+		}
+	}
+	return true
+}
+
+// funcName 未导出字段的值不能 Interface()，但是可以取函数指针
+func funcName(f reflect.Value) string {
+	if fn := runtime.FuncForPC(f.Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return "unknown"
+}
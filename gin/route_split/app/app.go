@@ -0,0 +1,8 @@
+// Package app 汇总所有业务模块，新增业务时在 app 下新建目录实现 module.Module，
+// 在 init 中调用 module.Register，然后在这里加一行 import，不需要修改 main.go
+package app
+
+import (
+	_ "main/app/blog"
+	_ "main/app/shop"
+)
@@ -0,0 +1,231 @@
+package blog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"main/module"
+	"main/version"
+)
+
+func init() {
+	module.Register(New())
+}
+
+// Module 博客模块，挂载在 /blog 下
+type Module struct {
+	repo Repository
+	db   *sqlx.DB
+}
+
+// New 创建博客模块，默认使用内存存储，Init 时配置了 MYSQL_DSN 则换成 MySQL
+func New() *Module {
+	return &Module{repo: NewMemoryRepository()}
+}
+
+// NewWithRepository 使用指定的存储
+func NewWithRepository(repo Repository) *Module {
+	return &Module{repo: repo}
+}
+
+// Name 实现 module.Module
+func (m *Module) Name() string {
+	return "blog"
+}
+
+// Init 实现 module.Module
+func (m *Module) Init(ctx context.Context) error {
+	dsn := os.Getenv("MYSQL_DSN")
+	if dsn == "" {
+		return nil
+	}
+	db, err := sqlx.ConnectContext(ctx, "mysql", dsn)
+	if err != nil {
+		return err
+	}
+	db.SetMaxOpenConns(20)
+	db.SetMaxIdleConns(10)
+	m.db, m.repo = db, NewMySQLRepository(db)
+	return nil
+}
+
+// Close 实现 module.Module
+func (m *Module) Close(ctx context.Context) error {
+	if m.db != nil {
+		return m.db.Close()
+	}
+	return nil
+}
+
+// Routes v1 已经废弃，响应中带上 Deprecation、Sunset 头提醒调用方迁移到 v2
+func (m *Module) Routes(g *gin.RouterGroup) {
+	api := version.New("blog")
+	api.Default = "v1"
+	v1 := api.Version("v1",
+		version.Deprecated(time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)),
+		version.Sunset(time.Date(2027, 7, 1, 0, 0, 0, 0, time.UTC), "https://example.com/blog/api/migrate-v2"),
+	)
+	v1.GET("/post", m.postHandler)
+	v1.GET("/post/:id", m.postDetailHandler)
+	v1.POST("/post", m.createPostHandler)
+	v1.GET("/comment", m.commentHandler)
+	v1.POST("/comment", m.createCommentHandler)
+	// v2 的评论列表按回复关系组装成树
+	v2 := api.Version("v2")
+	v2.GET("/comment", m.commentV2Handler)
+
+	api.Mount(g)
+	api.MountNegotiated(g)
+}
+
+// pageQuery 文章列表的分页参数 /post?page=1&size=20
+type pageQuery struct {
+	Page int `form:"page" binding:"omitempty,min=1"`
+	Size int `form:"size" binding:"omitempty,min=1,max=100"`
+}
+
+func (m *Module) postHandler(c *gin.Context) {
+	var q pageQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if q.Page == 0 {
+		q.Page = 1
+	}
+	if q.Size == 0 {
+		q.Size = 20
+	}
+	posts, total, err := m.repo.ListPosts(c.Request.Context(), (q.Page-1)*q.Size, q.Size)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"total": total,
+		"page":  q.Page,
+		"size":  q.Size,
+		"items": posts,
+	})
+}
+
+func (m *Module) postDetailHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
+		return
+	}
+	p, err := m.repo.GetPost(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func (m *Module) createPostHandler(c *gin.Context) {
+	var p Post
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p.Author = author(c)
+	if err := m.repo.CreatePost(c.Request.Context(), &p); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, p)
+}
+
+// commentQuery /comment?post_id=1
+type commentQuery struct {
+	PostID int64 `form:"post_id" binding:"required,min=1"`
+}
+
+func (m *Module) listComments(c *gin.Context) ([]Comment, bool) {
+	var q commentQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if _, err := m.repo.GetPost(c.Request.Context(), q.PostID); err != nil {
+		writeError(c, err)
+		return nil, false
+	}
+	comments, err := m.repo.ListComments(c.Request.Context(), q.PostID)
+	if err != nil {
+		writeError(c, err)
+		return nil, false
+	}
+	return comments, true
+}
+
+// commentHandler 按 ID 顺序返回平铺的评论，调用方根据 parent_id 自己组装
+func (m *Module) commentHandler(c *gin.Context) {
+	comments, ok := m.listComments(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": comments})
+}
+
+// commentV2Handler 返回评论树，回复放在父评论的 replies 中
+func (m *Module) commentV2Handler(c *gin.Context) {
+	comments, ok := m.listComments(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": Thread(comments)})
+}
+
+// commentRequest {"post_id": 1, "parent_id": 0, "content": "..."}
+type commentRequest struct {
+	PostID   int64  `json:"post_id" binding:"required,min=1"`
+	ParentID int64  `json:"parent_id" binding:"omitempty,min=0"`
+	Content  string `json:"content" binding:"required,max=2000"`
+}
+
+func (m *Module) createCommentHandler(c *gin.Context) {
+	var req commentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cm := Comment{
+		PostID:   req.PostID,
+		ParentID: req.ParentID,
+		Author:   author(c),
+		Content:  req.Content,
+	}
+	if err := m.repo.CreateComment(c.Request.Context(), &cm); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, cm)
+}
+
+// author 经过 JWTAuthMiddleware 时使用登录的用户名
+func author(c *gin.Context) string {
+	if user := c.GetString("username"); user != "" {
+		return user
+	}
+	return "guest"
+}
+
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrInvalidParent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,76 @@
+package blog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func do(e *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestPostsAndThreadedComments(t *testing.T) {
+	e := gin.New()
+	NewWithRepository(NewMemoryRepository()).Routes(&e.RouterGroup)
+
+	for _, title := range []string{"first", "second"} {
+		if w := do(e, http.MethodPost, "/v2/post", `{"title":"`+title+`","content":"hello"}`); w.Code != http.StatusCreated {
+			t.Fatalf("create post status = %d, body %s", w.Code, w.Body)
+		}
+	}
+	w := do(e, http.MethodGet, "/v2/post?size=1", "")
+	var page struct {
+		Total int    `json:"total"`
+		Items []Post `json:"items"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if page.Total != 2 || len(page.Items) != 1 || page.Items[0].Title != "second" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+
+	comments := []string{
+		`{"post_id":1,"content":"top"}`,
+		`{"post_id":1,"parent_id":1,"content":"reply"}`,
+		`{"post_id":1,"parent_id":2,"content":"nested"}`,
+		`{"post_id":2,"content":"other post"}`,
+	}
+	for _, body := range comments {
+		if w := do(e, http.MethodPost, "/v2/comment", body); w.Code != http.StatusCreated {
+			t.Fatalf("create comment status = %d, body %s", w.Code, w.Body)
+		}
+	}
+	if w := do(e, http.MethodPost, "/v2/comment", `{"post_id":1,"parent_id":4,"content":"x"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("cross-post reply status = %d", w.Code)
+	}
+	if w := do(e, http.MethodPost, "/v2/comment", `{"post_id":9,"content":"x"}`); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown post status = %d", w.Code)
+	}
+
+	var flat struct{ Items []Comment }
+	json.Unmarshal(do(e, http.MethodGet, "/v1/comment?post_id=1", "").Body.Bytes(), &flat)
+	if len(flat.Items) != 3 {
+		t.Fatalf("flat comments = %d, want 3", len(flat.Items))
+	}
+
+	var tree struct{ Items []*Comment }
+	json.Unmarshal(do(e, http.MethodGet, "/v2/comment?post_id=1", "").Body.Bytes(), &tree)
+	if len(tree.Items) != 1 || len(tree.Items[0].Replies) != 1 ||
+		len(tree.Items[0].Replies[0].Replies) != 1 || tree.Items[0].Replies[0].Replies[0].Content != "nested" {
+		t.Fatalf("unexpected thread: %s", do(e, http.MethodGet, "/v2/comment?post_id=1", "").Body)
+	}
+}
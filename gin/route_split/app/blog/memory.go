@@ -0,0 +1,117 @@
+package blog
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository 内存实现，用于测试和没有配置数据库时的演示
+type MemoryRepository struct {
+	mu        sync.Mutex
+	posts     []Post
+	comments  []Comment
+	postID    int64
+	commentID int64
+	now       func() time.Time
+}
+
+// NewMemoryRepository 创建内存存储
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{now: time.Now}
+}
+
+// ListPosts 实现 Repository
+func (r *MemoryRepository) ListPosts(ctx context.Context, offset, limit int) ([]Post, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	posts := append([]Post(nil), r.posts...)
+	sort.SliceStable(posts, func(i, j int) bool {
+		if !posts[i].CreatedAt.Equal(posts[j].CreatedAt) {
+			return posts[i].CreatedAt.After(posts[j].CreatedAt)
+		}
+		return posts[i].ID > posts[j].ID
+	})
+	total := len(posts)
+	if offset >= total {
+		return []Post{}, total, nil
+	}
+	posts = posts[offset:]
+	if limit > 0 && len(posts) > limit {
+		posts = posts[:limit]
+	}
+	return posts, total, nil
+}
+
+// GetPost 实现 Repository
+func (r *MemoryRepository) GetPost(ctx context.Context, id int64) (*Post, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.posts {
+		if p.ID == id {
+			return &p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// CreatePost 实现 Repository
+func (r *MemoryRepository) CreatePost(ctx context.Context, p *Post) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.postID++
+	p.ID = r.postID
+	p.CreatedAt = r.now()
+	r.posts = append(r.posts, *p)
+	return nil
+}
+
+// ListComments 实现 Repository
+func (r *MemoryRepository) ListComments(ctx context.Context, postID int64) ([]Comment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	comments := []Comment{}
+	for _, c := range r.comments {
+		if c.PostID == postID {
+			comments = append(comments, c)
+		}
+	}
+	return comments, nil
+}
+
+// CreateComment 实现 Repository
+func (r *MemoryRepository) CreateComment(ctx context.Context, c *Comment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := false
+	for _, p := range r.posts {
+		if p.ID == c.PostID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+	if c.ParentID != 0 {
+		var parent *Comment
+		for i := range r.comments {
+			if r.comments[i].ID == c.ParentID {
+				parent = &r.comments[i]
+				break
+			}
+		}
+		if parent == nil {
+			return ErrNotFound
+		}
+		if parent.PostID != c.PostID {
+			return ErrInvalidParent
+		}
+	}
+	r.commentID++
+	c.ID = r.commentID
+	c.CreatedAt = r.now()
+	r.comments = append(r.comments, *c)
+	return nil
+}
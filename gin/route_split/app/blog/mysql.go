@@ -0,0 +1,145 @@
+package blog
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Schema 博客的表结构
+const Schema = `
+CREATE TABLE IF NOT EXISTS posts (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	title VARCHAR(200) NOT NULL,
+	content TEXT NOT NULL,
+	author VARCHAR(64) NOT NULL,
+	created_at DATETIME NOT NULL,
+	KEY idx_created (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE IF NOT EXISTS comments (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	post_id BIGINT NOT NULL,
+	parent_id BIGINT NOT NULL DEFAULT 0,
+	author VARCHAR(64) NOT NULL,
+	content TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	KEY idx_post (post_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+// MySQLRepository MySQL 实现，DSN 需要带上 parseTime=True
+type MySQLRepository struct {
+	db *sqlx.DB
+}
+
+// NewMySQLRepository 创建 MySQL 存储
+func NewMySQLRepository(db *sqlx.DB) *MySQLRepository {
+	return &MySQLRepository{db: db}
+}
+
+// CreateSchema 建表，DSN 需要带上 multiStatements=true
+func (r *MySQLRepository) CreateSchema(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, Schema)
+	return err
+}
+
+// ListPosts 实现 Repository
+func (r *MySQLRepository) ListPosts(ctx context.Context, offset, limit int) ([]Post, int, error) {
+	var total int
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM posts"); err != nil {
+		return nil, 0, err
+	}
+	if limit <= 0 {
+		limit = total
+	}
+	posts := []Post{}
+	err := r.db.SelectContext(ctx, &posts,
+		"SELECT id, title, content, author, created_at FROM posts ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return posts, total, nil
+}
+
+// GetPost 实现 Repository
+func (r *MySQLRepository) GetPost(ctx context.Context, id int64) (*Post, error) {
+	var p Post
+	err := r.db.GetContext(ctx, &p, "SELECT id, title, content, author, created_at FROM posts WHERE id = ?", id)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreatePost 实现 Repository
+func (r *MySQLRepository) CreatePost(ctx context.Context, p *Post) error {
+	p.CreatedAt = time.Now()
+	ret, err := r.db.NamedExecContext(ctx,
+		"INSERT INTO posts (title, content, author, created_at) VALUES (:title, :content, :author, :created_at)", p)
+	if err != nil {
+		return err
+	}
+	p.ID, err = ret.LastInsertId()
+	return err
+}
+
+// ListComments 实现 Repository
+func (r *MySQLRepository) ListComments(ctx context.Context, postID int64) ([]Comment, error) {
+	comments := []Comment{}
+	err := r.db.SelectContext(ctx, &comments,
+		"SELECT id, post_id, parent_id, author, content, created_at FROM comments WHERE post_id = ? ORDER BY id", postID)
+	return comments, err
+}
+
+// CreateComment 实现 Repository，检查文章和父评论时加共享锁，防止插入前被删除
+func (r *MySQLRepository) CreateComment(ctx context.Context, c *Comment) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var id int64
+	err = tx.GetContext(ctx, &id, "SELECT id FROM posts WHERE id = ? LOCK IN SHARE MODE", c.PostID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if c.ParentID != 0 {
+		var postID int64
+		err = tx.GetContext(ctx, &postID, "SELECT post_id FROM comments WHERE id = ? LOCK IN SHARE MODE", c.ParentID)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if postID != c.PostID {
+			return ErrInvalidParent
+		}
+	}
+	c.CreatedAt = time.Now()
+	ret, err := tx.NamedExecContext(ctx,
+		"INSERT INTO comments (post_id, parent_id, author, content, created_at) VALUES (:post_id, :parent_id, :author, :content, :created_at)", c)
+	if err != nil {
+		return err
+	}
+	c.ID, err = ret.LastInsertId()
+	return err
+}
@@ -0,0 +1,68 @@
+package blog
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrNotFound 文章或评论不存在
+	ErrNotFound = errors.New("blog: not found")
+	// ErrInvalidParent 回复的评论不属于这篇文章
+	ErrInvalidParent = errors.New("blog: parent comment belongs to another post")
+)
+
+// Post 文章
+type Post struct {
+	ID        int64     `db:"id" json:"id"`
+	Title     string    `db:"title" json:"title" binding:"required,max=200"`
+	Content   string    `db:"content" json:"content" binding:"required"`
+	Author    string    `db:"author" json:"author"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Comment 评论，ParentID 为 0 表示直接评论文章，否则是回复另一条评论
+type Comment struct {
+	ID        int64      `db:"id" json:"id"`
+	PostID    int64      `db:"post_id" json:"post_id"`
+	ParentID  int64      `db:"parent_id" json:"parent_id"`
+	Author    string     `db:"author" json:"author"`
+	Content   string     `db:"content" json:"content"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	Replies   []*Comment `db:"-" json:"replies,omitempty"`
+}
+
+// Repository 博客的存储
+type Repository interface {
+	// ListPosts 按发布时间倒序分页，返回当前页和总数
+	ListPosts(ctx context.Context, offset, limit int) ([]Post, int, error)
+	GetPost(ctx context.Context, id int64) (*Post, error)
+	CreatePost(ctx context.Context, p *Post) error
+	// ListComments 返回文章的所有评论，按 ID 排序
+	ListComments(ctx context.Context, postID int64) ([]Comment, error)
+	// CreateComment 文章或父评论不存在时返回 ErrNotFound
+	CreateComment(ctx context.Context, c *Comment) error
+}
+
+// Thread 把按 ID 排序的评论组装成树，父评论找不到的评论当作顶层评论
+func Thread(comments []Comment) []*Comment {
+	nodes := make(map[int64]*Comment, len(comments))
+	for i := range comments {
+		c := comments[i]
+		c.Replies = nil
+		nodes[c.ID] = &c
+	}
+	roots := []*Comment{}
+	for i := range comments {
+		c := nodes[comments[i].ID]
+		if parent, ok := nodes[c.ParentID]; ok && c.ParentID != 0 {
+			parent.Replies = append(parent.Replies, c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].ID < roots[j].ID })
+	return roots
+}
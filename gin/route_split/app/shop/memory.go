@@ -0,0 +1,103 @@
+package shop
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository 内存实现，用于测试和没有配置数据库时的演示
+type MemoryRepository struct {
+	mu      sync.Mutex
+	goods   map[int64]*Goods
+	orders  []*Order
+	nextID  int64
+	orderID int64
+}
+
+// NewMemoryRepository 创建内存存储，goods 为初始商品，ID 为 0 时自动分配
+func NewMemoryRepository(goods ...Goods) *MemoryRepository {
+	r := &MemoryRepository{goods: make(map[int64]*Goods)}
+	for _, g := range goods {
+		g := g
+		if g.ID == 0 {
+			r.nextID++
+			g.ID = r.nextID
+		} else if g.ID > r.nextID {
+			r.nextID = g.ID
+		}
+		if g.CreatedAt.IsZero() {
+			g.CreatedAt = time.Now()
+		}
+		r.goods[g.ID] = &g
+	}
+	return r
+}
+
+// ListGoods 实现 Repository
+func (r *MemoryRepository) ListGoods(ctx context.Context, f GoodsFilter) ([]Goods, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matched []Goods
+	for _, g := range r.goods {
+		if f.match(*g) {
+			matched = append(matched, *g)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+	total := len(matched)
+	if f.Offset >= total {
+		return []Goods{}, total, nil
+	}
+	matched = matched[f.Offset:]
+	if f.Limit > 0 && len(matched) > f.Limit {
+		matched = matched[:f.Limit]
+	}
+	return matched, total, nil
+}
+
+// GetGoods 实现 Repository
+func (r *MemoryRepository) GetGoods(ctx context.Context, id int64) (*Goods, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.goods[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *g
+	return &cp, nil
+}
+
+// CreateOrder 实现 Repository，先检查全部库存再扣减，效果等同于事务
+func (r *MemoryRepository) CreateOrder(ctx context.Context, user string, items []OrderItem) (*Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	need := make(map[int64]int)
+	for _, it := range items {
+		g, ok := r.goods[it.GoodsID]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		need[it.GoodsID] += it.Quantity
+		if g.Stock < need[it.GoodsID] {
+			return nil, ErrOutOfStock
+		}
+	}
+	r.orderID++
+	o := &Order{ID: r.orderID, User: user, CreatedAt: time.Now()}
+	for _, it := range items {
+		g := r.goods[it.GoodsID]
+		g.Stock -= it.Quantity
+		it.Price = g.Price
+		o.Total += it.Price * int64(it.Quantity)
+		o.Items = append(o.Items, it)
+	}
+	r.orders = append(r.orders, o)
+	return o, nil
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
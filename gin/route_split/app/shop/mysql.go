@@ -0,0 +1,171 @@
+package shop
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Schema 商城的表结构
+const Schema = `
+CREATE TABLE IF NOT EXISTS goods (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(128) NOT NULL,
+	category VARCHAR(64) NOT NULL DEFAULT '',
+	price BIGINT NOT NULL,
+	stock INT NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	KEY idx_category (category)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE IF NOT EXISTS orders (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	user VARCHAR(64) NOT NULL,
+	total BIGINT NOT NULL,
+	created_at DATETIME NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+CREATE TABLE IF NOT EXISTS order_items (
+	order_id BIGINT NOT NULL,
+	goods_id BIGINT NOT NULL,
+	quantity INT NOT NULL,
+	price BIGINT NOT NULL,
+	KEY idx_order (order_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+`
+
+// MySQLRepository MySQL 实现，DSN 需要带上 parseTime=True
+type MySQLRepository struct {
+	db *sqlx.DB
+}
+
+// NewMySQLRepository 创建 MySQL 存储
+func NewMySQLRepository(db *sqlx.DB) *MySQLRepository {
+	return &MySQLRepository{db: db}
+}
+
+// CreateSchema 建表，DSN 需要带上 multiStatements=true
+func (r *MySQLRepository) CreateSchema(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, Schema)
+	return err
+}
+
+// ListGoods 实现 Repository
+func (r *MySQLRepository) ListGoods(ctx context.Context, f GoodsFilter) ([]Goods, int, error) {
+	var where []string
+	var args []interface{}
+	if f.Category != "" {
+		where = append(where, "category = ?")
+		args = append(args, f.Category)
+	}
+	if f.Keyword != "" {
+		where = append(where, "name LIKE ?")
+		args = append(args, "%"+escapeLike(f.Keyword)+"%")
+	}
+	if f.MinPrice > 0 {
+		where = append(where, "price >= ?")
+		args = append(args, f.MinPrice)
+	}
+	if f.MaxPrice > 0 {
+		where = append(where, "price <= ?")
+		args = append(args, f.MaxPrice)
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM goods"+cond, args...); err != nil {
+		return nil, 0, err
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = total
+	}
+	goods := []Goods{}
+	sqlStr := "SELECT id, name, category, price, stock, created_at FROM goods" + cond + " ORDER BY id LIMIT ? OFFSET ?"
+	if err := r.db.SelectContext(ctx, &goods, sqlStr, append(args, limit, f.Offset)...); err != nil {
+		return nil, 0, err
+	}
+	return goods, total, nil
+}
+
+// GetGoods 实现 Repository
+func (r *MySQLRepository) GetGoods(ctx context.Context, id int64) (*Goods, error) {
+	var g Goods
+	err := r.db.GetContext(ctx, &g, "SELECT id, name, category, price, stock, created_at FROM goods WHERE id = ?", id)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// CreateOrder 实现 Repository
+// 在一个事务中用 SELECT ... FOR UPDATE 锁住商品行，检查库存后扣减，再写入订单和订单明细
+func (r *MySQLRepository) CreateOrder(ctx context.Context, user string, items []OrderItem) (o *Order, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// 按商品 ID 顺序加锁，避免两个订单互相等待对方锁住的行
+	sorted := append([]OrderItem(nil), items...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].GoodsID < sorted[j].GoodsID })
+	o = &Order{User: user, CreatedAt: time.Now()}
+	for _, it := range sorted {
+		var g Goods
+		err = tx.GetContext(ctx, &g, "SELECT id, price, stock FROM goods WHERE id = ? FOR UPDATE", it.GoodsID)
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if g.Stock < it.Quantity {
+			return nil, ErrOutOfStock
+		}
+		if _, err = tx.ExecContext(ctx, "UPDATE goods SET stock = stock - ? WHERE id = ?", it.Quantity, it.GoodsID); err != nil {
+			return nil, err
+		}
+		it.Price = g.Price
+		o.Total += it.Price * int64(it.Quantity)
+		o.Items = append(o.Items, it)
+	}
+
+	ret, err := tx.ExecContext(ctx, "INSERT INTO orders (user, total, created_at) VALUES (?, ?, ?)", o.User, o.Total, o.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if o.ID, err = ret.LastInsertId(); err != nil {
+		return nil, err
+	}
+	for _, it := range o.Items {
+		_, err = tx.ExecContext(ctx, "INSERT INTO order_items (order_id, goods_id, quantity, price) VALUES (?, ?, ?, ?)",
+			o.ID, it.GoodsID, it.Quantity, it.Price)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// escapeLike 转义 LIKE 中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,70 @@
+package shop
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound 商品或订单不存在
+	ErrNotFound = errors.New("shop: not found")
+	// ErrOutOfStock 库存不足
+	ErrOutOfStock = errors.New("shop: out of stock")
+)
+
+// Goods 商品，价格以分为单位
+type Goods struct {
+	ID        int64     `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	Category  string    `db:"category" json:"category"`
+	Price     int64     `db:"price" json:"price"`
+	Stock     int       `db:"stock" json:"stock"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// GoodsFilter 商品列表的筛选条件
+type GoodsFilter struct {
+	Category string
+	// Keyword 按名称模糊匹配
+	Keyword  string
+	MinPrice int64
+	// MaxPrice 为 0 表示不限
+	MaxPrice int64
+	// Offset、Limit 分页
+	Offset int
+	Limit  int
+}
+
+func (f GoodsFilter) match(g Goods) bool {
+	return (f.Category == "" || g.Category == f.Category) &&
+		(f.Keyword == "" || containsFold(g.Name, f.Keyword)) &&
+		g.Price >= f.MinPrice &&
+		(f.MaxPrice == 0 || g.Price <= f.MaxPrice)
+}
+
+// OrderItem 订单中的一个商品
+type OrderItem struct {
+	GoodsID  int64 `db:"goods_id" json:"goods_id" binding:"required"`
+	Quantity int   `db:"quantity" json:"quantity" binding:"required,min=1"`
+	// Price 下单时的单价
+	Price int64 `db:"price" json:"price"`
+}
+
+// Order 订单
+type Order struct {
+	ID        int64       `db:"id" json:"id"`
+	User      string      `db:"user" json:"user"`
+	Total     int64       `db:"total" json:"total"`
+	CreatedAt time.Time   `db:"created_at" json:"created_at"`
+	Items     []OrderItem `db:"-" json:"items"`
+}
+
+// Repository 商城的存储
+type Repository interface {
+	// ListGoods 返回当前页的商品和符合条件的总数，按 ID 排序
+	ListGoods(ctx context.Context, f GoodsFilter) ([]Goods, int, error)
+	GetGoods(ctx context.Context, id int64) (*Goods, error)
+	// CreateOrder 扣减库存并创建订单，任何一个商品库存不足时整个订单失败
+	CreateOrder(ctx context.Context, user string, items []OrderItem) (*Order, error)
+}
@@ -0,0 +1,200 @@
+package shop
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"main/module"
+	"main/version"
+)
+
+func init() {
+	module.Register(New())
+}
+
+// Module 商城模块，挂载在 /shop 下
+type Module struct {
+	repo Repository
+	db   *sqlx.DB
+}
+
+// New 创建商城模块，默认使用带演示数据的内存存储，Init 时配置了 MYSQL_DSN 则换成 MySQL
+func New() *Module {
+	return &Module{repo: NewMemoryRepository(
+		Goods{Name: "Go 语言圣经", Category: "book", Price: 8900, Stock: 100},
+		Goods{Name: "Go 并发编程实战", Category: "book", Price: 6900, Stock: 50},
+		Goods{Name: "机械键盘", Category: "digital", Price: 49900, Stock: 10},
+	)}
+}
+
+// NewWithRepository 使用指定的存储
+func NewWithRepository(repo Repository) *Module {
+	return &Module{repo: repo}
+}
+
+// Name 实现 module.Module
+func (m *Module) Name() string {
+	return "shop"
+}
+
+// Init 实现 module.Module
+func (m *Module) Init(ctx context.Context) error {
+	dsn := os.Getenv("MYSQL_DSN")
+	if dsn == "" {
+		return nil
+	}
+	db, err := sqlx.ConnectContext(ctx, "mysql", dsn)
+	if err != nil {
+		return err
+	}
+	db.SetMaxOpenConns(20)
+	db.SetMaxIdleConns(10)
+	m.db, m.repo = db, NewMySQLRepository(db)
+	return nil
+}
+
+// Close 实现 module.Module
+func (m *Module) Close(ctx context.Context) error {
+	if m.db != nil {
+		return m.db.Close()
+	}
+	return nil
+}
+
+// Routes 同时提供 /v1/goods、/v2/goods 以及根据 Accept 头选择版本的 /goods
+func (m *Module) Routes(g *gin.RouterGroup) {
+	api := version.New("shop")
+	// 老客户端请求 /goods 时没有带版本，继续使用 v1
+	api.Default = "v1"
+	v1 := api.Version("v1")
+	v1.GET("/goods", m.goodsHandler)
+	v1.GET("/goods/:id", m.goodsDetailHandler)
+	v1.POST("/checkout", m.checkoutHandler)
+	// v2 只改了商品列表的返回格式，其他接口沿用 v1 的处理函数
+	v2 := api.Version("v2")
+	v2.GET("/goods", m.goodsV2Handler)
+
+	api.Mount(g)
+	api.MountNegotiated(g)
+}
+
+// goodsQuery 商品列表的查询参数 /goods?page=1&size=20&category=book&q=go&min_price=100&max_price=10000
+type goodsQuery struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	Size     int    `form:"size" binding:"omitempty,min=1,max=100"`
+	Category string `form:"category"`
+	Keyword  string `form:"q"`
+	MinPrice int64  `form:"min_price" binding:"omitempty,min=0"`
+	MaxPrice int64  `form:"max_price" binding:"omitempty,min=0"`
+}
+
+func (m *Module) listGoods(c *gin.Context) (q goodsQuery, goods []Goods, total int, ok bool) {
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return q, nil, 0, false
+	}
+	if q.Page == 0 {
+		q.Page = 1
+	}
+	if q.Size == 0 {
+		q.Size = 20
+	}
+	goods, total, err := m.repo.ListGoods(c.Request.Context(), GoodsFilter{
+		Category: q.Category,
+		Keyword:  q.Keyword,
+		MinPrice: q.MinPrice,
+		MaxPrice: q.MaxPrice,
+		Offset:   (q.Page - 1) * q.Size,
+		Limit:    q.Size,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return q, nil, 0, false
+	}
+	return q, goods, total, true
+}
+
+func (m *Module) goodsHandler(c *gin.Context) {
+	q, goods, total, ok := m.listGoods(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"total": total,
+		"page":  q.Page,
+		"size":  q.Size,
+		"items": goods,
+	})
+}
+
+// goodsV2Handler 分页信息放在 paging 中，并给出总页数
+func (m *Module) goodsV2Handler(c *gin.Context) {
+	q, goods, total, ok := m.listGoods(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": goods,
+		"paging": gin.H{
+			"page":  q.Page,
+			"size":  q.Size,
+			"total": total,
+			"pages": (total + q.Size - 1) / q.Size,
+		},
+	})
+}
+
+func (m *Module) goodsDetailHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid goods id"})
+		return
+	}
+	g, err := m.repo.GetGoods(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, g)
+}
+
+// checkoutRequest {"items": [{"goods_id": 1, "quantity": 2}]}
+type checkoutRequest struct {
+	Items []OrderItem `json:"items" binding:"required,min=1,dive"`
+}
+
+func (m *Module) checkoutHandler(c *gin.Context) {
+	var req checkoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 经过 JWTAuthMiddleware 时使用登录的用户名
+	user := c.GetString("username")
+	if user == "" {
+		user = "guest"
+	}
+	o, err := m.repo.CreateOrder(c.Request.Context(), user, req.Items)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, o)
+}
+
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrOutOfStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
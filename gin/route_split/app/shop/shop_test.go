@@ -0,0 +1,101 @@
+package shop
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newTestEngine() (*gin.Engine, *MemoryRepository) {
+	repo := NewMemoryRepository(
+		Goods{Name: "Go Book", Category: "book", Price: 8900, Stock: 2},
+		Goods{Name: "Rust Book", Category: "book", Price: 9900, Stock: 5},
+		Goods{Name: "Keyboard", Category: "digital", Price: 49900, Stock: 1},
+	)
+	e := gin.New()
+	NewWithRepository(repo).Routes(&e.RouterGroup)
+	return e, repo
+}
+
+func do(e *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestGoodsFilterAndPaging(t *testing.T) {
+	e, _ := newTestEngine()
+
+	w := do(e, http.MethodGet, "/v1/goods?category=book&size=1&page=2", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var v1 struct {
+		Total int     `json:"total"`
+		Items []Goods `json:"items"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &v1)
+	if v1.Total != 2 || len(v1.Items) != 1 || v1.Items[0].Name != "Rust Book" {
+		t.Fatalf("unexpected v1 page: %+v", v1)
+	}
+
+	w = do(e, http.MethodGet, "/v2/goods?q=BOOK&max_price=9000", "")
+	var v2 struct {
+		Data   []Goods        `json:"data"`
+		Paging map[string]int `json:"paging"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &v2)
+	if len(v2.Data) != 1 || v2.Data[0].Name != "Go Book" || v2.Paging["pages"] != 1 {
+		t.Fatalf("unexpected v2 page: %+v", v2)
+	}
+
+	if w := do(e, http.MethodGet, "/v1/goods?size=1000", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("oversized page status = %d", w.Code)
+	}
+}
+
+func TestCheckout(t *testing.T) {
+	e, repo := newTestEngine()
+
+	w := do(e, http.MethodPost, "/v1/checkout", `{"items":[{"goods_id":1,"quantity":2},{"goods_id":3,"quantity":1}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var o Order
+	json.Unmarshal(w.Body.Bytes(), &o)
+	if o.Total != 2*8900+49900 || o.User != "guest" {
+		t.Fatalf("unexpected order: %+v", o)
+	}
+	if g, _ := repo.GetGoods(context.Background(), 1); g.Stock != 0 {
+		t.Fatalf("stock = %d, want 0", g.Stock)
+	}
+
+	// 第二个商品库存不足时，第一个商品的库存不能被扣减
+	w = do(e, http.MethodPost, "/v1/checkout", `{"items":[{"goods_id":2,"quantity":1},{"goods_id":3,"quantity":1}]}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("out of stock status = %d", w.Code)
+	}
+	if g, _ := repo.GetGoods(context.Background(), 2); g.Stock != 5 {
+		t.Fatalf("stock = %d, want 5", g.Stock)
+	}
+
+	if w := do(e, http.MethodPost, "/v1/checkout", `{"items":[{"goods_id":42,"quantity":1}]}`); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown goods status = %d", w.Code)
+	}
+	if w := do(e, http.MethodPost, "/v1/checkout", `{"items":[]}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("empty order status = %d", w.Code)
+	}
+}
@@ -2,4 +2,10 @@ module main
 
 go 1.14
 
-require github.com/gin-gonic/gin v1.7.7
+require (
+	github.com/gin-gonic/gin v1.7.7
+	github.com/go-sql-driver/mysql v1.6.0
+	github.com/jmoiron/sqlx v1.3.4
+	github.com/prometheus/client_golang v1.12.2
+	golang.org/x/sys v0.0.0-20220319134239-a9b59b0215f8 // indirect
+)
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"main/admin"
+	_ "main/app"
+	"main/metrics"
+	"main/module"
 	"main/routers"
 	"net/http"
+	"os"
 )
 
 func helloHandler(c *gin.Context) {
@@ -18,7 +25,7 @@ func base() {
 	r := gin.Default()
 	r.GET("/hello", helloHandler)
 	if err := r.Run(); err != nil {
-		fmt.Println("startup service failed, err:%v\n", err)
+		fmt.Printf("startup service failed, err:%v\n", err)
 	}
 }
 
@@ -30,7 +37,7 @@ func split() {
 	// 调用 routers.go 中定义的setupRouter
 	r := routers.SetupRouter()
 	if err := r.Run(); err != nil {
-		fmt.Println("startup service failed, err:%v\n", err)
+		fmt.Printf("startup service failed, err:%v\n", err)
 	}
 }
 
@@ -41,15 +48,99 @@ func multiSplit() {
 	routers.LoadShop(r)
 	routers.LoadBlog(r)
 	if err := r.Run(); err != nil {
-		fmt.Println("startup service failed, err:%v\n", err)
+		fmt.Printf("startup service failed, err:%v\n", err)
 	}
 }
 
 
+// config 服务配置，从环境变量读取
+type config struct {
+	Addr       string `json:"addr"`
+	AdminAddr  string `json:"admin_addr"`
+	AdminToken string `json:"admin_token"`
+	MySQLDSN   string `json:"mysql_dsn"`
+}
+
+func loadConfig() config {
+	return config{
+		Addr:       os.Getenv("ADDR"),
+		AdminAddr:  os.Getenv("ADMIN_ADDR"),
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+		MySQLDSN:   os.Getenv("MYSQL_DSN"),
+	}
+}
+
+var (
+	printRoutes = flag.Bool("routes", false, "打印路由表后退出")
+	adminAddr   = flag.String("admin", "", "管理接口的监听地址，例如 127.0.0.1:6060，为空时不开启，也可以通过 ADMIN_ADDR 设置")
+)
+
+// 拆分到不同的模块
+// 业务模块在 app 目录下实现 module.Module 并在 init 中注册，新增业务不需要修改这里
+func appSplit() {
+	ctx := context.Background()
+	cfg := loadConfig()
+	r := gin.Default()
+	// 指标记录所有模块的请求，通过管理接口的 /metrics 输出，不暴露在业务端口上
+	m := metrics.New("gin", nil)
+	r.Use(m.Middleware())
+	// 按依赖顺序 Init，然后把每个模块挂载到 /{模块名} 下，例如 /shop/v1/goods、/blog/post
+	if err := module.Setup(ctx, r); err != nil {
+		fmt.Printf("setup modules failed, err:%v\n", err)
+		return
+	}
+	defer module.Close(ctx)
+
+	// 管理接口单独监听一个内网端口，使用单独的 token 认证
+	if *adminAddr != "" {
+		cfg.AdminAddr = *adminAddr
+	}
+	if cfg.AdminAddr != "" {
+		a, err := admin.New(admin.Config{Engine: r, Token: cfg.AdminToken, Settings: cfg, Metrics: m.Handler()})
+		if err != nil {
+			fmt.Printf("setup admin failed, err:%v\n", err)
+			return
+		}
+		go func() {
+			if err := a.Run(cfg.AdminAddr); err != nil {
+				fmt.Printf("startup admin failed, err:%v\n", err)
+			}
+		}()
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = ":8080"
+	}
+	if err := r.Run(cfg.Addr); err != nil {
+		fmt.Printf("startup service failed, err:%v\n", err)
+	}
+}
+
+// routeTable 只挂载路由不初始化模块（不会连接数据库），打印路由表
+// go run . -routes
+func routeTable() {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	if err := module.Default.Mount(r); err != nil {
+		fmt.Printf("mount modules failed, err:%v\n", err)
+		os.Exit(1)
+	}
+	admin.PrintRoutes(os.Stdout, r)
+}
+
+
 func main() {
+	flag.Parse()
+	if *printRoutes {
+		routeTable()
+		return
+	}
+
 	// 基础
 	//base()
 
+	// 模块
+	//appSplit()
+
 
 
 }
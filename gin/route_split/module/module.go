@@ -0,0 +1,223 @@
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Module 一个业务模块，例如 shop、blog
+type Module interface {
+	// Name 模块名，同时作为默认的路由前缀 /{Name}
+	Name() string
+	// Routes 在模块自己的路由组上注册路由
+	Routes(g *gin.RouterGroup)
+	// Init 在注册路由之前调用，按依赖顺序执行，例如连接数据库
+	Init(ctx context.Context) error
+	// Close 服务退出时按依赖的逆序调用
+	Close(ctx context.Context) error
+}
+
+// Prefixer 自定义路由前缀，返回 "" 表示挂在根路径上
+type Prefixer interface {
+	Prefix() string
+}
+
+// Middlewarer 模块路由组的中间件
+type Middlewarer interface {
+	Middleware() []gin.HandlerFunc
+}
+
+// Depender 声明依赖的模块，依赖的模块会先 Init、后 Close
+type Depender interface {
+	DependsOn() []string
+}
+
+// Base 提供空的 Init 和 Close，不需要初始化的模块可以嵌入它
+type Base struct{}
+
+// Init 实现 Module
+func (Base) Init(ctx context.Context) error { return nil }
+
+// Close 实现 Module
+func (Base) Close(ctx context.Context) error { return nil }
+
+// Registry 模块注册表
+type Registry struct {
+	mu      sync.Mutex
+	modules []Module
+	started []Module
+}
+
+// Register 注册模块，模块名重复时 panic
+func (r *Registry) Register(m Module) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, old := range r.modules {
+		if old.Name() == m.Name() {
+			panic("module: Register called twice for module " + m.Name())
+		}
+	}
+	r.modules = append(r.modules, m)
+}
+
+// Modules 按依赖排序后的模块，没有依赖关系的模块保持注册顺序
+func (r *Registry) Modules() ([]Module, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return sortModules(r.modules)
+}
+
+// Init 按依赖顺序初始化所有模块，失败时逆序关闭已经初始化的模块
+func (r *Registry) Init(ctx context.Context) error {
+	mods, err := r.Modules()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range mods {
+		if err := m.Init(ctx); err != nil {
+			err = fmt.Errorf("module %s: init: %w", m.Name(), err)
+			return joinErrors(err, r.closeStarted(ctx))
+		}
+		r.started = append(r.started, m)
+	}
+	return nil
+}
+
+// Mount 把模块挂载到各自的前缀下，中间件只作用于模块自己的路由
+func (r *Registry) Mount(g gin.IRouter) error {
+	mods, err := r.Modules()
+	if err != nil {
+		return err
+	}
+	for _, m := range mods {
+		prefix := "/" + m.Name()
+		if p, ok := m.(Prefixer); ok {
+			prefix = p.Prefix()
+		}
+		var middleware []gin.HandlerFunc
+		if mw, ok := m.(Middlewarer); ok {
+			middleware = mw.Middleware()
+		}
+		m.Routes(g.Group(prefix, middleware...))
+	}
+	return nil
+}
+
+// Setup Init 之后 Mount
+func (r *Registry) Setup(ctx context.Context, g gin.IRouter) error {
+	if err := r.Init(ctx); err != nil {
+		return err
+	}
+	return r.Mount(g)
+}
+
+// Close 逆序关闭已经初始化的模块，一个模块失败不影响其他模块关闭
+func (r *Registry) Close(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closeStarted(ctx)
+}
+
+func (r *Registry) closeStarted(ctx context.Context) error {
+	var errs []error
+	for i := len(r.started) - 1; i >= 0; i-- {
+		m := r.started[i]
+		if err := m.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("module %s: close: %w", m.Name(), err))
+		}
+	}
+	r.started = nil
+	return joinErrors(errs...)
+}
+
+// sortModules 拓扑排序，依赖不存在或者循环依赖时返回错误
+func sortModules(mods []Module) ([]Module, error) {
+	byName := make(map[string]Module, len(mods))
+	for _, m := range mods {
+		byName[m.Name()] = m
+	}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(mods))
+	sorted := make([]Module, 0, len(mods))
+	var visit func(m Module, path []string) error
+	visit = func(m Module, path []string) error {
+		name := m.Name()
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("module: dependency cycle %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+		if d, ok := m.(Depender); ok {
+			for _, dep := range d.DependsOn() {
+				dm, ok := byName[dep]
+				if !ok {
+					return fmt.Errorf("module %s: depends on unknown module %s", name, dep)
+				}
+				if err := visit(dm, append(path, name)); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = done
+		sorted = append(sorted, m)
+		return nil
+	}
+	for _, m := range mods {
+		if err := visit(m, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// joinErrors go 1.14 还没有 errors.Join，多个错误拼成一个
+func joinErrors(errs ...error) error {
+	var msgs []string
+	var first error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if first == nil {
+			first = err
+		}
+		msgs = append(msgs, err.Error())
+	}
+	switch len(msgs) {
+	case 0:
+		return nil
+	case 1:
+		return first
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// Default 默认的注册表，业务模块在 init 中调用 Register 注册到这里
+var Default = &Registry{}
+
+// Register 注册到 Default
+func Register(m Module) {
+	Default.Register(m)
+}
+
+// Setup 初始化并挂载 Default 中的模块
+func Setup(ctx context.Context, g gin.IRouter) error {
+	return Default.Setup(ctx, g)
+}
+
+// Close 关闭 Default 中的模块
+func Close(ctx context.Context) error {
+	return Default.Close(ctx)
+}
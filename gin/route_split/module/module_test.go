@@ -0,0 +1,128 @@
+package module
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+type fakeModule struct {
+	name    string
+	deps    []string
+	initErr error
+	log     *[]string
+}
+
+func (m *fakeModule) Name() string        { return m.name }
+func (m *fakeModule) DependsOn() []string { return m.deps }
+
+func (m *fakeModule) Routes(g *gin.RouterGroup) {
+	g.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, m.name+" "+c.GetHeader("X-Seen"))
+	})
+}
+
+func (m *fakeModule) Middleware() []gin.HandlerFunc {
+	return []gin.HandlerFunc{func(c *gin.Context) {
+		c.Request.Header.Set("X-Seen", m.name+"-mw")
+	}}
+}
+
+func (m *fakeModule) Init(ctx context.Context) error {
+	*m.log = append(*m.log, "init "+m.name)
+	return m.initErr
+}
+
+func (m *fakeModule) Close(ctx context.Context) error {
+	*m.log = append(*m.log, "close "+m.name)
+	return nil
+}
+
+type rootModule struct {
+	Base
+}
+
+func (rootModule) Name() string   { return "root" }
+func (rootModule) Prefix() string { return "" }
+func (rootModule) Routes(g *gin.RouterGroup) {
+	g.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "root") })
+}
+
+func TestRegistry(t *testing.T) {
+	var log []string
+	r := &Registry{}
+	r.Register(&fakeModule{name: "shop", deps: []string{"user", "blog"}, log: &log})
+	r.Register(&fakeModule{name: "blog", deps: []string{"user"}, log: &log})
+	r.Register(&fakeModule{name: "user", log: &log})
+	r.Register(rootModule{})
+
+	e := gin.New()
+	if err := r.Setup(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(log, ","); got != "init user,init blog,init shop" {
+		t.Fatalf("init order = %s", got)
+	}
+	for path, want := range map[string]string{"/shop/ping": "shop shop-mw", "/user/ping": "user user-mw", "/": "root"} {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Body.String() != want {
+			t.Errorf("GET %s = %q, want %q", path, w.Body.String(), want)
+		}
+	}
+
+	log = nil
+	if err := r.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(log, ","); got != "close shop,close blog,close user" {
+		t.Fatalf("close order = %s", got)
+	}
+}
+
+func TestRegistryInitFailure(t *testing.T) {
+	var log []string
+	r := &Registry{}
+	r.Register(&fakeModule{name: "user", log: &log})
+	r.Register(&fakeModule{name: "shop", deps: []string{"user"}, initErr: errors.New("db down"), log: &log})
+	err := r.Init(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "module shop: init: db down") {
+		t.Fatalf("Init = %v", err)
+	}
+	// 已经初始化的模块要关闭
+	if got := strings.Join(log, ","); got != "init user,init shop,close user" {
+		t.Fatalf("log = %s", got)
+	}
+}
+
+func TestRegistryDependencyErrors(t *testing.T) {
+	var log []string
+	r := &Registry{}
+	r.Register(&fakeModule{name: "a", deps: []string{"b"}, log: &log})
+	r.Register(&fakeModule{name: "b", deps: []string{"a"}, log: &log})
+	if _, err := r.Modules(); err == nil || !strings.Contains(err.Error(), "cycle a -> b -> a") {
+		t.Fatalf("cycle: %v", err)
+	}
+
+	r = &Registry{}
+	r.Register(&fakeModule{name: "a", deps: []string{"missing"}, log: &log})
+	if _, err := r.Modules(); err == nil || !strings.Contains(err.Error(), "unknown module missing") {
+		t.Fatalf("missing: %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("duplicate Register should panic")
+		}
+	}()
+	r.Register(&fakeModule{name: "a", log: &log})
+}
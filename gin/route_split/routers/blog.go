@@ -1,16 +1,11 @@
 package routers
 
-import "github.com/gin-gonic/gin"
-
-func postHandler(c *gin.Context) {
-
-}
-
-func commentHandler(c *gin.Context) {
-
-}
+import (
+	"github.com/gin-gonic/gin"
+	"main/app/blog"
+)
 
+// LoadBlog 把博客的路由挂在根路径上，路由定义见 app/blog
 func LoadBlog(e *gin.Engine)  {
-	e.GET("/post", postHandler)
-	e.GET("/comment", commentHandler)
-}
\ No newline at end of file
+	blog.New().Routes(&e.RouterGroup)
+}
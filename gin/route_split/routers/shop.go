@@ -1,16 +1,11 @@
 package routers
 
-import "github.com/gin-gonic/gin"
-
-func goodsHandler(c *gin.Context) {
-
-}
-
-func checkoutHandler(c *gin.Context) {
-
-}
+import (
+	"github.com/gin-gonic/gin"
+	"main/app/shop"
+)
 
+// LoadShop 把商城的路由挂在根路径上，路由定义见 app/shop
 func LoadShop(e *gin.Engine)  {
-	e.GET("/goods", goodsHandler)
-	e.GET("/checkout", checkoutHandler)
-}
\ No newline at end of file
+	shop.New().Routes(&e.RouterGroup)
+}
@@ -0,0 +1,300 @@
+package version
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API 一组带版本的路由
+// 每个版本默认继承上一个版本的全部路由，只需要注册有变化的接口
+type API struct {
+	// Vendor 用于 Accept: application/vnd.{Vendor}.v2+json，为空时只识别 version 参数
+	Vendor string
+	// Default 请求没有指定版本时使用的版本，为空时使用最新的版本
+	Default string
+
+	versions []*Version
+}
+
+// New 创建 API
+func New(vendor string) *API {
+	return &API{Vendor: vendor}
+}
+
+// Option 版本选项
+type Option func(*Version)
+
+// Deprecated 标记版本已废弃，响应中带上 Deprecation 头
+func Deprecated(at time.Time) Option {
+	return func(v *Version) {
+		v.deprecated = at
+	}
+}
+
+// Sunset 版本的下线时间，响应中带上 Sunset 头，link 为迁移文档地址，可以为空
+func Sunset(at time.Time, link string) Option {
+	return func(v *Version) {
+		v.sunset = at
+		v.link = link
+	}
+}
+
+// Version 注册一个新版本，继承上一个版本的路由和中间件
+func (a *API) Version(name string, opts ...Option) *Version {
+	v := &Version{Name: name, routes: make(map[routeKey]*route)}
+	if len(a.versions) > 0 {
+		v.parent = a.versions[len(a.versions)-1]
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	a.versions = append(a.versions, v)
+	return v
+}
+
+type routeKey struct {
+	method, path string
+}
+
+type route struct {
+	routeKey
+	handlers []gin.HandlerFunc
+	// removed 在这个版本中删除了父版本的接口
+	removed bool
+}
+
+// Version 一个版本的路由
+type Version struct {
+	Name string
+
+	parent     *Version
+	deprecated time.Time
+	sunset     time.Time
+	link       string
+	middleware []gin.HandlerFunc
+	routes     map[routeKey]*route
+	order      []routeKey
+}
+
+// Use 添加这个版本的中间件，后续版本也会继承
+func (v *Version) Use(middleware ...gin.HandlerFunc) *Version {
+	v.middleware = append(v.middleware, middleware...)
+	return v
+}
+
+// Handle 注册或覆盖一个接口
+func (v *Version) Handle(method, path string, handlers ...gin.HandlerFunc) *Version {
+	k := routeKey{method: strings.ToUpper(method), path: path}
+	if _, ok := v.routes[k]; !ok {
+		v.order = append(v.order, k)
+	}
+	v.routes[k] = &route{routeKey: k, handlers: handlers}
+	return v
+}
+
+// Remove 在这个版本中删除从父版本继承的接口
+func (v *Version) Remove(method, path string) *Version {
+	k := routeKey{method: strings.ToUpper(method), path: path}
+	if _, ok := v.routes[k]; !ok {
+		v.order = append(v.order, k)
+	}
+	v.routes[k] = &route{routeKey: k, removed: true}
+	return v
+}
+
+// GET 注册 GET 接口
+func (v *Version) GET(path string, handlers ...gin.HandlerFunc) *Version {
+	return v.Handle(http.MethodGet, path, handlers...)
+}
+
+// POST 注册 POST 接口
+func (v *Version) POST(path string, handlers ...gin.HandlerFunc) *Version {
+	return v.Handle(http.MethodPost, path, handlers...)
+}
+
+// PUT 注册 PUT 接口
+func (v *Version) PUT(path string, handlers ...gin.HandlerFunc) *Version {
+	return v.Handle(http.MethodPut, path, handlers...)
+}
+
+// PATCH 注册 PATCH 接口
+func (v *Version) PATCH(path string, handlers ...gin.HandlerFunc) *Version {
+	return v.Handle(http.MethodPatch, path, handlers...)
+}
+
+// DELETE 注册 DELETE 接口
+func (v *Version) DELETE(path string, handlers ...gin.HandlerFunc) *Version {
+	return v.Handle(http.MethodDelete, path, handlers...)
+}
+
+// resolve 合并父版本之后的路由，父版本的顺序在前
+func (v *Version) resolve() ([]gin.HandlerFunc, []*route) {
+	var middleware []gin.HandlerFunc
+	var routes []*route
+	if v.parent != nil {
+		middleware, routes = v.parent.resolve()
+	}
+	middleware = append(middleware[:len(middleware):len(middleware)], v.middleware...)
+	index := make(map[routeKey]int, len(routes))
+	for i, r := range routes {
+		index[r.routeKey] = i
+	}
+	for _, k := range v.order {
+		r := v.routes[k]
+		if i, ok := index[k]; ok {
+			routes[i] = r
+		} else {
+			index[k] = len(routes)
+			routes = append(routes, r)
+		}
+	}
+	live := routes[:0:0]
+	for _, r := range routes {
+		if !r.removed {
+			live = append(live, r)
+		}
+	}
+	return middleware, live
+}
+
+// headers 设置 API-Version 以及废弃相关的响应头
+func (v *Version) headers(c *gin.Context) {
+	h := c.Writer.Header()
+	h.Set("API-Version", v.Name)
+	if !v.deprecated.IsZero() {
+		// RFC 9745，值为 @ 加上 Unix 时间戳
+		h.Set("Deprecation", "@"+strconv.FormatInt(v.deprecated.Unix(), 10))
+	}
+	if !v.sunset.IsZero() {
+		// RFC 8594
+		h.Set("Sunset", v.sunset.UTC().Format(http.TimeFormat))
+	}
+	if v.link != "" {
+		h.Add("Link", "<"+v.link+`>; rel="deprecation"`)
+	}
+}
+
+// Mount 把每个版本挂载到 /{版本名} 下，例如 /v1/goods、/v2/goods
+func (a *API) Mount(g *gin.RouterGroup) {
+	for _, v := range a.versions {
+		middleware, routes := v.resolve()
+		vg := g.Group("/"+v.Name, v.headers)
+		vg.Use(middleware...)
+		for _, r := range routes {
+			vg.Handle(r.method, r.path, r.handlers...)
+		}
+	}
+}
+
+// MountNegotiated 把接口挂载到没有版本前缀的路径下，根据请求头选择版本：
+//
+//	Accept: application/vnd.{Vendor}.v2+json
+//	Accept: application/json; version=2
+//	X-API-Version: v2
+//
+// 都没有时使用 Default，指定了不存在的版本返回 406
+// 每个版本的中间件和处理函数注册在单独的 gin.Engine 上，选中版本后交给它处理，
+// 中间件中的 c.Next() 和在 g 上注册时一样嵌套执行；g 上的中间件设置的值在版本的处理函数中可以取到，反之亦然
+func (a *API) MountNegotiated(g *gin.RouterGroup) {
+	engines := make(map[string]*gin.Engine)
+	versions := make(map[routeKey]map[string]*Version)
+	var order []routeKey
+	for _, v := range a.versions {
+		middleware, routes := v.resolve()
+		e := gin.New()
+		vg := e.Group(g.BasePath(), bridge)
+		vg.Use(middleware...)
+		for _, r := range routes {
+			if _, ok := versions[r.routeKey]; !ok {
+				versions[r.routeKey] = make(map[string]*Version)
+				order = append(order, r.routeKey)
+			}
+			versions[r.routeKey][v.Name] = v
+			vg.Handle(r.method, r.path, r.handlers...)
+		}
+		engines[v.Name] = e
+	}
+	for _, k := range order {
+		k, vs := k, versions[k]
+		g.Handle(k.method, k.path, func(c *gin.Context) {
+			c.Writer.Header().Add("Vary", "Accept")
+			c.Writer.Header().Add("Vary", "X-API-Version")
+			name := a.requested(c)
+			if name == "" {
+				name = a.defaultVersion()
+			}
+			v, ok := vs[name]
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{
+					"error": "api version " + name + " is not available for " + k.method + " " + k.path,
+				})
+				return
+			}
+			v.headers(c)
+			req := c.Request.WithContext(context.WithValue(c.Request.Context(), outerKey{}, c))
+			engines[name].ServeHTTP(c.Writer, req)
+		})
+	}
+}
+
+type outerKey struct{}
+
+// bridge 是版本 Engine 上的第一个处理函数，在外层和版本的 gin.Context 之间同步 Keys、Errors 和 Abort 状态
+func bridge(vc *gin.Context) {
+	c := vc.Request.Context().Value(outerKey{}).(*gin.Context)
+	for k, v := range c.Keys {
+		vc.Set(k, v)
+	}
+	vc.Next()
+	for k, v := range vc.Keys {
+		c.Set(k, v)
+	}
+	c.Errors = append(c.Errors, vc.Errors...)
+	if vc.IsAborted() {
+		c.Abort()
+	}
+}
+
+func (a *API) defaultVersion() string {
+	if a.Default != "" {
+		return a.Default
+	}
+	if len(a.versions) == 0 {
+		return ""
+	}
+	return a.versions[len(a.versions)-1].Name
+}
+
+var (
+	vendorPattern = regexp.MustCompile(`^application/vnd\.([^.+;]+)\.(v[0-9]+)(\+[a-z]+)?$`)
+	paramPattern  = regexp.MustCompile(`^\s*version\s*=\s*"?v?([0-9]+)"?\s*$`)
+)
+
+// requested 从请求头中取出客户端要求的版本，没有指定时返回空字符串
+func (a *API) requested(c *gin.Context) string {
+	if v := strings.TrimSpace(c.GetHeader("X-API-Version")); v != "" {
+		if !strings.HasPrefix(v, "v") {
+			v = "v" + v
+		}
+		return v
+	}
+	for _, item := range strings.Split(c.GetHeader("Accept"), ",") {
+		params := strings.Split(item, ";")
+		mime := strings.ToLower(strings.TrimSpace(params[0]))
+		if m := vendorPattern.FindStringSubmatch(mime); m != nil && m[1] == strings.ToLower(a.Vendor) {
+			return m[2]
+		}
+		for _, p := range params[1:] {
+			if m := paramPattern.FindStringSubmatch(strings.ToLower(p)); m != nil {
+				return "v" + m[1]
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package version
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func text(s string) gin.HandlerFunc {
+	return func(c *gin.Context) { c.String(http.StatusOK, s) }
+}
+
+func newRouter() *gin.Engine {
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	api := New("shop")
+	api.Default = "v1"
+	v1 := api.Version("v1", Deprecated(time.Unix(1700000000, 0)), Sunset(sunset, "https://example.com/migrate"))
+	v1.GET("/goods", text("goods v1"))
+	v1.GET("/checkout", text("checkout v1"))
+	v1.GET("/legacy", text("legacy"))
+	v2 := api.Version("v2")
+	v2.GET("/goods", text("goods v2"))
+	v2.Remove(http.MethodGet, "/legacy")
+
+	r := gin.New()
+	api.Mount(&r.RouterGroup)
+	api.MountNegotiated(r.Group("/api"))
+	return r
+}
+
+func TestMount(t *testing.T) {
+	r := newRouter()
+	cases := []struct {
+		path, body string
+		status     int
+	}{
+		{"/v1/goods", "goods v1", http.StatusOK},
+		{"/v2/goods", "goods v2", http.StatusOK},
+		// v2 没有覆盖的接口沿用 v1
+		{"/v2/checkout", "checkout v1", http.StatusOK},
+		{"/v1/legacy", "legacy", http.StatusOK},
+		{"/v2/legacy", "404 page not found", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if w.Code != tc.status || w.Body.String() != tc.body {
+			t.Errorf("GET %s = %d %q, want %d %q", tc.path, w.Code, w.Body.String(), tc.status, tc.body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/goods", nil))
+	h := w.Header()
+	if h.Get("API-Version") != "v1" || h.Get("Deprecation") != "@1700000000" || h.Get("Sunset") != "Fri, 01 Jan 2027 00:00:00 GMT" {
+		t.Fatalf("unexpected headers: %v", h)
+	}
+	if h.Get("Link") != `<https://example.com/migrate>; rel="deprecation"` {
+		t.Fatalf("Link = %q", h.Get("Link"))
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/goods", nil))
+	if w.Header().Get("Deprecation") != "" || w.Header().Get("API-Version") != "v2" {
+		t.Fatalf("unexpected v2 headers: %v", w.Header())
+	}
+}
+
+func TestMountNegotiated(t *testing.T) {
+	r := newRouter()
+	cases := []struct {
+		path, header, value, body string
+		status                    int
+	}{
+		{"/api/goods", "", "", "goods v1", http.StatusOK},
+		{"/api/goods", "Accept", "application/vnd.shop.v2+json", "goods v2", http.StatusOK},
+		{"/api/goods", "Accept", "text/html, application/json; version=2", "goods v2", http.StatusOK},
+		{"/api/checkout", "X-API-Version", "2", "checkout v1", http.StatusOK},
+		{"/api/legacy", "X-API-Version", "v2", "", http.StatusNotAcceptable},
+		{"/api/goods", "Accept", "application/vnd.shop.v9+json", "", http.StatusNotAcceptable},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		r.ServeHTTP(w, req)
+		if w.Code != tc.status || (tc.body != "" && w.Body.String() != tc.body) {
+			t.Errorf("GET %s %s: %s = %d %q", tc.path, tc.header, tc.value, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestMountNegotiatedMiddleware(t *testing.T) {
+	var order []string
+	api := New("shop")
+	v1 := api.Version("v1")
+	// 中间件的 c.Next() 之后的代码要在处理函数之后执行
+	v1.Use(func(c *gin.Context) {
+		order = append(order, "before")
+		c.Next()
+		order = append(order, "after "+strconv.Itoa(c.Writer.Status()))
+	}, gin.Recovery())
+	v1.GET("/goods/:id", func(c *gin.Context) {
+		order = append(order, "handler "+c.Param("id")+" "+c.GetString("user"))
+		c.Set("handled", true)
+		c.String(http.StatusCreated, "goods")
+	})
+	v1.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	var handled bool
+	r := gin.New()
+	g := r.Group("/api", func(c *gin.Context) {
+		c.Set("user", "jason")
+		c.Next()
+		handled = c.GetBool("handled")
+	})
+	api.MountNegotiated(g)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/goods/1", nil))
+	want := []string{"before", "handler 1 jason", "after 201"}
+	if w.Code != http.StatusCreated || !reflect.DeepEqual(order, want) || !handled {
+		t.Fatalf("code = %d, order = %v, handled = %v", w.Code, order, handled)
+	}
+
+	// 版本中间件里的 Recovery 能接住处理函数的 panic
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/panic", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("panic: code = %d", w.Code)
+	}
+}
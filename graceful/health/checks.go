@@ -0,0 +1,29 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// MySQL 检查数据库连接，sqlx.DB 可以传 db.DB
+func MySQL(db *sql.DB) Check {
+	return db.PingContext
+}
+
+// Redis 检查 Redis 连接，单机、哨兵和集群客户端都可以
+func Redis(c redis.UniversalClient) Check {
+	return func(ctx context.Context) error {
+		return c.Ping(ctx).Err()
+	}
+}
+
+// Mongo 检查 MongoDB 主节点是否可用
+func Mongo(c *mongo.Client) Check {
+	return func(ctx context.Context) error {
+		return c.Ping(ctx, readpref.Primary())
+	}
+}
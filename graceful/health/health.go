@@ -0,0 +1,221 @@
+// Package health 提供 /healthz（存活）和 /readyz（就绪）探针
+//
+// 关闭服务时先让 /readyz 返回 503，等待 PreStopDelay 让负载均衡摘掉这个实例，再关闭 HTTP 服务，
+// 避免 Shutdown 期间还有新流量打进来。
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check 健康检查，返回 nil 表示正常
+type Check func(ctx context.Context) error
+
+// Result 单个检查的结果
+type Result struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Report 探针的响应
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+const (
+	// StatusOK 正常
+	StatusOK = "ok"
+	// StatusFail 有检查失败
+	StatusFail = "fail"
+	// StatusShuttingDown 正在关闭，不再接收新流量
+	StatusShuttingDown = "shutting_down"
+)
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker 管理健康检查和关闭状态
+type Checker struct {
+	// Timeout 单个检查的超时，为 0 时使用 2 秒
+	Timeout time.Duration
+	// PreStopDelay 开始关闭后 /readyz 返回 503 的时间，之后才真正关闭服务，
+	// 需要大于负载均衡健康检查的间隔乘以失败阈值
+	PreStopDelay time.Duration
+
+	mu       sync.RWMutex
+	ready    []namedCheck
+	live     []namedCheck
+	stopping int32
+}
+
+// New 创建 Checker
+func New() *Checker {
+	return &Checker{}
+}
+
+// AddReadiness 添加就绪检查，失败时 /readyz 返回 503，比如数据库、缓存连不上
+func (h *Checker) AddReadiness(name string, check Check) *Checker {
+	h.mu.Lock()
+	h.ready = append(h.ready, namedCheck{name: name, check: check})
+	h.mu.Unlock()
+	return h
+}
+
+// AddLiveness 添加存活检查，失败时 /healthz 返回 503，编排系统会重启进程
+// 只放进程自身的问题（比如死锁检测），不要放外部依赖，否则依赖故障会导致所有实例被反复重启
+func (h *Checker) AddLiveness(name string, check Check) *Checker {
+	h.mu.Lock()
+	h.live = append(h.live, namedCheck{name: name, check: check})
+	h.mu.Unlock()
+	return h
+}
+
+// ShuttingDown 是否已经开始关闭
+func (h *Checker) ShuttingDown() bool {
+	return atomic.LoadInt32(&h.stopping) == 1
+}
+
+// Shutdown 让 /readyz 开始返回 503，并等待 PreStopDelay 或 ctx 结束
+// 在 http.Server.Shutdown 之前调用
+func (h *Checker) Shutdown(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&h.stopping, 0, 1) || h.PreStopDelay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(h.PreStopDelay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Readiness 运行所有就绪检查，正在关闭时直接返回失败
+func (h *Checker) Readiness(ctx context.Context) Report {
+	if h.ShuttingDown() {
+		return Report{Status: StatusShuttingDown}
+	}
+	h.mu.RLock()
+	checks := h.ready
+	h.mu.RUnlock()
+	return h.run(ctx, checks)
+}
+
+// Liveness 运行所有存活检查，关闭期间仍然返回正常，避免进程在处理剩余请求时被杀掉
+func (h *Checker) Liveness(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := h.live
+	h.mu.RUnlock()
+	return h.run(ctx, checks)
+}
+
+// ReadyHandler /readyz 的处理函数，?verbose=0 时不返回每个检查的结果
+func (h *Checker) ReadyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, r, h.Readiness(r.Context()))
+	}
+}
+
+// LiveHandler /healthz 的处理函数
+func (h *Checker) LiveHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, r, h.Liveness(r.Context()))
+	}
+}
+
+// Register 在 mux 上注册 /healthz 和 /readyz
+func (h *Checker) Register(mux *http.ServeMux) {
+	mux.Handle("/healthz", h.LiveHandler())
+	mux.Handle("/readyz", h.ReadyHandler())
+}
+
+// run 并发执行检查，每个检查单独计时
+func (h *Checker) run(ctx context.Context, checks []namedCheck) Report {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			err := runCheck(ctx, c.check)
+			res := Result{Name: c.name, Status: StatusOK, Latency: time.Since(start).String()}
+			if err != nil {
+				res.Status, res.Error = StatusFail, err.Error()
+			}
+			results[i] = res
+		}(i, c)
+	}
+	wg.Wait()
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, res := range results {
+		if res.Status != StatusOK {
+			report.Status = StatusFail
+			break
+		}
+	}
+	return report
+}
+
+// runCheck 检查不理会 ctx 时也按超时返回
+func runCheck(ctx context.Context, check Check) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func writeReport(w http.ResponseWriter, r *http.Request, report Report) {
+	code := http.StatusOK
+	if report.Status != StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	if r.URL.Query().Get("verbose") == "0" {
+		report.Checks = nil
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
+
+// Name 实现 lifecycle.Component，Checker 要最后添加，这样关闭时最先把 /readyz 置为失败
+func (h *Checker) Name() string {
+	return "health"
+}
+
+// Start 实现 lifecycle.Component
+func (h *Checker) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop 实现 lifecycle.Component，关闭超时需要大于 PreStopDelay
+func (h *Checker) Stop(ctx context.Context) error {
+	return h.Shutdown(ctx)
+}
@@ -0,0 +1,79 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func get(h http.Handler, target string) (int, Report) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	var report Report
+	json.Unmarshal(w.Body.Bytes(), &report)
+	return w.Code, report
+}
+
+func TestReadinessChecks(t *testing.T) {
+	h := New()
+	h.Timeout = 20 * time.Millisecond
+	h.AddReadiness("mysql", func(ctx context.Context) error { return nil })
+	h.AddReadiness("redis", func(ctx context.Context) error { return errors.New("connection refused") })
+	// 不理会 ctx 的检查也要按超时返回
+	h.AddReadiness("mongo", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	start := time.Now()
+	code, report := get(h.ReadyHandler(), "/readyz")
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("slow check was not timed out")
+	}
+	if code != http.StatusServiceUnavailable || report.Status != StatusFail || len(report.Checks) != 3 {
+		t.Fatalf("code = %d, report = %+v", code, report)
+	}
+	want := map[string]string{"mongo": StatusFail, "mysql": StatusOK, "redis": StatusFail}
+	for _, res := range report.Checks {
+		if want[res.Name] != res.Status || res.Latency == "" {
+			t.Fatalf("unexpected result %+v", res)
+		}
+	}
+
+	if code, _ := get(h.LiveHandler(), "/healthz"); code != http.StatusOK {
+		t.Fatalf("liveness code = %d", code)
+	}
+}
+
+func TestShutdownFlipsReadiness(t *testing.T) {
+	h := New()
+	h.PreStopDelay = 50 * time.Millisecond
+	if code, _ := get(h.ReadyHandler(), "/readyz"); code != http.StatusOK {
+		t.Fatalf("ready code = %d", code)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.Stop(context.Background())
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	code, report := get(h.ReadyHandler(), "/readyz")
+	if code != http.StatusServiceUnavailable || report.Status != StatusShuttingDown {
+		t.Fatalf("code = %d, report = %+v", code, report)
+	}
+	// 存活探针在关闭期间保持正常
+	if code, _ := get(h.LiveHandler(), "/healthz"); code != http.StatusOK {
+		t.Fatalf("liveness code = %d", code)
+	}
+	select {
+	case <-done:
+		t.Fatal("Stop returned before PreStopDelay")
+	default:
+	}
+	<-done
+}
@@ -0,0 +1,444 @@
+package lifecycle
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPServer 把 http.Server 包装成组件
+// 关闭时停止接受新连接，等待 GracePeriod 后取消所有请求的 ctx，在 Stop 的 ctx 超时前等待请求处理完，
+// 超时后强制关闭剩余的连接，包括 WebSocket 等被 Hijack 的连接
+type HTTPServer struct {
+	// inFlight 放在第一个，32 位平台上 atomic 操作要求 8 字节对齐
+	inFlight int64
+
+	// GracePeriod 关闭开始后让请求自然结束的时间，之后取消 base context，为 0 时立即取消
+	GracePeriod time.Duration
+
+	name   string
+	srv    *http.Server
+	ln     net.Listener
+	errc   chan error
+	base   context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	hijacked map[net.Conn]struct{}
+}
+
+// NewHTTPServer 创建 HTTP 组件，srv.TLSConfig 中配置了证书时使用 HTTPS
+func NewHTTPServer(name string, srv *http.Server) *HTTPServer {
+	s := &HTTPServer{
+		name:     name,
+		srv:      srv,
+		errc:     make(chan error, 1),
+		hijacked: make(map[net.Conn]struct{}),
+	}
+	s.base, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// Name 实现 Component
+func (s *HTTPServer) Name() string {
+	return s.name
+}
+
+// Addr 实际监听的地址，端口为 0 时可以通过它拿到分配的端口
+func (s *HTTPServer) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// InFlight 正在处理的请求数，不包括已经 Hijack 的连接
+func (s *HTTPServer) InFlight() int64 {
+	return atomic.LoadInt64(&s.inFlight)
+}
+
+// Hijacked 还没有关闭的 Hijack 连接数
+func (s *HTTPServer) Hijacked() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.hijacked)
+}
+
+// Start 实现 Component，监听成功后在后台提供服务
+func (s *HTTPServer) Start(ctx context.Context) error {
+	addr := s.srv.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := Listen(ctx, s.name, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+
+	// 所有请求的 ctx 都从 base 派生，关闭时取消 base，处理函数和下游调用都能感知到
+	baseContext := s.srv.BaseContext
+	s.srv.BaseContext = func(l net.Listener) context.Context {
+		if baseContext != nil {
+			return mergeCancel(baseContext(l), s.base)
+		}
+		return s.base
+	}
+	handler := s.srv.Handler
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	s.srv.Handler = s.track(handler)
+
+	go func() {
+		var err error
+		if s.srv.TLSConfig != nil {
+			err = s.srv.ServeTLS(ln, "", "")
+		} else {
+			err = s.srv.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			s.errc <- err
+		}
+		close(s.errc)
+	}()
+	return nil
+}
+
+// Stop 实现 Component，ctx 的截止时间就是强制关闭的时间
+func (s *HTTPServer) Stop(ctx context.Context) error {
+	if s.GracePeriod > 0 {
+		t := time.AfterFunc(s.GracePeriod, s.cancel)
+		defer t.Stop()
+	} else {
+		s.cancel()
+	}
+	// Shutdown 不管 Hijack 的连接，等它们自己关闭
+	err := s.srv.Shutdown(ctx)
+	if err == nil {
+		err = s.waitHijacked(ctx)
+	}
+	if err == nil {
+		return nil
+	}
+	s.cancel()
+	inFlight, hijacked := s.InFlight(), s.Hijacked()
+	s.srv.Close()
+	// Close 会回调 onClose 修改 hijacked，不能持有锁关闭
+	s.mu.Lock()
+	conns := make([]net.Conn, 0, len(s.hijacked))
+	for conn := range s.hijacked {
+		conns = append(conns, conn)
+	}
+	s.mu.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+	return fmt.Errorf("%d requests and %d hijacked connections still active, closed forcibly: %w", inFlight, hijacked, err)
+}
+
+// Err 实现 Failer
+func (s *HTTPServer) Err() <-chan error {
+	return s.errc
+}
+
+func (s *HTTPServer) waitHijacked(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for s.Hijacked() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// track 统计正在处理的请求，记录 Hijack 出去的连接
+func (s *HTTPServer) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&s.inFlight, 1)
+		defer atomic.AddInt64(&s.inFlight, -1)
+		next.ServeHTTP(&trackedWriter{ResponseWriter: w, s: s, r: r}, r)
+	})
+}
+
+func (s *HTTPServer) addHijacked(conn net.Conn) net.Conn {
+	tc := &trackedConn{Conn: conn}
+	tc.onClose = func() {
+		s.mu.Lock()
+		delete(s.hijacked, tc)
+		s.mu.Unlock()
+	}
+	s.mu.Lock()
+	s.hijacked[tc] = struct{}{}
+	s.mu.Unlock()
+	return tc
+}
+
+// trackedWriter 保留 Flusher、Hijacker、Pusher、CloseNotifier，SSE 和 WebSocket 可以照常使用
+type trackedWriter struct {
+	http.ResponseWriter
+	s *HTTPServer
+	r *http.Request
+}
+
+func (w *trackedWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *trackedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("lifecycle: response does not support hijacking")
+	}
+	conn, rw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return w.s.addHijacked(conn), rw, nil
+}
+
+// CloseNotify gin 的 c.Stream 依赖它，客户端断开或者关闭时取消请求的 ctx 都会通知
+func (w *trackedWriter) CloseNotify() <-chan bool {
+	var gone <-chan bool
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		gone = cn.CloseNotify()
+	}
+	ch := make(chan bool, 1)
+	go func() {
+		// 请求结束后 ctx 一定会被取消，goroutine 不会泄漏
+		select {
+		case <-gone:
+		case <-w.r.Context().Done():
+		}
+		ch <- true
+	}()
+	return ch
+}
+
+func (w *trackedWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap 供 http.ResponseController 使用
+func (w *trackedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// trackedConn 关闭时从 HTTPServer 中移除
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.onClose)
+	return c.Conn.Close()
+}
+
+// mergeCancel 返回保留 parent 的值、在 parent 或 cancel 任意一个结束时结束的 ctx
+func mergeCancel(parent, cancel context.Context) context.Context {
+	ctx, stop := context.WithCancel(parent)
+	go func() {
+		select {
+		case <-cancel.Done():
+			stop()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx
+}
+
+// TCPServer 通用的 TCP 服务组件，每个连接在单独的 goroutine 中交给 handler 处理
+// 关闭时先停止接受新连接并取消 handler 的 ctx，等待 handler 返回，超时后强制关闭剩余连接
+type TCPServer struct {
+	name    string
+	addr    string
+	handler func(ctx context.Context, conn net.Conn)
+
+	ln     net.Listener
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	errc   chan error
+
+	mu      sync.Mutex
+	closing bool
+	conns   map[net.Conn]struct{}
+}
+
+// NewTCPServer 创建 TCP 组件，handler 返回后连接会被关闭
+func NewTCPServer(name, addr string, handler func(ctx context.Context, conn net.Conn)) *TCPServer {
+	return &TCPServer{
+		name:    name,
+		addr:    addr,
+		handler: handler,
+		errc:    make(chan error, 1),
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// Name 实现 Component
+func (s *TCPServer) Name() string {
+	return s.name
+}
+
+// Addr 实际监听的地址
+func (s *TCPServer) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// Start 实现 Component
+func (s *TCPServer) Start(ctx context.Context) error {
+	ln, err := Listen(ctx, s.name, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.serve()
+	return nil
+}
+
+func (s *TCPServer) serve() {
+	defer close(s.errc)
+	var delay time.Duration
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closing := s.closing
+			s.mu.Unlock()
+			if closing {
+				return
+			}
+			// 和 http.Server 一样，临时错误时退避重试
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Temporary() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+				time.Sleep(delay)
+				continue
+			}
+			s.errc <- err
+			return
+		}
+		delay = 0
+
+		s.mu.Lock()
+		if s.closing {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			s.handler(s.ctx, conn)
+		}()
+	}
+}
+
+// Stop 实现 Component
+func (s *TCPServer) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+	s.ln.Close()
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Err 实现 Failer
+func (s *TCPServer) Err() <-chan error {
+	return s.errc
+}
+
+// Worker 后台任务组件，run 需要在 ctx 取消后返回
+type Worker struct {
+	name   string
+	run    func(ctx context.Context) error
+	cancel context.CancelFunc
+	done   chan struct{}
+	errc   chan error
+}
+
+// NewWorker 创建后台任务组件，run 在关闭前返回 nil 表示任务正常结束，返回错误会触发 App 关闭
+func NewWorker(name string, run func(ctx context.Context) error) *Worker {
+	return &Worker{name: name, run: run, done: make(chan struct{}), errc: make(chan error, 1)}
+}
+
+// Name 实现 Component
+func (w *Worker) Name() string {
+	return w.name
+}
+
+// Start 实现 Component
+func (w *Worker) Start(ctx context.Context) error {
+	// 不使用 Run 的 ctx，否则 ctx 取消时所有任务会同时退出，无法按顺序关闭
+	runCtx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	go func() {
+		defer close(w.done)
+		defer close(w.errc)
+		if err := w.run(runCtx); err != nil && runCtx.Err() == nil {
+			w.errc <- err
+		}
+	}()
+	return nil
+}
+
+// Stop 实现 Component
+func (w *Worker) Stop(ctx context.Context) error {
+	w.cancel()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Err 实现 Failer
+func (w *Worker) Err() <-chan error {
+	return w.errc
+}
@@ -0,0 +1,279 @@
+// Package lifecycle 管理一组需要一起启动和关闭的组件，比如多个 HTTP 服务、TCP 服务和后台任务
+//
+// 组件按添加顺序启动，收到 SIGINT/SIGTERM、ctx 被取消或某个组件运行出错时按相反顺序关闭，
+// 每个组件有自己的关闭超时，Run 返回的 Errors 中记录了哪个组件在哪一步出错。
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Component 由 App 管理的组件
+type Component interface {
+	Name() string
+	// Start 在组件可以提供服务后返回，比如端口已经监听成功，不能阻塞到组件退出
+	Start(ctx context.Context) error
+	// Stop 关闭组件，ctx 超时后应该尽快返回
+	Stop(ctx context.Context) error
+}
+
+// Failer 可选接口，组件运行期间出错时往 Err 返回的 channel 发送错误，App 收到后关闭所有组件
+// 组件退出后需要关闭这个 channel
+type Failer interface {
+	Err() <-chan error
+}
+
+// ComponentError 某个组件在 Op（start、run、stop）时出的错
+type ComponentError struct {
+	Name string
+	Op   string
+	Err  error
+}
+
+func (e *ComponentError) Error() string {
+	return fmt.Sprintf("lifecycle: %s %q: %v", e.Op, e.Name, e.Err)
+}
+
+// Unwrap 返回原始错误
+func (e *ComponentError) Unwrap() error {
+	return e.Err
+}
+
+// Errors Run 过程中所有组件的错误，按发生顺序排列
+type Errors []*ComponentError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ListenFunc 按组件名创建监听 socket
+type ListenFunc func(name, network, addr string) (net.Listener, error)
+
+type listenKey struct{}
+
+// Listen 组件在 Start 中用它创建监听 socket，App 设置了 Listen 时使用 App 的方式，否则直接 net.Listen
+func Listen(ctx context.Context, name, network, addr string) (net.Listener, error) {
+	if fn, ok := ctx.Value(listenKey{}).(ListenFunc); ok {
+		return fn(name, network, addr)
+	}
+	return net.Listen(network, addr)
+}
+
+// Option 添加组件时的选项
+type Option func(*entry)
+
+// WithStopTimeout 单独设置组件的关闭超时，覆盖 App.StopTimeout
+func WithStopTimeout(d time.Duration) Option {
+	return func(e *entry) {
+		e.stopTimeout = d
+	}
+}
+
+type entry struct {
+	c           Component
+	stopTimeout time.Duration
+}
+
+// App 组件的生命周期管理
+type App struct {
+	// StopTimeout 每个组件默认的关闭超时，为 0 时使用 5 秒
+	StopTimeout time.Duration
+	// Signals 触发关闭的信号，为空时使用 SIGINT 和 SIGTERM
+	Signals []os.Signal
+	// Logger 为 nil 时使用 log 包默认的 Logger
+	Logger *log.Logger
+	// Listen 组件创建监听 socket 的方式，为 nil 时直接使用 net.Listen
+	// 热重启时换成 restart.Restarter.Listen，从父进程继承 socket
+	Listen ListenFunc
+	// Upgrade 收到 UpgradeSignals 时调用，返回 nil 表示新进程已经接手，App 随后关闭所有组件
+	// 返回错误时记录日志并继续运行，为 nil 时不处理这些信号
+	Upgrade func() error
+	// UpgradeSignals 触发 Upgrade 的信号，为空时使用 SIGHUP
+	UpgradeSignals []os.Signal
+
+	entries []entry
+	ready   chan struct{}
+}
+
+// New 创建 App
+func New() *App {
+	return &App{ready: make(chan struct{})}
+}
+
+// Ready 所有组件启动成功后关闭
+func (a *App) Ready() <-chan struct{} {
+	return a.ready
+}
+
+// Add 添加组件，组件按添加顺序启动，按相反顺序关闭
+func (a *App) Add(c Component, opts ...Option) *App {
+	e := entry{c: c}
+	for _, opt := range opts {
+		opt(&e)
+	}
+	a.entries = append(a.entries, e)
+	return a
+}
+
+// Run 启动所有组件并阻塞，直到收到信号、ctx 被取消或者某个组件出错，然后关闭所有已启动的组件
+// 正常关闭时返回 nil，否则返回 Errors
+// 关闭过程中再次收到信号会取消剩余组件的关闭等待，尽快退出
+func (a *App) Run(ctx context.Context) error {
+	signals := a.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	quit := make(chan os.Signal, 2)
+	signal.Notify(quit, signals...)
+	defer signal.Stop(quit)
+	upgrade := make(chan os.Signal, 1)
+	if a.Upgrade != nil {
+		upgradeSignals := a.UpgradeSignals
+		if len(upgradeSignals) == 0 {
+			upgradeSignals = []os.Signal{syscall.SIGHUP}
+		}
+		signal.Notify(upgrade, upgradeSignals...)
+		defer signal.Stop(upgrade)
+	}
+	if a.Listen != nil {
+		ctx = context.WithValue(ctx, listenKey{}, a.Listen)
+	}
+
+	var errs Errors
+	failed := make(chan *ComponentError, len(a.entries))
+	started := 0
+	for _, e := range a.entries {
+		a.logf("starting %s", e.c.Name())
+		if err := e.c.Start(ctx); err != nil {
+			errs = append(errs, &ComponentError{Name: e.c.Name(), Op: "start", Err: err})
+			break
+		}
+		started++
+		if f, ok := e.c.(Failer); ok {
+			go watch(e.c.Name(), f, failed)
+		}
+	}
+
+	if len(errs) == 0 {
+		close(a.ready)
+	wait:
+		for {
+			select {
+			case sig := <-quit:
+				a.logf("received %s, shutting down", sig)
+				break wait
+			case sig := <-upgrade:
+				a.logf("received %s, upgrading", sig)
+				if err := a.Upgrade(); err != nil {
+					a.logf("upgrade failed, keep running: %v", err)
+					continue
+				}
+				a.logf("upgrade done, shutting down")
+				break wait
+			case <-ctx.Done():
+				a.logf("context done, shutting down")
+				break wait
+			case err := <-failed:
+				a.logf("%v, shutting down", err)
+				errs = append(errs, err)
+				break wait
+			}
+		}
+	}
+
+	// 关闭时不能继承已经取消的 ctx，第二次收到信号时再取消
+	stopCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case sig := <-quit:
+			a.logf("received %s again, aborting shutdown", sig)
+			cancel()
+		case <-stopCtx.Done():
+		}
+	}()
+	for i := started - 1; i >= 0; i-- {
+		if err := a.stop(stopCtx, a.entries[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// stop 在超时时间内关闭组件，组件的 Stop 不理会 ctx 时也不会一直等下去
+func (a *App) stop(ctx context.Context, e entry) *ComponentError {
+	timeout := e.stopTimeout
+	if timeout <= 0 {
+		timeout = a.StopTimeout
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	a.logf("stopping %s", e.c.Name())
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- e.c.Stop(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// 理会 ctx 的组件（例如 HTTPServer）在截止时间到了之后才开始强制关闭，
+		// 再等一小会儿拿到它自己的错误，里面有还剩多少请求之类的信息
+		t := time.NewTimer(forceCloseGrace(timeout))
+		select {
+		case err = <-done:
+		case <-t.C:
+			err = ctx.Err()
+		}
+		t.Stop()
+	}
+	if err != nil {
+		a.logf("stop %s failed after %s: %v", e.c.Name(), time.Since(start).Round(time.Millisecond), err)
+		return &ComponentError{Name: e.c.Name(), Op: "stop", Err: err}
+	}
+	a.logf("stopped %s in %s", e.c.Name(), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// forceCloseGrace 超时之后继续等待 Stop 返回的时间，超时时间的 1/4，最多 1 秒
+func forceCloseGrace(timeout time.Duration) time.Duration {
+	if g := timeout / 4; g < time.Second {
+		return g
+	}
+	return time.Second
+}
+
+func (a *App) logf(format string, v ...interface{}) {
+	if a.Logger != nil {
+		a.Logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// watch 把组件运行期间的第一个错误转发给 App
+func watch(name string, f Failer, failed chan<- *ComponentError) {
+	if err, ok := <-f.Err(); ok && err != nil {
+		failed <- &ComponentError{Name: name, Op: "run", Err: err}
+	}
+}
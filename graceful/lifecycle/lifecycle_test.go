@@ -0,0 +1,329 @@
+package lifecycle
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type callLog struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *callLog) add(s string) {
+	l.mu.Lock()
+	l.calls = append(l.calls, s)
+	l.mu.Unlock()
+}
+
+func (l *callLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.calls...)
+}
+
+type fakeComponent struct {
+	name     string
+	log      *callLog
+	startErr error
+	stopWait time.Duration
+}
+
+func (f *fakeComponent) Name() string { return f.name }
+
+func (f *fakeComponent) Start(ctx context.Context) error {
+	f.log.add("start " + f.name)
+	return f.startErr
+}
+
+func (f *fakeComponent) Stop(ctx context.Context) error {
+	f.log.add("stop " + f.name)
+	// 模拟不理会 ctx 的组件
+	time.Sleep(f.stopWait)
+	return nil
+}
+
+func newApp() *App {
+	app := New()
+	app.Logger = log.New(ioutil.Discard, "", 0)
+	return app
+}
+
+func TestRunOrderAndStopTimeout(t *testing.T) {
+	calls := &callLog{}
+	app := newApp()
+	app.Add(&fakeComponent{name: "a", log: calls})
+	app.Add(&fakeComponent{name: "slow", log: calls, stopWait: time.Second}, WithStopTimeout(20*time.Millisecond))
+	app.Add(&fakeComponent{name: "c", log: calls})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := app.Run(ctx)
+
+	want := []string{"start a", "start slow", "start c", "stop c", "stop slow", "stop a"}
+	if got := calls.get(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("calls = %v, want %v", got, want)
+	}
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("err = %v", err)
+	}
+	if errs[0].Name != "slow" || errs[0].Op != "stop" || !errors.Is(errs[0], context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", errs[0])
+	}
+}
+
+func TestStartFailureStopsStarted(t *testing.T) {
+	calls := &callLog{}
+	boom := errors.New("address in use")
+	app := newApp()
+	app.Add(&fakeComponent{name: "a", log: calls})
+	app.Add(&fakeComponent{name: "b", log: calls, startErr: boom})
+	app.Add(&fakeComponent{name: "c", log: calls})
+
+	err := app.Run(context.Background())
+	want := []string{"start a", "start b", "stop a"}
+	if got := calls.get(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("calls = %v, want %v", got, want)
+	}
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Op != "start" || errs[0].Err != boom {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestWorkerFailureTriggersShutdown(t *testing.T) {
+	boom := errors.New("queue closed")
+	stopped := make(chan struct{})
+	app := newApp()
+	app.Add(NewWorker("consumer", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return nil
+	}))
+	app.Add(NewWorker("producer", func(ctx context.Context) error {
+		return boom
+	}))
+
+	err := app.Run(context.Background())
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Name != "producer" || errs[0].Op != "run" {
+		t.Fatalf("err = %v", err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("consumer was not stopped")
+	}
+}
+
+func TestServersDrainOnShutdown(t *testing.T) {
+	started := make(chan struct{})
+	web := NewHTTPServer("web", &http.Server{
+		Addr: "127.0.0.1:0",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte("done"))
+		}),
+	})
+	tcp := NewTCPServer("tcp", "127.0.0.1:0", func(ctx context.Context, conn net.Conn) {
+		<-ctx.Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	app := newApp()
+	app.Add(tcp).Add(web)
+	result := make(chan error, 1)
+	go func() { result <- app.Run(ctx) }()
+
+	<-app.Ready()
+	addr := web.Addr().String()
+	conn, err := net.Dial("tcp", tcp.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	resp := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + addr)
+		if err != nil {
+			resp <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		b, _ := ioutil.ReadAll(res.Body)
+		resp <- string(b)
+	}()
+	<-started
+	cancel()
+
+	if err := <-result; err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if got := <-resp; got != "done" {
+		t.Fatalf("in-flight request got %q", got)
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Fatal("web server still accepting connections")
+	}
+}
+
+func TestHTTPServerCancelsRequestsAndClosesHijacked(t *testing.T) {
+	canceled := make(chan struct{})
+	inHandler := make(chan struct{}, 2)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		inHandler <- struct{}{}
+		select {
+		case <-r.Context().Done():
+			close(canceled)
+		case <-time.After(5 * time.Second):
+		}
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		inHandler <- struct{}{}
+		// 模拟不理会关闭的 WebSocket 连接，一直读到连接被关掉
+		go ioutil.ReadAll(conn)
+	})
+	web := NewHTTPServer("web", &http.Server{Addr: "127.0.0.1:0", Handler: mux})
+	if err := web.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	addr := web.Addr().String()
+
+	go http.Get("http://" + addr + "/slow")
+	ws, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.Write([]byte("GET /ws HTTP/1.1\r\nHost: x\r\n\r\n"))
+	<-inHandler
+	<-inHandler
+	if web.InFlight() != 1 || web.Hijacked() != 1 {
+		t.Fatalf("in-flight = %d, hijacked = %d", web.InFlight(), web.Hijacked())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err = web.Stop(ctx)
+	select {
+	case <-canceled:
+	default:
+		t.Fatal("request context was not canceled")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Stop = %v, want deadline exceeded", err)
+	}
+	// 硬截止时间到了之后 Hijack 的连接被强制关闭
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := ws.Read(make([]byte, 1)); err == nil {
+		t.Fatal("hijacked connection still open")
+	}
+	if web.Hijacked() != 0 {
+		t.Fatalf("hijacked = %d after stop", web.Hijacked())
+	}
+}
+
+func TestRunReportsForcedClose(t *testing.T) {
+	mux := http.NewServeMux()
+	hijacked := make(chan struct{})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		close(hijacked)
+		go ioutil.ReadAll(conn)
+	})
+	// 先拿到一个空闲端口，App 启动后才能连接
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	web := NewHTTPServer("web", &http.Server{Addr: addr, Handler: mux})
+	app := newApp()
+	app.StopTimeout = 100 * time.Millisecond
+	app.Add(web)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- app.Run(ctx) }()
+	var ws net.Conn
+	for i := 0; ; i++ {
+		if ws, err = net.Dial("tcp", addr); err == nil {
+			break
+		}
+		if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer ws.Close()
+	ws.Write([]byte("GET /ws HTTP/1.1\r\nHost: x\r\n\r\n"))
+	<-hijacked
+	cancel()
+
+	// 错误来自 HTTPServer.Stop 而不是 App 自己的超时，里面有剩余连接的数量
+	var errs Errors
+	if err := <-result; !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("err = %v", err)
+	}
+	if msg := errs[0].Error(); !strings.Contains(msg, "0 requests and 1 hijacked connections still active") {
+		t.Fatalf("unexpected error: %s", msg)
+	}
+}
+
+func TestHTTPServerGinStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/stream", func(c *gin.Context) {
+		// 只靠 CloseNotify 结束，关闭时取消请求的 ctx 后 c.Stream 应该返回
+		c.Stream(func(w io.Writer) bool {
+			time.Sleep(10 * time.Millisecond)
+			c.SSEvent("tick", "ok")
+			return true
+		})
+	})
+	web := NewHTTPServer("web", &http.Server{Addr: "127.0.0.1:0", Handler: r})
+	if err := web.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get("http://" + web.Addr().String() + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != "event:tick\n" {
+		t.Fatalf("first line = %q, %v", line, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := web.Stop(ctx); err != nil {
+		t.Fatalf("Stop = %v", err)
+	}
+}
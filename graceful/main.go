@@ -1,27 +1,62 @@
 package main
 
 import (
+	"bufio"
 	"context"
-	"github.com/fvbock/endless"
+	"database/sql"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	_ "github.com/go-sql-driver/mysql"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
+	"main/health"
+	"main/lifecycle"
+	"main/restart"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"protocal"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
 func graceShutdown() {
+	// /readyz 在关闭开始时返回 503，负载均衡摘掉实例后再 Shutdown，PreStopDelay 要大于探测间隔 × 失败次数
+	checker := health.New()
+	checker.PreStopDelay = 10 * time.Second
+	addDependencyChecks(checker)
+
+	// 所有请求的 ctx 都从 baseCtx 派生，关闭时取消它，正在处理的请求和下游调用都能感知到
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+	var inFlight int64
+
 	router := gin.Default()
+	router.Use(func(c *gin.Context) {
+		atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		c.Next()
+	})
 	router.GET("/", func(c *gin.Context) {
-		time.Sleep(5* time.Second)
-		c.String(http.StatusOK, "gin server 5s")
+		select {
+		case <-time.After(5 * time.Second):
+			c.String(http.StatusOK, "gin server 5s")
+		case <-c.Request.Context().Done():
+			c.String(http.StatusServiceUnavailable, "server shutting down")
+		}
 	})
+	router.GET("/healthz", gin.WrapF(checker.LiveHandler()))
+	router.GET("/readyz", gin.WrapF(checker.ReadyHandler()))
 
 	srv := &http.Server{
 		Addr: ":8080",
 		Handler: router,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
 	}
 
 	go func() {
@@ -39,38 +74,166 @@ func graceShutdown() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM) // 此处不会阻塞
 	<-quit // 阻塞在此，当接收到上述两种信号时才会往下执行
 	log.Println("Shutdown Server ...")
+	// 先让 /readyz 失败，等负载均衡不再转发新请求
+	checker.Shutdown(context.Background())
 	// 创建一个5秒超时的context
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	// 先给正在处理的请求 3 秒自然结束，之后再通知剩下的请求尽快结束
+	grace := time.AfterFunc(3*time.Second, func() {
+		log.Printf("cancel %d in-flight requests", atomic.LoadInt64(&inFlight))
+		cancelBase()
+	})
+	defer grace.Stop()
 	// 5秒内优雅关闭服务（将未处理完的请求处理完再关闭服务），超过5秒就超时退出
+	// Shutdown 不会等待 WebSocket 这类被 Hijack 的连接，需要它们自己关闭，lifecycle.HTTPServer 会在超时后强制关闭
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server Shutdown: ", err)
+		log.Fatalf("Server Shutdown: %v, %d requests still in flight", err, atomic.LoadInt64(&inFlight))
 	}
 	log.Println("Server exiting")
 }
 
+// addDependencyChecks 按环境变量注册依赖的就绪检查，任意一个连不上时 /readyz 返回 503
+func addDependencyChecks(checker *health.Checker) {
+	if dsn := os.Getenv("MYSQL_DSN"); dsn != "" {
+		// sql.Open 不会建立连接，连不上时由检查报告
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		checker.AddReadiness("mysql", health.MySQL(db))
+	}
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		checker.AddReadiness("redis", health.Redis(redis.NewClient(&redis.Options{Addr: addr})))
+	}
+	if uri := os.Getenv("MONGO_URI"); uri != "" {
+		// mongo.Connect 同样不会等待连接成功
+		client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+		if err != nil {
+			log.Fatal(err)
+		}
+		checker.AddReadiness("mongo", health.Mongo(client))
+	}
+}
+
 
 // 优雅重启
-// 我们可以使用 fvbock/endless 来替换默认的 ListenAndServe启动服务来实现
+// kill -1 pid 发送 SIGHUP 信号后，当前进程带着监听的 socket 重新执行自己，
+// 新进程启动完成后通知老进程，老进程处理完已有请求后退出，期间端口一直在监听，客户端不会连接失败
+// 和 fvbock/endless 不同，这里可以同时交接多个 HTTP 服务和 TCP 服务
 func graceRestart() {
 	router := gin.Default()
 	router.GET("/", func(c *gin.Context) {
-		time.Sleep(5*time.Second)
-		c.String(http.StatusOK, "hello jason!")
+		time.Sleep(5 * time.Second)
+		c.String(http.StatusOK, "hello jason! pid %d", os.Getpid())
 	})
-	//默认endless服务器会监听下列信号：
-	// syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2，syscall.SIGINT，syscall.SIGTERM 和 syscall.SIGTSTP
-	// 接收到 SIGHUP 信号将触发 `fork/restart` 实现优雅重启(kill -1 pid会发送SIGHUP信号)
-	// 接收到 syscall.SIGINT或syscall.SIGTERM 信号将触发优雅关机
-	// 接收到 SIGUSR2 信号将触发HammerTime
-	// SIGUSR1 和 SIGTSTP 被用来触发一些用户自定义的hook函数
-	if err := endless.ListenAndServe(":8080", router); err != nil {
-		log.Fatalf("listen: %s\n", err)
+
+	r, err := restart.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	app := lifecycle.New()
+	// 组件通过 r.Listen 创建监听 socket，新进程按组件名拿回父进程的 socket
+	app.Listen = r.Listen
+	// 收到 SIGHUP 时启动新进程，新进程准备好后老进程按正常流程关闭
+	app.Upgrade = r.Upgrade
+	app.Add(lifecycle.NewTCPServer("tcp", "127.0.0.1:30000", processConn), lifecycle.WithStopTimeout(2*time.Second))
+	// 新进程已经接手，老进程不取消请求，让它们在 10 秒内自然结束
+	web := lifecycle.NewHTTPServer("web", &http.Server{Addr: ":8080", Handler: router})
+	web.GracePeriod = 10 * time.Second
+	app.Add(web, lifecycle.WithStopTimeout(10*time.Second))
+	go func() {
+		<-app.Ready()
+		// 由 SIGHUP 启动的新进程在这里通知老进程退出
+		if err := r.Ready(); err != nil {
+			log.Println("notify parent:", err)
+		}
+	}()
+
+	if err := app.Run(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("server %d exiting", os.Getpid())
+}
+
+// 同时管理多个组件：graceShutdown 只能关闭一个写死的服务，lifecycle.App 可以按顺序启动多个 HTTP 服务、
+// TCP 服务和后台任务，收到 SIGINT/SIGTERM 后按相反顺序关闭，每个组件有自己的关闭超时
+func appLifecycle() {
+	router := gin.Default()
+	router.GET("/", func(c *gin.Context) {
+		select {
+		case <-time.After(5 * time.Second):
+			c.String(http.StatusOK, "gin server 5s")
+		case <-c.Request.Context().Done():
+			c.String(http.StatusServiceUnavailable, "server shutting down")
+		}
+	})
+	admin := gin.Default()
+	admin.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	checker := health.New()
+	checker.PreStopDelay = 10 * time.Second
+	addDependencyChecks(checker)
+	admin.GET("/healthz", gin.WrapF(checker.LiveHandler()))
+	admin.GET("/readyz", gin.WrapF(checker.ReadyHandler()))
+
+	app := lifecycle.New()
+	app.StopTimeout = 5 * time.Second
+	// 后台任务最先启动、最后关闭，HTTP 服务关闭时还在处理的请求可以继续使用它
+	app.Add(lifecycle.NewWorker("ticker", func(ctx context.Context) error {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				log.Println("ticker: tick")
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}))
+	// net1/server 的 TCP 服务，协议见 net1/protocal
+	app.Add(lifecycle.NewTCPServer("tcp", "127.0.0.1:30000", processConn), lifecycle.WithStopTimeout(2*time.Second))
+	app.Add(lifecycle.NewHTTPServer("admin", &http.Server{Addr: ":8081", Handler: admin}))
+	// 关闭时先给请求 3 秒自然结束，然后取消请求的 ctx，10 秒后强制关闭剩余连接
+	web := lifecycle.NewHTTPServer("web", &http.Server{Addr: ":8080", Handler: router})
+	web.GracePeriod = 3 * time.Second
+	app.Add(web, lifecycle.WithStopTimeout(10*time.Second))
+	// 最后添加、最先关闭：/readyz 先失败，等待 PreStopDelay 后才开始关闭 HTTP 服务
+	app.Add(checker, lifecycle.WithStopTimeout(15*time.Second))
+
+	if err := app.Run(context.Background()); err != nil {
+		// err 是 lifecycle.Errors，可以看到是哪个组件在启动、运行或关闭时出错
+		log.Fatal(err)
+	}
+	log.Println("all components stopped")
+}
+
+// processConn 读取 client 发来的消息，关闭时打断阻塞的读操作，让连接尽快退出
+func processConn(ctx context.Context, conn net.Conn) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		msg, err := protocal.Decode(reader)
+		if err != nil {
+			return
+		}
+		log.Println("收到client发来的数据：", msg)
 	}
-	log.Println("server exiting")
 }
 
 func main() {
 	//graceShutdown()
+	//appLifecycle()
 	graceRestart()
 }
\ No newline at end of file
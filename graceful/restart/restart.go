@@ -0,0 +1,235 @@
+// Package restart 通过继承文件描述符实现不中断服务的热重启，用来替代 fvbock/endless
+//
+// 流程：老进程收到 SIGHUP 后调用 Upgrade，带着所有监听 socket 重新执行自己，
+// 新进程用 Listen 拿回同名的 socket，全部组件启动后调用 Ready 通知老进程，
+// 老进程收到通知后停止接受新连接，处理完已有请求再退出。只支持 Linux 等类 Unix 系统。
+package restart
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// envListeners 继承的 socket 名称，按顺序对应 fd 3、4、5……
+	envListeners = "GRACEFUL_LISTENERS"
+	// envReadyFD 新进程准备好后往这个 fd 写一个字节
+	envReadyFD = "GRACEFUL_READY_FD"
+)
+
+// ErrNotReady 新进程在 ReadyTimeout 内没有通知准备好，或者提前退出了
+var ErrNotReady = errors.New("restart: child process did not become ready")
+
+// Restarter 管理可以传给新进程的监听 socket
+type Restarter struct {
+	// Path 新进程的可执行文件，为空时使用 os.Executable
+	Path string
+	// Args 新进程的参数，不含程序名，为 nil 时使用 os.Args[1:]
+	Args []string
+	// ReadyTimeout 等待新进程准备好的时间，为 0 时使用 30 秒
+	ReadyTimeout time.Duration
+
+	mu        sync.Mutex
+	inherited map[string]*os.File
+	names     []string
+	listeners map[string]net.Listener
+	ready     *os.File
+	upgrading bool
+}
+
+// New 创建 Restarter，当前进程由 Upgrade 启动时读取继承的 socket
+func New() (*Restarter, error) {
+	r := &Restarter{
+		inherited: make(map[string]*os.File),
+		listeners: make(map[string]net.Listener),
+	}
+	names := os.Getenv(envListeners)
+	readyFD := os.Getenv(envReadyFD)
+	// 清掉环境变量，避免这个进程再启动的其他程序误以为自己继承了 socket
+	os.Unsetenv(envListeners)
+	os.Unsetenv(envReadyFD)
+
+	if names != "" {
+		for i, name := range strings.Split(names, ",") {
+			r.inherited[name] = os.NewFile(uintptr(3+i), name)
+		}
+	}
+	if readyFD != "" {
+		fd, err := strconv.Atoi(readyFD)
+		if err != nil {
+			return nil, fmt.Errorf("restart: invalid %s %q", envReadyFD, readyFD)
+		}
+		r.ready = os.NewFile(uintptr(fd), "ready")
+	}
+	return r, nil
+}
+
+// Inherited 当前进程是否由 Upgrade 启动
+func (r *Restarter) Inherited() bool {
+	return r.ready != nil
+}
+
+// Listen 创建名为 name 的监听 socket，父进程传下来同名的 socket 时直接复用，忽略 addr
+// 可以直接赋值给 lifecycle.App.Listen
+func (r *Restarter) Listen(name, network, addr string) (net.Listener, error) {
+	if name == "" || strings.Contains(name, ",") {
+		return nil, fmt.Errorf("restart: invalid listener name %q", name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.listeners[name]; ok {
+		return nil, fmt.Errorf("restart: listener %q already exists", name)
+	}
+
+	var l net.Listener
+	var err error
+	if f, ok := r.inherited[name]; ok {
+		delete(r.inherited, name)
+		// FileListener 会复制一份 fd，原来的需要关掉
+		l, err = net.FileListener(f)
+		f.Close()
+	} else {
+		l, err = net.Listen(network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.names = append(r.names, name)
+	r.listeners[name] = l
+	return l, nil
+}
+
+// Ready 通知父进程已经准备好，由 Upgrade 启动的进程需要在所有服务启动后调用，其他情况下什么也不做
+// 父进程没有传下来的 socket 会在这里关掉
+func (r *Restarter) Ready() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, f := range r.inherited {
+		f.Close()
+		delete(r.inherited, name)
+	}
+	if r.ready == nil {
+		return nil
+	}
+	_, err := r.ready.Write([]byte{1})
+	r.ready.Close()
+	r.ready = nil
+	return err
+}
+
+// Upgrade 带着所有监听 socket 启动新进程，等新进程调用 Ready 后返回
+// 返回 nil 后调用方应该关闭服务并退出，返回错误时新进程已经被杀掉，老进程可以继续运行
+// 可以直接赋值给 lifecycle.App.Upgrade
+func (r *Restarter) Upgrade() error {
+	r.mu.Lock()
+	if r.upgrading {
+		r.mu.Unlock()
+		return errors.New("restart: upgrade already in progress")
+	}
+	r.upgrading = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.upgrading = false
+		r.mu.Unlock()
+	}()
+
+	names, files, err := r.files()
+	if err != nil {
+		return err
+	}
+	defer closeFiles(files)
+
+	path := r.Path
+	if path == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		path = exe
+	}
+	args := r.Args
+	if args == nil {
+		args = os.Args[1:]
+	}
+	rd, wr, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(environ(),
+		envListeners+"="+strings.Join(names, ","),
+		envReadyFD+"="+strconv.Itoa(3+len(files)),
+	)
+	cmd.ExtraFiles = append(files, wr)
+	err = cmd.Start()
+	// 父进程不再持有写端，新进程退出后读端才能读到 EOF
+	wr.Close()
+	if err != nil {
+		return err
+	}
+
+	timeout := r.ReadyTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	rd.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 1)
+	if n, err := rd.Read(buf); n != 1 {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("%w: %v", ErrNotReady, err)
+	}
+	// 回收新进程，避免老进程退出前新进程先退出变成僵尸进程
+	go cmd.Wait()
+	return nil
+}
+
+// files 复制所有监听 socket 的 fd，顺序和名称一一对应
+func (r *Restarter) files() ([]string, []*os.File, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	files := make([]*os.File, 0, len(r.names))
+	for _, name := range r.names {
+		fl, ok := r.listeners[name].(interface{ File() (*os.File, error) })
+		if !ok {
+			closeFiles(files)
+			return nil, nil, fmt.Errorf("restart: listener %q can not be passed to child", name)
+		}
+		f, err := fl.File()
+		if err != nil {
+			closeFiles(files)
+			return nil, nil, err
+		}
+		files = append(files, f)
+	}
+	return append([]string(nil), r.names...), files, nil
+}
+
+// environ 去掉上一次继承时留下的环境变量
+func environ() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, envListeners+"=") || strings.HasPrefix(kv, envReadyFD+"=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
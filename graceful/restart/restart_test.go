@@ -0,0 +1,141 @@
+//go:build linux
+// +build linux
+
+package restart
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// helperEnv 为 1 时测试程序扮演 Upgrade 启动的新进程，为 fail 时模拟启动失败
+const helperEnv = "RESTART_TEST_HELPER"
+
+// TestHelperProcess 不是真正的测试，Upgrade 重新执行测试程序时只运行它
+func TestHelperProcess(t *testing.T) {
+	mode := os.Getenv(helperEnv)
+	if mode == "" {
+		return
+	}
+	if mode == "fail" {
+		os.Exit(1)
+	}
+	r, err := New()
+	if err != nil || !r.Inherited() {
+		fmt.Fprintln(os.Stderr, "not inherited:", err)
+		os.Exit(2)
+	}
+	web, err := r.Listen("web", "tcp", "127.0.0.1:0")
+	if err != nil {
+		os.Exit(3)
+	}
+	tcp, err := r.Listen("tcp", "tcp", "127.0.0.1:0")
+	if err != nil {
+		os.Exit(4)
+	}
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("child"))
+			conn.Close()
+		}
+	}()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("child"))
+	})
+	mux.HandleFunc("/exit", func(w http.ResponseWriter, req *http.Request) {
+		go os.Exit(0)
+	})
+	go http.Serve(web, mux)
+	if err := r.Ready(); err != nil {
+		os.Exit(5)
+	}
+	// 测试没有叫退出时自己退出，避免留下进程
+	time.Sleep(10 * time.Second)
+	os.Exit(0)
+}
+
+func newParent(t *testing.T, mode string) *Restarter {
+	os.Setenv(helperEnv, mode)
+	t.Cleanup(func() { os.Unsetenv(helperEnv) })
+	r, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Args = []string{"-test.run=^TestHelperProcess$"}
+	r.ReadyTimeout = 5 * time.Second
+	return r
+}
+
+func TestUpgradePassesListeners(t *testing.T) {
+	r := newParent(t, "1")
+	web, err := r.Listen("web", "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcp, err := r.Listen("tcp", "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Upgrade(); err != nil {
+		t.Fatal(err)
+	}
+	// 老进程停止接受连接，之后的连接都由新进程处理
+	web.Close()
+	tcp.Close()
+
+	res, err := http.Get("http://" + web.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "child" {
+		t.Fatalf("http body = %q", body)
+	}
+
+	conn, err := net.Dial("tcp", tcp.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(conn)
+	conn.Close()
+	if string(b) != "child" {
+		t.Fatalf("tcp got %q", b)
+	}
+
+	http.Get("http://" + web.Addr().String() + "/exit")
+}
+
+func TestUpgradeChildFails(t *testing.T) {
+	r := newParent(t, "fail")
+	web, err := r.Listen("web", "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer web.Close()
+	if err := r.Upgrade(); !errors.Is(err, ErrNotReady) {
+		t.Fatalf("Upgrade = %v, want ErrNotReady", err)
+	}
+	// 升级失败后老进程的 socket 还能继续使用
+	go func() {
+		if conn, err := web.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("tcp", web.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
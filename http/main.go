@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
+	"main/server"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func sayHello(w http.ResponseWriter, r *http.Request) {
@@ -96,11 +101,46 @@ func main() {
 	//}
 
 	// 自定义 server
-	s := &http.Server{
-		Addr: ":8080",
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxHeaderBytes: 1 << 20,
+	// 超时、请求头大小和证书都在配置文件中，配置了证书时提供 HTTPS 和 HTTP/2
+	// 证书文件更新后自动加载，也可以发送 SIGHUP 立即加载：kill -HUP <pid>
+	configFile := flag.String("config", "", "server config file (json), empty means defaults on :8080")
+	flag.Parse()
+	cfg := server.DefaultConfig()
+	if *configFile != "" {
+		var err error
+		if cfg, err = server.Load(*configFile); err != nil {
+			log.Fatal(err)
+		}
 	}
-	log.Fatal(s.ListenAndServe())
-}
\ No newline at end of file
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", sayHello)
+	s, err := server.New(cfg, mux)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for sig := range sigs {
+			if sig != syscall.SIGHUP {
+				cancel()
+				return
+			}
+			if s.Certs() == nil {
+				continue
+			}
+			if err := s.Certs().Reload(); err != nil {
+				log.Printf("reload certificate failed: %v", err)
+			} else {
+				log.Printf("certificate reloaded")
+			}
+		}
+	}()
+	log.Printf("listening on %s, tls: %t", cfg.Addr, cfg.TLS != nil)
+	if err := s.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// Config 服务的地址、超时和 TLS 配置，可以从 JSON 文件加载
+//
+//	{
+//	  "addr": ":8443",
+//	  "read_timeout": "10s",
+//	  "read_header_timeout": "5s",
+//	  "write_timeout": "10s",
+//	  "idle_timeout": "2m",
+//	  "max_header_bytes": 1048576,
+//	  "tls": {"cert_file": "server.crt", "key_file": "server.key", "reload_interval": "30s"}
+//	}
+type Config struct {
+	Addr string `json:"addr"`
+	// ReadTimeout 读取整个请求（包括 body）的超时
+	ReadTimeout Duration `json:"read_timeout"`
+	// ReadHeaderTimeout 读取请求头的超时，防止慢速攻击，为 0 时使用 ReadTimeout
+	ReadHeaderTimeout Duration `json:"read_header_timeout"`
+	// WriteTimeout 从读完请求头到写完响应的超时
+	WriteTimeout Duration `json:"write_timeout"`
+	// IdleTimeout keep-alive 连接空闲多久后关闭，为 0 时使用 ReadTimeout
+	IdleTimeout    Duration `json:"idle_timeout"`
+	MaxHeaderBytes int      `json:"max_header_bytes"`
+	// ShutdownTimeout 退出时等待正在处理的请求的最长时间
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+	// TLS 为 nil 时提供明文 HTTP 服务
+	TLS *TLSConfig `json:"tls"`
+}
+
+// TLSConfig 证书配置
+type TLSConfig struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// ReloadInterval 检查证书文件是否变化的间隔，为 0 时使用 1 分钟，小于 0 时不检查（可以调用 Reload 手动加载）
+	ReloadInterval Duration `json:"reload_interval"`
+	// DisableHTTP2 只提供 HTTP/1.1
+	DisableHTTP2 bool `json:"disable_http2"`
+}
+
+// DefaultConfig 默认配置，和原来 http/main.go 中的设置一致，另外加上了请求头和空闲连接的超时
+func DefaultConfig() Config {
+	return Config{
+		Addr:              ":8080",
+		ReadTimeout:       Duration(10 * time.Second),
+		ReadHeaderTimeout: Duration(5 * time.Second),
+		WriteTimeout:      Duration(10 * time.Second),
+		IdleTimeout:       Duration(2 * time.Minute),
+		MaxHeaderBytes:    1 << 20,
+		ShutdownTimeout:   Duration(10 * time.Second),
+	}
+}
+
+// Load 读取 JSON 配置文件，文件中没有写的字段使用 DefaultConfig 中的值
+func Load(path string) (Config, error) {
+	cfg := DefaultConfig()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("server: parse %s: %w", path, err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate 检查配置
+func (c Config) Validate() error {
+	if c.Addr == "" {
+		return errors.New("server: addr is required")
+	}
+	if c.ReadTimeout < 0 || c.ReadHeaderTimeout < 0 || c.WriteTimeout < 0 || c.IdleTimeout < 0 {
+		return errors.New("server: timeouts must not be negative")
+	}
+	if c.TLS != nil && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		return errors.New("server: tls requires both cert_file and key_file")
+	}
+	return nil
+}
+
+// Duration JSON 中写成 "10s" 这样的字符串，也可以写纳秒数
+type Duration time.Duration
+
+// UnmarshalJSON 实现 json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v)
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("server: invalid duration %s", b)
+	}
+	return nil
+}
+
+// MarshalJSON 实现 json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader 证书文件更新后自动加载新证书，不需要重启服务
+// 通过 tls.Config.GetCertificate 在每次握手时取当前的证书，已经建立的连接不受影响
+type CertReloader struct {
+	certFile, keyFile string
+
+	mu    sync.RWMutex
+	cert  *tls.Certificate
+	stamp fileStamp
+}
+
+// fileStamp 证书和私钥文件的修改时间和大小，任意一个变了就重新加载
+type fileStamp struct {
+	certMod, keyMod   time.Time
+	certSize, keySize int64
+}
+
+// NewCertReloader 加载证书，失败时返回错误
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate 用作 tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload 重新读取证书文件，失败时继续使用原来的证书
+func (r *CertReloader) Reload() error {
+	stamp, err := r.statFiles()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cert, r.stamp = &cert, stamp
+	r.mu.Unlock()
+	return nil
+}
+
+// Watch 每隔 interval 检查一次证书文件，直到 ctx 结束
+// 证书和私钥通常是分两次写入的，中间加载失败时保留旧证书，下次检查时再试
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		stamp, err := r.statFiles()
+		if err != nil {
+			log.Printf("server: stat certificate: %v", err)
+			continue
+		}
+		r.mu.RLock()
+		changed := stamp != r.stamp
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			log.Printf("server: reload certificate failed, keep using the old one: %v", err)
+			continue
+		}
+		log.Printf("server: certificate %s reloaded", r.certFile)
+	}
+}
+
+func (r *CertReloader) statFiles() (fileStamp, error) {
+	cert, err := os.Stat(r.certFile)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	key, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{
+		certMod: cert.ModTime(), keyMod: key.ModTime(),
+		certSize: cert.Size(), keySize: key.Size(),
+	}, nil
+}
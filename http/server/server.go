@@ -0,0 +1,120 @@
+// Package server 按配置启动 net/http 服务
+//
+// 超时和请求头大小都来自 Config，配置了证书时提供 TLS 和 HTTP/2，
+// 证书文件更新后通过 tls.Config.GetCertificate 自动换成新证书，不需要重启。
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Server 包装 http.Server
+type Server struct {
+	cfg   Config
+	srv   *http.Server
+	certs *CertReloader
+}
+
+// New 按配置创建服务，配置了 TLS 时会先加载一次证书
+func New(cfg Config, h http.Handler) (*Server, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	s := &Server{cfg: cfg, srv: &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           h,
+		ReadTimeout:       time.Duration(cfg.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(cfg.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.IdleTimeout),
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}}
+	if cfg.TLS == nil {
+		return s, nil
+	}
+
+	certs, err := NewCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	s.certs = certs
+	s.srv.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	if cfg.TLS.DisableHTTP2 {
+		s.srv.TLSConfig.NextProtos = []string{"http/1.1"}
+		// TLSNextProto 不为 nil 时 net/http 不会自动启用 HTTP/2
+		s.srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+	return s, nil
+}
+
+// HTTPServer 返回底层的 http.Server，可以在 Run 之前调整其他设置
+func (s *Server) HTTPServer() *http.Server {
+	return s.srv
+}
+
+// Certs 返回证书加载器，没有配置 TLS 时返回 nil
+func (s *Server) Certs() *CertReloader {
+	return s.certs
+}
+
+// Run 监听 Config.Addr 并提供服务，ctx 结束时优雅退出
+func (s *Server) Run(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve 在 l 上提供服务，ctx 结束时停止接受新连接，等待正在处理的请求完成，最多等 ShutdownTimeout
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if s.certs != nil {
+		interval := time.Duration(s.cfg.TLS.ReloadInterval)
+		if interval == 0 {
+			interval = time.Minute
+		}
+		if interval > 0 {
+			go s.certs.Watch(ctx, interval)
+		}
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if s.certs != nil {
+			// 证书由 GetCertificate 提供，这里不需要文件名
+			errCh <- s.srv.ServeTLS(l, "", "")
+		} else {
+			errCh <- s.srv.Serve(l)
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	timeout := time.Duration(s.cfg.ShutdownTimeout)
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	sctx, scancel := context.WithTimeout(context.Background(), timeout)
+	defer scancel()
+	if err := s.srv.Shutdown(sctx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
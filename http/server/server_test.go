@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert 生成自签名证书写到 dir 中
+func writeCert(t *testing.T, dir, cn string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(filepath.Join(dir, "server.crt"), certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "server.key"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSAndReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeCert(t, dir, "first")
+
+	cfg := DefaultConfig()
+	cfg.TLS = &TLSConfig{
+		CertFile:       filepath.Join(dir, "server.crt"),
+		KeyFile:        filepath.Join(dir, "server.key"),
+		ReloadInterval: Duration(10 * time.Millisecond),
+	}
+	s, err := New(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, l) }()
+
+	// 每次都建立新连接，才能看到换过的证书
+	get := func() (proto, cn string) {
+		tr := &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		}
+		defer tr.CloseIdleConnections()
+		resp, err := (&http.Client{Transport: tr}).Get("https://" + l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return string(b), resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	if proto, cn := get(); proto != "HTTP/2.0" || cn != "first" {
+		t.Fatalf("proto = %s, cn = %s", proto, cn)
+	}
+
+	// 写坏的证书时继续使用旧证书
+	if err := ioutil.WriteFile(cfg.TLS.CertFile, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, cn := get(); cn != "first" {
+		t.Fatalf("cn = %s after broken cert, want first", cn)
+	}
+
+	writeCert(t, dir, "second")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, cn := get(); cn == "second" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("certificate was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	f, err := ioutil.TempFile("", "server*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"addr": ":9443", "read_header_timeout": "2s", "idle_timeout": 30000000000}`)
+	f.Close()
+
+	cfg, err := Load(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != ":9443" || cfg.ReadHeaderTimeout != Duration(2*time.Second) || cfg.IdleTimeout != Duration(30*time.Second) {
+		t.Fatalf("cfg = %+v", cfg)
+	}
+	// 没写的字段使用默认值
+	if cfg.ReadTimeout != DefaultConfig().ReadTimeout || cfg.MaxHeaderBytes != 1<<20 {
+		t.Fatalf("defaults not kept: %+v", cfg)
+	}
+
+	cfg.TLS = &TLSConfig{CertFile: "server.crt"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("want error for missing key file")
+	}
+}